@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/fikrialwan/FitByte/config"
+)
+
+// runConfigCommand handles `app config <subcommand>` and returns the process
+// exit code:
+//
+//	config print     print the effective configuration with secrets redacted
+//	config validate  only report configuration problems
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || (args[0] != "print" && args[0] != "validate") {
+		fmt.Fprintln(os.Stderr, "usage: app config <print|validate>")
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if args[0] == "print" {
+		if err := cfg.PrintRedacted(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			fmt.Fprintln(os.Stderr, validationErr.Error())
+		}
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, "configuration is valid")
+	return 0
+}
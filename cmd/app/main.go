@@ -2,23 +2,18 @@ package main
 
 import (
 	"context"
-	"log"
-	"net/http"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
 	"github.com/fikrialwan/FitByte/config"
 	_ "github.com/fikrialwan/FitByte/docs" // This will be generated by swag init
-	"github.com/fikrialwan/FitByte/internal/controller"
-	"github.com/fikrialwan/FitByte/internal/repository"
-	"github.com/fikrialwan/FitByte/internal/routes"
-	"github.com/fikrialwan/FitByte/internal/service"
-	"github.com/fikrialwan/FitByte/middlewares" // Added import for middlewares
+	"github.com/fikrialwan/FitByte/internal/app"
+	"github.com/fikrialwan/FitByte/internal/logging"
 	"github.com/gin-gonic/gin"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
 // @title FitByte API
@@ -42,108 +37,47 @@ import (
 // @description Type "Bearer" followed by a space and JWT token.
 
 func main() {
-	server := gin.Default()
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
 
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatal("failed to load config")
-	}
-
-	registerRoutesAndInjectDependency(server, cfg)
-
-	run(server, cfg)
-}
-
-func run(server *gin.Engine, cfg *config.Config) {
-	port := cfg.AppPort
-	if port == "" {
-		port = "8080"
+		fatal("failed to load config", err)
 	}
 
-	host := cfg.AppEnv
-	var serve string
-	if host == "develop" {
-		serve = "0.0.0.0:" + port
-	} else {
-		serve = ":" + port
-	}
-
-	// Create HTTP server with timeouts
-	srv := &http.Server{
-		Addr:         serve,
-		Handler:      server,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	logLevel := setupLogging(cfg)
+	a, err := app.New(cfg, app.WithLogLevel(logLevel))
+	if err != nil {
+		fatal("failed to start", err)
 	}
 
-	// Start server in a goroutine
-	go func() {
-		log.Printf("Starting server on %s", serve)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
-		}
-	}()
-
-	// Wait for interrupt signal to gracefully shutdown the server
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Println("Shutting down server...")
-
-	// Give outstanding requests a deadline for completion
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Attempt graceful shutdown
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
-	} else {
-		log.Println("Server exited gracefully")
+	// Serve until SIGINT or SIGTERM, then shut down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := a.Run(ctx); err != nil {
+		fatal("server stopped with errors", err)
 	}
 }
 
-func registerRoutesAndInjectDependency(server *gin.Engine, cfg *config.Config) {
-	db := config.InitDb(cfg)
+// setupLogging makes JSON logs on stdout the default, including gin's debug
+// output. The returned level follows LOG_LEVEL reloads once given to the App.
+func setupLogging(cfg *config.Config) *slog.LevelVar {
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.GetLogLevel())
+	slog.SetDefault(logging.New(os.Stdout, logLevel))
 
-	userRepository := repository.NewUserRepository(db)
-	activityRepository := repository.NewActivityRepository(db)
-
-	jwtService := service.NewJwtService(cfg)
-	cacheService := service.NewCacheService(cfg)
-	userService := service.NewUserService(userRepository, jwtService, cacheService)
-	fileService := service.NewFileService(cfg)
-	activityService := service.NewActivityService(activityRepository)
-
-	userController := controller.NewUserController(userService)
-	fileController := controller.NewFileController(fileService)
-	activityController := controller.NewActivityController(activityService)
-	healthController := controller.NewHealthController(db, cacheService, fileService)
-
-	// Add CORS middleware
-	server.Use(middlewares.CORS(cfg))
-
-	// Add rate limiting middleware only if enabled
-	if cfg.RateLimitEnabled {
-		middlewares.InitGlobalRateLimiter(cfg)
-		server.Use(middlewares.RateLimit(middlewares.GlobalRateLimiter))
+	gin.DebugPrintFunc = func(format string, values ...interface{}) {
+		slog.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)), "component", "gin")
 	}
+	gin.DebugPrintRouteFunc = func(method, path, handler string, handlers int) {
+		slog.Debug("route registered", "component", "gin", "method", method, "path", path, "handler", handler)
+	}
+	return logLevel
+}
 
-	// Swagger endpoints with custom configuration
-	server.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.PersistAuthorization(true)))
-
-	// Create v1 route group
-	v1 := server.Group("/v1")
-
-	// Health check endpoints under v1
-	routes.RegisterHealthRoutes(v1, healthController)
-
-	// User routes under v1
-	routes.RegisterUserRoutes(v1, userController, jwtService)
-
-	// File routes under v1
-	routes.RegisterFileRoutes(v1, fileController, jwtService)
-
-	// Activity routes under v1
-	routes.RegisterActivityRoutes(v1, activityController, jwtService)
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -1,21 +1,100 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/fikrialwan/FitByte/config"
 	"github.com/fikrialwan/FitByte/database"
+	"github.com/fikrialwan/FitByte/internal/clock"
 )
 
+const usage = `usage: migrate <command>
+
+commands:
+  up             apply all pending migrations
+  down [N]       roll back the last N migrations (default 1)
+  status         list migrations and whether they are applied
+  create <name>  add an empty migration pair to database/migrations`
+
 func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	// create only writes files, it doesn't need a database
+	if os.Args[1] == "create" {
+		if len(os.Args) < 3 {
+			log.Fatal(usage)
+		}
+		up, down, err := database.CreateMigration("database/migrations", os.Args[2])
+		if err != nil {
+			log.Fatalf("Error create migration: %v", err)
+		}
+		log.Printf("created %s and %s", up, down)
+		return
+	}
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatal("failed to load config")
+		log.Fatalf("failed to load config: %v", err)
+	}
+	db, err := config.InitDb(cfg, clock.System)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		log.Fatalf("Error loading migrations: %v", err)
 	}
-	db := config.InitDb(cfg)
+	// 0002 only converts image URLs pointing at our own storage
+	migrator.WithSettings(map[string]string{
+		"fitbyte.storage_hosts":  strings.ToLower(cfg.GetMinIOPublicEndpoint() + "," + cfg.MinIOEndpoint),
+		"fitbyte.storage_bucket": cfg.MinIOBucket,
+	})
 
-	if err := database.Migrate(db); err != nil {
-		log.Fatalf("Error migration: %v", err)
+	switch os.Args[1] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			log.Printf("applied %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Error migration: %v", err)
+		}
+		log.Println("complete migration successfully!")
+	case "down":
+		n := 1
+		if len(os.Args) > 2 {
+			if n, err = strconv.Atoi(os.Args[2]); err != nil || n < 1 {
+				log.Fatalf("invalid number of migrations '%s'", os.Args[2])
+			}
+		}
+		rolledBack, err := migrator.Down(n)
+		for _, migration := range rolledBack {
+			log.Printf("rolled back %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Error rollback: %v", err)
+		}
+		log.Println("complete rollback successfully!")
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("Error migration status: %v", err)
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, appliedAt)
+		}
+	default:
+		log.Fatal(usage)
 	}
-	log.Println("complete migration successfully!")
 }
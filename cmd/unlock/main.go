@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/service"
+)
+
+// Clears failed login attempts and lockouts for an email and/or client IP:
+//
+//	go run cmd/unlock/main.go --email user@example.com --ip 203.0.113.7
+func main() {
+	email := flag.String("email", "", "email to unlock")
+	ip := flag.String("ip", "", "client IP to unlock")
+	flag.Parse()
+
+	if *email == "" && *ip == "" {
+		log.Fatal("at least one of --email or --ip is required")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	cacheService := service.NewCacheService(cfg, config.InitRedis(cfg))
+	loginAttemptService := service.NewLoginAttemptService(cfg, cacheService)
+
+	if err := loginAttemptService.Unlock(context.Background(), *email, *ip); err != nil {
+		log.Fatalf("Error unlock: %v", err)
+	}
+	log.Println("login unlocked successfully!")
+}
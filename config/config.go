@@ -2,8 +2,10 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,58 +19,188 @@ type Config struct {
 	DBHost     string `koanf:"DB_HOST"`
 	DBPort     int    `koanf:"DB_PORT"`
 	DBUser     string `koanf:"DB_USER"`
-	DBPassword string `koanf:"DB_PASS"`
+	DBPassword string `koanf:"DB_PASS" secret:"true"`
 	DBName     string `koanf:"DB_NAME"`
 
 	AppPort string `koanf:"APP_PORT"`
 	AppEnv  string `koanf:"APP_ENV"`
 	AppHost string `koanf:"APP_HOST"`
+	// Port of /metrics and /v1/cache/stats, kept off the public API
+	MetricsPort string `koanf:"METRICS_PORT"`
 
-	JWTSecret string `koanf:"JWT_SECRET"`
+	JWTSecret string `koanf:"JWT_SECRET" secret:"true"`
+
+	// Password Hashing (bcrypt or argon2id)
+	PasswordHashAlgorithm string `koanf:"PASSWORD_HASH_ALGORITHM"`
+	BcryptCost            int    `koanf:"BCRYPT_COST"`
+	Argon2MemoryKB        int    `koanf:"ARGON2_MEMORY_KB"`
+	Argon2Iterations      int    `koanf:"ARGON2_ITERATIONS"`
+	Argon2Parallelism     int    `koanf:"ARGON2_PARALLELISM"`
 
 	MinIOEndpoint  string `koanf:"MINIO_ENDPOINT"`
-	MinIOAccessKey string `koanf:"MINIO_ACCESS_KEY"`
-	MinIOSecretKey string `koanf:"MINIO_SECRET_KEY"`
+	MinIOAccessKey string `koanf:"MINIO_ACCESS_KEY" secret:"true"`
+	MinIOSecretKey string `koanf:"MINIO_SECRET_KEY" secret:"true"`
 	MinIOBucket    string `koanf:"MINIO_BUCKET"`
-	MinIOUseSSL    string `koanf:"MINIO_USE_SSL"`
+	MinIOUseSSL    bool   `koanf:"MINIO_USE_SSL"`
+
+	// Host used when signing download URLs handed to clients, defaults to MINIO_ENDPOINT
+	MinIOPublicEndpoint string        `koanf:"MINIO_PUBLIC_ENDPOINT"`
+	MinIOPresignExpiry  time.Duration `koanf:"MINIO_PRESIGN_EXPIRY"`
+
+	// Comma separated schemes and hosts accepted for profile imageUri, empty
+	// hosts allows any host. "*.example.com" matches subdomains.
+	ImageURIAllowedSchemes string `koanf:"IMAGE_URI_ALLOWED_SCHEMES"`
+	ImageURIAllowedHosts   string `koanf:"IMAGE_URI_ALLOWED_HOSTS"`
 
 	RedisAddr     string `koanf:"REDIS_ADDR"`
-	RedisPassword string `koanf:"REDIS_PASSWORD"`
+	RedisPassword string `koanf:"REDIS_PASSWORD" secret:"true"`
 	GinMode       string `koanf:"GIN_MODE"`
 
+	// Minimum level for structured logs: debug, info, warn or error
+	LogLevel string `koanf:"LOG_LEVEL"`
+
+	// Tracing: otlp, stdout or none. The OTLP exporter sends to
+	// TRACING_OTLP_ENDPOINT over HTTP, or to the standard OTEL_EXPORTER_OTLP_*
+	// settings when unset.
+	TracingExporter     string  `koanf:"TRACING_EXPORTER"`
+	TracingServiceName  string  `koanf:"TRACING_SERVICE_NAME"`
+	TracingOTLPEndpoint string  `koanf:"TRACING_OTLP_ENDPOINT"`
+	TracingOTLPInsecure bool    `koanf:"TRACING_OTLP_INSECURE"`
+	TracingSampleRatio  float64 `koanf:"TRACING_SAMPLE_RATIO"`
+
+	// Cache Configuration
+	CacheDriver           string        `koanf:"CACHE_DRIVER"`
+	CacheLocalMaxEntries  int           `koanf:"CACHE_LOCAL_MAX_ENTRIES"`
+	CacheLocalTTL         time.Duration `koanf:"CACHE_LOCAL_TTL"`
+	CacheBreakerThreshold int           `koanf:"CACHE_BREAKER_THRESHOLD"`
+	CacheBreakerCooldown  time.Duration `koanf:"CACHE_BREAKER_COOLDOWN"`
+
+	// IdempotencyKeyTTL is how long a response is replayed for retries
+	// carrying the same Idempotency-Key
+	IdempotencyKeyTTL time.Duration `koanf:"IDEMPOTENCY_KEY_TTL"`
+
+	// Deleted activities are purged once they've been in the trash for
+	// TrashRetentionDays, checked every TrashPurgeInterval
+	TrashRetentionDays int           `koanf:"TRASH_RETENTION_DAYS"`
+	TrashPurgeInterval time.Duration `koanf:"TRASH_PURGE_INTERVAL"`
+
+	// Domain events are polled from the outbox every OutboxPollInterval. A
+	// failed delivery is retried after OutboxRetryBackoff, doubling on each
+	// attempt, up to OutboxMaxAttempts. Published events are deleted after
+	// OutboxRetentionDays.
+	OutboxPollInterval  time.Duration `koanf:"OUTBOX_POLL_INTERVAL"`
+	OutboxRetryBackoff  time.Duration `koanf:"OUTBOX_RETRY_BACKOFF"`
+	OutboxMaxAttempts   int           `koanf:"OUTBOX_MAX_ATTEMPTS"`
+	OutboxRetentionDays int           `koanf:"OUTBOX_RETENTION_DAYS"`
+
+	// AdminUserIDs lists the users, by UUID and comma separated, allowed on
+	// the /v1/admin routes
+	AdminUserIDs string `koanf:"ADMIN_USER_IDS"`
+
+	// TrustedProxies lists the proxy IPs or CIDRs, comma separated, whose
+	// X-Forwarded-For and X-Real-IP headers name the client. Empty trusts
+	// none, the client is the address the request came from
+	TrustedProxies string `koanf:"TRUSTED_PROXIES"`
+
 	// CORS Configuration
-	CORSAllowedOrigins   string `koanf:"CORS_ALLOWED_ORIGINS"`
-	CORSAllowedMethods   string `koanf:"CORS_ALLOWED_METHODS"`
-	CORSAllowedHeaders   string `koanf:"CORS_ALLOWED_HEADERS"`
-	CORSExposeHeaders    string `koanf:"CORS_EXPOSE_HEADERS"`
-	CORSAllowCredentials bool   `koanf:"CORS_ALLOW_CREDENTIALS"`
-	CORSMaxAge           int    `koanf:"CORS_MAX_AGE"`
+	CORSAllowedOrigins   string        `koanf:"CORS_ALLOWED_ORIGINS"`
+	CORSAllowedMethods   string        `koanf:"CORS_ALLOWED_METHODS"`
+	CORSAllowedHeaders   string        `koanf:"CORS_ALLOWED_HEADERS"`
+	CORSExposeHeaders    string        `koanf:"CORS_EXPOSE_HEADERS"`
+	CORSAllowCredentials bool          `koanf:"CORS_ALLOW_CREDENTIALS"`
+	CORSMaxAge           time.Duration `koanf:"CORS_MAX_AGE"`
 
 	// Rate Limit
-	RateLimitEnabled   bool `koanf:"RATE_LIMIT_ENABLED"`
-	RateLimitPerSecond int  `koanf:"RATE_LIMIT_PER_SECOND"`
-	RateLimitBurst     int  `koanf:"RATE_LIMIT_BURST"`
+	RateLimitEnabled       bool   `koanf:"RATE_LIMIT_ENABLED"`
+	RateLimitDriver        string `koanf:"RATE_LIMIT_DRIVER"`
+	RateLimitPerSecond     int    `koanf:"RATE_LIMIT_PER_SECOND"`
+	RateLimitBurst         int    `koanf:"RATE_LIMIT_BURST"`
+	RateLimitAuthPerMinute int    `koanf:"RATE_LIMIT_AUTH_PER_MINUTE"`
+	RateLimitAuthBurst     int    `koanf:"RATE_LIMIT_AUTH_BURST"`
+	RateLimitUserPerSecond int    `koanf:"RATE_LIMIT_USER_PER_SECOND"`
+	RateLimitUserBurst     int    `koanf:"RATE_LIMIT_USER_BURST"`
+
+	// Login Protection
+	LoginMaxAttemptsPerEmail int           `koanf:"LOGIN_MAX_ATTEMPTS_PER_EMAIL"`
+	LoginMaxAttemptsPerIP    int           `koanf:"LOGIN_MAX_ATTEMPTS_PER_IP"`
+	LoginAttemptWindow       time.Duration `koanf:"LOGIN_ATTEMPT_WINDOW"`
+	LoginLockoutBase         time.Duration `koanf:"LOGIN_LOCKOUT_BASE"`
+	LoginLockoutMax          time.Duration `koanf:"LOGIN_LOCKOUT_MAX"`
+
+	// Readiness checks: deadline of each dependency check and how long its
+	// result is reused by later probes
+	HealthCheckTimeout  time.Duration `koanf:"HEALTH_CHECK_TIMEOUT"`
+	HealthCheckCacheTTL time.Duration `koanf:"HEALTH_CHECK_CACHE_TTL"`
+
+	// Deadlines: requests are cancelled after REQUEST_TIMEOUT, and every
+	// database query, cache command and storage call within them is bounded
+	// by its own, shorter deadline
+	RequestTimeout time.Duration `koanf:"REQUEST_TIMEOUT"`
+	DBQueryTimeout time.Duration `koanf:"DB_QUERY_TIMEOUT"`
+	CacheTimeout   time.Duration `koanf:"CACHE_TIMEOUT"`
+	StorageTimeout time.Duration `koanf:"STORAGE_TIMEOUT"`
 
 	// Database Connection Pool Configuration
-	DBMaxIdleConns    int `koanf:"DB_MAX_IDLE_CONNS"`
-	DBMaxOpenConns    int `koanf:"DB_MAX_OPEN_CONNS"`
-	DBConnMaxLifetime int `koanf:"DB_CONN_MAX_LIFETIME_MINUTES"`
-	DBConnMaxIdleTime int `koanf:"DB_CONN_MAX_IDLE_TIME_MINUTES"`
+	DBMaxIdleConns    int           `koanf:"DB_MAX_IDLE_CONNS"`
+	DBMaxOpenConns    int           `koanf:"DB_MAX_OPEN_CONNS"`
+	DBConnMaxLifetime time.Duration `koanf:"DB_CONN_MAX_LIFETIME"`
+	DBConnMaxIdleTime time.Duration `koanf:"DB_CONN_MAX_IDLE_TIME"`
+
+	// invalidLegacyKeys lists legacy keys whose value isn't a whole number
+	invalidLegacyKeys []string
 }
 
+// bareDurationKeys are durations that used to be integers in the given unit.
+// Such values are still read in that unit, with a deprecation warning.
+var bareDurationKeys = map[string]string{
+	"CORS_MAX_AGE": "h",
+}
+
+// legacyDuration is the duration key and unit a legacy integer key maps to
+type legacyDuration struct {
+	key  string
+	unit string
+}
+
+// legacyDurationKeys are integer keys in a unit that were replaced by typed
+// durations. Their value is still read into the replacement, with a
+// deprecation warning, unless the replacement is set too.
+var legacyDurationKeys = map[string]legacyDuration{
+	"MINIO_PRESIGN_EXPIRY_MINUTES":   {key: "MINIO_PRESIGN_EXPIRY", unit: "m"},
+	"CACHE_LOCAL_TTL_SECONDS":        {key: "CACHE_LOCAL_TTL", unit: "s"},
+	"CACHE_BREAKER_COOLDOWN_SECONDS": {key: "CACHE_BREAKER_COOLDOWN", unit: "s"},
+	"LOGIN_ATTEMPT_WINDOW_MINUTES":   {key: "LOGIN_ATTEMPT_WINDOW", unit: "m"},
+	"LOGIN_LOCKOUT_BASE_SECONDS":     {key: "LOGIN_LOCKOUT_BASE", unit: "s"},
+	"LOGIN_LOCKOUT_MAX_MINUTES":      {key: "LOGIN_LOCKOUT_MAX", unit: "m"},
+	"DB_CONN_MAX_LIFETIME_MINUTES":   {key: "DB_CONN_MAX_LIFETIME", unit: "m"},
+	"DB_CONN_MAX_IDLE_TIME_MINUTES":  {key: "DB_CONN_MAX_IDLE_TIME", unit: "m"},
+}
+
+// LoadConfig loads and validates the configuration, failing with every
+// problem found at once
 func LoadConfig() (*Config, error) {
-	k := koanf.New(".")
+	config, err := Load()
+	if err != nil {
+		return nil, err
+	}
 
-	configPath := os.Getenv("CONFIG_FILE_PATH")
-	if configPath == "" {
-		configPath = ".env"
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
 
+	return config, nil
+}
+
+// Load reads the config file and environment without validating them
+func Load() (*Config, error) {
+	k := koanf.New(".")
+
+	configPath := FilePath()
 	if err := k.Load(file.Provider(configPath), dotenv.Parser()); err != nil {
 		if os.IsNotExist(err) {
-			log.Default().Printf("Info: config file '%s' not found, using environment variables only", configPath)
+			slog.Info("config file not found, using environment variables only", "path", configPath)
 		} else {
-			log.Default().Printf("Warning: error reading config file '%s': %v", configPath, err)
+			slog.Warn("error reading config file", "path", configPath, "error", err)
 		}
 	}
 
@@ -76,14 +208,66 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to load environment variables: %w", err)
 	}
 
+	var invalidLegacyKeys []string
+	for legacyKey, legacy := range legacyDurationKeys {
+		value := strings.TrimSpace(k.String(legacyKey))
+		if value == "" {
+			continue
+		}
+		if k.Exists(legacy.key) {
+			slog.Warn("deprecated key ignored, its replacement is set", "key", legacyKey, "replacement", legacy.key)
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			invalidLegacyKeys = append(invalidLegacyKeys, legacyKey)
+			continue
+		}
+		duration := fmt.Sprintf("%d%s", n, legacy.unit)
+		slog.Warn("deprecated key, use its replacement", "key", legacyKey, "value", value, "replacement", legacy.key, "read_as", duration)
+		if err := k.Set(legacy.key, duration); err != nil {
+			return nil, fmt.Errorf("failed to convert %s: %w", legacyKey, err)
+		}
+	}
+
+	for key, unit := range bareDurationKeys {
+		if value := strings.TrimSpace(k.String(key)); value != "" {
+			if n, err := strconv.Atoi(value); err == nil {
+				duration := fmt.Sprintf("%d%s", n, unit)
+				slog.Warn("a duration without a unit is deprecated", "key", key, "value", value, "read_as", duration)
+				if err := k.Set(key, duration); err != nil {
+					return nil, fmt.Errorf("failed to convert %s: %w", key, err)
+				}
+			}
+		}
+	}
+
 	config := &Config{}
 	if err := k.Unmarshal("", config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	sort.Strings(invalidLegacyKeys)
+	config.invalidLegacyKeys = invalidLegacyKeys
+
 	return config, nil
 }
 
+// FilePath returns the config file read by Load, set with CONFIG_FILE_PATH
+func FilePath() string {
+	if configPath := os.Getenv("CONFIG_FILE_PATH"); configPath != "" {
+		return configPath
+	}
+	return ".env"
+}
+
+func (c *Config) GetMetricsPort() string {
+	if c.MetricsPort == "" {
+		return "9090"
+	}
+	return c.MetricsPort
+}
+
 func (c *Config) GetDSN() string {
 	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable",
 		c.DBHost, c.DBUser, c.DBPassword, c.DBName, c.DBPort)
@@ -109,14 +293,14 @@ func (c *Config) GetCORSAllowedMethods() []string {
 
 func (c *Config) GetCORSAllowedHeaders() []string {
 	if c.CORSAllowedHeaders == "" {
-		return []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "accept", "origin", "Cache-Control", "X-Requested-With"}
+		return []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "accept", "origin", "Cache-Control", "X-Requested-With", "traceparent", "tracestate", "X-Request-ID", "Idempotency-Key", "If-Match", "If-None-Match"}
 	}
 	return strings.Split(c.CORSAllowedHeaders, ",")
 }
 
 func (c *Config) GetCORSExposeHeaders() []string {
 	if c.CORSExposeHeaders == "" {
-		return []string{"Content-Length"}
+		return []string{"Content-Length", "X-Request-ID", "Idempotent-Replayed", "ETag"}
 	}
 	return strings.Split(c.CORSExposeHeaders, ",")
 }
@@ -125,7 +309,323 @@ func (c *Config) GetCORSMaxAge() time.Duration {
 	if c.CORSMaxAge == 0 {
 		return 12 * time.Hour
 	}
-	return time.Duration(c.CORSMaxAge) * time.Hour
+	return c.CORSMaxAge
+}
+
+func (c *Config) GetLogLevel() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+func (c *Config) GetTracingExporter() string {
+	if c.TracingExporter == "" {
+		return "none"
+	}
+	return strings.ToLower(c.TracingExporter)
+}
+
+func (c *Config) GetTracingServiceName() string {
+	if c.TracingServiceName == "" {
+		return "fitbyte"
+	}
+	return c.TracingServiceName
+}
+
+// GetTracingSampleRatio returns the share of new traces to record. Requests
+// arriving with a sampled parent are always recorded.
+func (c *Config) GetTracingSampleRatio() float64 {
+	if c.TracingSampleRatio == 0 {
+		return 1
+	}
+	return c.TracingSampleRatio
+}
+
+func (c *Config) GetPasswordHashAlgorithm() string {
+	if c.PasswordHashAlgorithm == "" {
+		return "bcrypt"
+	}
+	return strings.ToLower(c.PasswordHashAlgorithm)
+}
+
+func (c *Config) GetBcryptCost() int {
+	if c.BcryptCost == 0 {
+		return 12
+	}
+	return c.BcryptCost
+}
+
+func (c *Config) GetArgon2MemoryKB() uint32 {
+	if c.Argon2MemoryKB == 0 {
+		return 64 * 1024
+	}
+	return uint32(c.Argon2MemoryKB)
+}
+
+func (c *Config) GetArgon2Iterations() uint32 {
+	if c.Argon2Iterations == 0 {
+		return 3
+	}
+	return uint32(c.Argon2Iterations)
+}
+
+func (c *Config) GetArgon2Parallelism() uint8 {
+	if c.Argon2Parallelism == 0 {
+		return 2
+	}
+	return uint8(c.Argon2Parallelism)
+}
+
+func (c *Config) GetMinIOPublicEndpoint() string {
+	if c.MinIOPublicEndpoint == "" {
+		return c.MinIOEndpoint
+	}
+	return c.MinIOPublicEndpoint
+}
+
+func (c *Config) GetMinIOPresignExpiry() time.Duration {
+	if c.MinIOPresignExpiry == 0 {
+		return 15 * time.Minute
+	}
+	return c.MinIOPresignExpiry
+}
+
+func (c *Config) GetImageURIAllowedSchemes() []string {
+	if c.ImageURIAllowedSchemes == "" {
+		return []string{"http", "https"}
+	}
+	return strings.Split(c.ImageURIAllowedSchemes, ",")
+}
+
+// GetImageURIAllowedHosts defaults to the host of presigned URLs, any other
+// host would only be rejected later as not uploaded by the user
+func (c *Config) GetImageURIAllowedHosts() []string {
+	if c.ImageURIAllowedHosts == "" {
+		return []string{c.GetMinIOPublicEndpoint()}
+	}
+	return strings.Split(c.ImageURIAllowedHosts, ",")
+}
+
+func (c *Config) GetCacheLocalMaxEntries() int {
+	if c.CacheLocalMaxEntries == 0 {
+		return 10000
+	}
+	return c.CacheLocalMaxEntries
+}
+
+func (c *Config) GetCacheLocalTTL() time.Duration {
+	if c.CacheLocalTTL == 0 {
+		return 30 * time.Second
+	}
+	return c.CacheLocalTTL
+}
+
+func (c *Config) GetCacheBreakerThreshold() int {
+	if c.CacheBreakerThreshold == 0 {
+		return 5
+	}
+	return c.CacheBreakerThreshold
+}
+
+func (c *Config) GetCacheBreakerCooldown() time.Duration {
+	if c.CacheBreakerCooldown == 0 {
+		return 30 * time.Second
+	}
+	return c.CacheBreakerCooldown
+}
+
+func (c *Config) GetRateLimitPerSecond() int {
+	if c.RateLimitPerSecond == 0 {
+		return 20
+	}
+	return c.RateLimitPerSecond
+}
+
+func (c *Config) GetRateLimitBurst() int {
+	if c.RateLimitBurst == 0 {
+		return 100
+	}
+	return c.RateLimitBurst
+}
+
+func (c *Config) GetRateLimitAuthPerMinute() int {
+	if c.RateLimitAuthPerMinute == 0 {
+		return 10
+	}
+	return c.RateLimitAuthPerMinute
+}
+
+func (c *Config) GetRateLimitAuthBurst() int {
+	if c.RateLimitAuthBurst == 0 {
+		return 5
+	}
+	return c.RateLimitAuthBurst
+}
+
+func (c *Config) GetRateLimitUserPerSecond() int {
+	if c.RateLimitUserPerSecond == 0 {
+		return 20
+	}
+	return c.RateLimitUserPerSecond
+}
+
+func (c *Config) GetRateLimitUserBurst() int {
+	if c.RateLimitUserBurst == 0 {
+		return 40
+	}
+	return c.RateLimitUserBurst
+}
+
+func (c *Config) GetLoginMaxAttemptsPerEmail() int {
+	if c.LoginMaxAttemptsPerEmail == 0 {
+		return 5
+	}
+	return c.LoginMaxAttemptsPerEmail
+}
+
+func (c *Config) GetLoginMaxAttemptsPerIP() int {
+	if c.LoginMaxAttemptsPerIP == 0 {
+		return 20
+	}
+	return c.LoginMaxAttemptsPerIP
+}
+
+func (c *Config) GetLoginAttemptWindow() time.Duration {
+	if c.LoginAttemptWindow == 0 {
+		return 15 * time.Minute
+	}
+	return c.LoginAttemptWindow
+}
+
+func (c *Config) GetLoginLockoutBase() time.Duration {
+	if c.LoginLockoutBase == 0 {
+		return 30 * time.Second
+	}
+	return c.LoginLockoutBase
+}
+
+func (c *Config) GetLoginLockoutMax() time.Duration {
+	if c.LoginLockoutMax == 0 {
+		return 60 * time.Minute
+	}
+	return c.LoginLockoutMax
+}
+
+func (c *Config) GetIdempotencyKeyTTL() time.Duration {
+	if c.IdempotencyKeyTTL == 0 {
+		return 24 * time.Hour
+	}
+	return c.IdempotencyKeyTTL
+}
+
+func (c *Config) GetTrashRetentionDays() int {
+	if c.TrashRetentionDays == 0 {
+		return 30
+	}
+	return c.TrashRetentionDays
+}
+
+func (c *Config) GetTrashPurgeInterval() time.Duration {
+	if c.TrashPurgeInterval == 0 {
+		return time.Hour
+	}
+	return c.TrashPurgeInterval
+}
+
+func (c *Config) GetOutboxPollInterval() time.Duration {
+	if c.OutboxPollInterval == 0 {
+		return time.Second
+	}
+	return c.OutboxPollInterval
+}
+
+func (c *Config) GetOutboxRetryBackoff() time.Duration {
+	if c.OutboxRetryBackoff == 0 {
+		return 5 * time.Second
+	}
+	return c.OutboxRetryBackoff
+}
+
+func (c *Config) GetOutboxMaxAttempts() int {
+	if c.OutboxMaxAttempts == 0 {
+		return 10
+	}
+	return c.OutboxMaxAttempts
+}
+
+func (c *Config) GetOutboxRetentionDays() int {
+	if c.OutboxRetentionDays == 0 {
+		return 7
+	}
+	return c.OutboxRetentionDays
+}
+
+func (c *Config) GetAdminUserIDs() []string {
+	var ids []string
+	for _, id := range strings.Split(c.AdminUserIDs, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (c *Config) GetTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(c.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+func (c *Config) GetHealthCheckTimeout() time.Duration {
+	if c.HealthCheckTimeout == 0 {
+		return 2 * time.Second
+	}
+	return c.HealthCheckTimeout
+}
+
+func (c *Config) GetHealthCheckCacheTTL() time.Duration {
+	if c.HealthCheckCacheTTL == 0 {
+		return 5 * time.Second
+	}
+	return c.HealthCheckCacheTTL
+}
+
+// ServerWriteTimeout bounds writing a response. Requests must time out before
+// it so the client still receives the error.
+const ServerWriteTimeout = 15 * time.Second
+
+func (c *Config) GetRequestTimeout() time.Duration {
+	if c.RequestTimeout == 0 {
+		return 10 * time.Second
+	}
+	return c.RequestTimeout
+}
+
+func (c *Config) GetDBQueryTimeout() time.Duration {
+	if c.DBQueryTimeout == 0 {
+		return 5 * time.Second
+	}
+	return c.DBQueryTimeout
+}
+
+func (c *Config) GetCacheTimeout() time.Duration {
+	if c.CacheTimeout == 0 {
+		return 500 * time.Millisecond
+	}
+	return c.CacheTimeout
+}
+
+func (c *Config) GetStorageTimeout() time.Duration {
+	if c.StorageTimeout == 0 {
+		return 10 * time.Second
+	}
+	return c.StorageTimeout
 }
 
 func (c *Config) GetDBMaxIdleConns() int {
@@ -146,12 +646,12 @@ func (c *Config) GetDBConnMaxLifetime() time.Duration {
 	if c.DBConnMaxLifetime == 0 {
 		return 60 * time.Minute
 	}
-	return time.Duration(c.DBConnMaxLifetime) * time.Minute
+	return c.DBConnMaxLifetime
 }
 
 func (c *Config) GetDBConnMaxIdleTime() time.Duration {
 	if c.DBConnMaxIdleTime == 0 {
 		return 15 * time.Minute
 	}
-	return time.Duration(c.DBConnMaxIdleTime) * time.Minute
+	return c.DBConnMaxIdleTime
 }
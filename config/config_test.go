@@ -0,0 +1,105 @@
+package config_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fikrialwan/FitByte/config"
+)
+
+func TestLoadBareCORSMaxAge(t *testing.T) {
+	t.Setenv("CONFIG_FILE_PATH", filepath.Join(t.TempDir(), "missing.env"))
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		// The hours CORS_MAX_AGE was counted in before it became a duration
+		{value: "24", want: 24 * time.Hour},
+		{value: "90m", want: 90 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("CORS_MAX_AGE", tt.value)
+
+			cfg, err := config.Load()
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if got := cfg.GetCORSMaxAge(); got != tt.want {
+				t.Errorf("GetCORSMaxAge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadLegacyDurationKeys(t *testing.T) {
+	t.Setenv("CONFIG_FILE_PATH", filepath.Join(t.TempDir(), "missing.env"))
+
+	t.Run("read into the replacement", func(t *testing.T) {
+		t.Setenv("DB_CONN_MAX_LIFETIME_MINUTES", "45")
+		t.Setenv("DB_CONN_MAX_IDLE_TIME_MINUTES", "3")
+		t.Setenv("LOGIN_LOCKOUT_BASE_SECONDS", "20")
+
+		cfg, err := config.Load()
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if got := cfg.GetDBConnMaxLifetime(); got != 45*time.Minute {
+			t.Errorf("GetDBConnMaxLifetime() = %v, want 45m", got)
+		}
+		if got := cfg.GetDBConnMaxIdleTime(); got != 3*time.Minute {
+			t.Errorf("GetDBConnMaxIdleTime() = %v, want 3m", got)
+		}
+		if got := cfg.GetLoginLockoutBase(); got != 20*time.Second {
+			t.Errorf("GetLoginLockoutBase() = %v, want 20s", got)
+		}
+	})
+
+	t.Run("replacement wins", func(t *testing.T) {
+		t.Setenv("DB_CONN_MAX_LIFETIME_MINUTES", "45")
+		t.Setenv("DB_CONN_MAX_LIFETIME", "10m")
+
+		cfg, err := config.Load()
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if got := cfg.GetDBConnMaxLifetime(); got != 10*time.Minute {
+			t.Errorf("GetDBConnMaxLifetime() = %v, want 10m", got)
+		}
+	})
+
+	t.Run("not a number", func(t *testing.T) {
+		t.Setenv("DB_CONN_MAX_LIFETIME_MINUTES", "half an hour")
+
+		cfg, err := config.Load()
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		err = cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "DB_CONN_MAX_LIFETIME_MINUTES") {
+			t.Errorf("Validate() = %v, want a DB_CONN_MAX_LIFETIME_MINUTES problem", err)
+		}
+	})
+}
+
+func TestReloaderWatchReturnsWhenDone(t *testing.T) {
+	t.Setenv("CONFIG_FILE_PATH", filepath.Join(t.TempDir(), "missing.env"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		config.NewReloader(&config.Config{}).Watch(ctx)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Watch still running after its context was cancelled")
+	}
+}
@@ -1,35 +1,55 @@
 package config
 
 import (
-	"log"
+	"fmt"
 
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/metrics"
+	"github.com/fikrialwan/FitByte/internal/tracing"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func RunExtension(db *gorm.DB) {
+func RunExtension(db *gorm.DB) error {
 	// extension to generate uuid
-	db.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\";")
+	return db.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\";").Error
 }
 
-func InitDb(cfg *Config) *gorm.DB {
+// InitDb connects to Postgres. Timestamps GORM fills in come from clk.
+func InitDb(cfg *Config, clk clock.Clock) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.New(postgres.Config{
 		DSN: cfg.GetDSN(),
-	}), &gorm.Config{})
-
+	}), &gorm.Config{
+		// Unique violations become gorm.ErrDuplicatedKey
+		TranslateError: true,
+		NowFunc:        clk.Now,
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return nil, fmt.Errorf("connecting to database: %w", err)
 	}
 
 	sqlDB, err := db.DB()
-	if err == nil {
-		sqlDB.SetMaxIdleConns(cfg.GetDBMaxIdleConns())
-		sqlDB.SetMaxOpenConns(cfg.GetDBMaxOpenConns())
-		sqlDB.SetConnMaxLifetime(cfg.GetDBConnMaxLifetime())
-		sqlDB.SetConnMaxIdleTime(cfg.GetDBConnMaxIdleTime())
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxIdleConns(cfg.GetDBMaxIdleConns())
+	sqlDB.SetMaxOpenConns(cfg.GetDBMaxOpenConns())
+	sqlDB.SetConnMaxLifetime(cfg.GetDBConnMaxLifetime())
+	sqlDB.SetConnMaxIdleTime(cfg.GetDBConnMaxIdleTime())
+
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("registering database metrics: %w", err)
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("registering database tracing: %w", err)
 	}
 
-	RunExtension(db)
+	if err := RunExtension(db); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("creating uuid-ossp extension: %w", err)
+	}
 
-	return db
+	return db, nil
 }
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+const redactedValue = "********"
+
+// PrintRedacted writes the effective configuration as KEY=value lines in
+// declaration order. Fields tagged secret:"true" are masked, empty secrets
+// are left empty so operators can still tell they are missing.
+func (c *Config) PrintRedacted(w io.Writer) error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("koanf")
+		if key == "" || !field.IsExported() {
+			continue
+		}
+
+		value := fmt.Sprint(v.Field(i).Interface())
+		if field.Tag.Get("secret") == "true" && value != "" {
+			value = redactedValue
+		}
+
+		if _, err := fmt.Fprintf(w, "%s=%s\n", key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
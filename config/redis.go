@@ -0,0 +1,34 @@
+package config
+
+import (
+	"log/slog"
+
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+func InitRedis(cfg *Config) *redis.Client {
+	redisAddr := cfg.RedisAddr
+	if redisAddr == "" {
+		redisAddr = "localhost:6379"
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:         redisAddr,
+		Password:     cfg.RedisPassword,
+		DB:           0,
+		PoolSize:     50,
+		MinIdleConns: 10,
+		MaxRetries:   3,
+		// Honour context deadlines, see CACHE_TIMEOUT
+		ContextTimeoutEnabled: true,
+	})
+
+	// Commands become child spans of the context they are called with. Their
+	// arguments are left out since keys may hold emails and tokens.
+	if err := redisotel.InstrumentTracing(client, redisotel.WithDBStatement(false)); err != nil {
+		slog.Warn("failed to enable redis tracing", "error", err)
+	}
+
+	return client
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/knadh/koanf/providers/file"
+)
+
+// reloadableKeys are the settings that may change without a restart, matched
+// by key prefix. Everything else keeps its startup value until the process is
+// restarted.
+var reloadableKeys = []string{"CORS_", "RATE_LIMIT_", "LOG_LEVEL", "ADMIN_USER_IDS"}
+
+// restartOnlyKeys are excluded from reloadableKeys because the component
+// they configure is built once at startup
+var restartOnlyKeys = []string{"RATE_LIMIT_DRIVER"}
+
+func isReloadable(key string) bool {
+	for _, k := range restartOnlyKeys {
+		if key == k {
+			return false
+		}
+	}
+	for _, prefix := range reloadableKeys {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reloader holds the live configuration and re-reads it when the config file
+// changes or the process receives SIGHUP. Only reloadable settings are taken
+// from the new configuration, and a reload that fails validation is rejected
+// so the previous values stay in effect.
+type Reloader struct {
+	current     atomic.Pointer[Config]
+	mu          sync.Mutex
+	subscribers []func(*Config)
+}
+
+func NewReloader(cfg *Config) *Reloader {
+	r := &Reloader{}
+	r.current.Store(cfg)
+	return r
+}
+
+// Current returns the configuration in effect. The returned value must not be
+// modified.
+func (r *Reloader) Current() *Config {
+	return r.current.Load()
+}
+
+// Subscribe registers fn to be called with every configuration accepted by a
+// reload. Subscribers run in registration order on the reloading goroutine.
+func (r *Reloader) Subscribe(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Watch reloads on config file changes and SIGHUP, returning once ctx is
+// done and the watch is released
+func (r *Reloader) Watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	changed := make(chan struct{}, 1)
+	provider, err := r.watchFile(changed)
+	if err != nil {
+		slog.Warn("config file watch unavailable, reload with SIGHUP instead", "path", FilePath(), "error", err)
+	}
+
+	defer signal.Stop(hup)
+	if provider != nil {
+		defer provider.Unwatch()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("received SIGHUP, reloading configuration")
+			r.Reload()
+		case <-changed:
+			slog.Info("config file changed, reloading configuration", "path", FilePath())
+			r.Reload()
+		}
+	}
+}
+
+func (r *Reloader) watchFile(changed chan<- struct{}) (*file.File, error) {
+	// fsnotify watches the parent directory, which has to be explicit
+	path, err := filepath.Abs(FilePath())
+	if err != nil {
+		return nil, err
+	}
+
+	provider := file.Provider(path)
+	err = provider.Watch(func(_ interface{}, err error) {
+		if err != nil {
+			slog.Warn("config file watch stopped, reload with SIGHUP instead", "path", path, "error", err)
+			return
+		}
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// Reload reads the configuration again and applies its reloadable settings.
+// It reports whether the new configuration was accepted.
+func (r *Reloader) Reload() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, err := Load()
+	if err != nil {
+		slog.Error("configuration reload rejected", "error", err)
+		return false
+	}
+
+	current := r.current.Load()
+	next := *current
+	var applied, ignored []string
+
+	currentValue := reflect.ValueOf(current).Elem()
+	loadedValue := reflect.ValueOf(loaded).Elem()
+	nextValue := reflect.ValueOf(&next).Elem()
+	for i := 0; i < currentValue.NumField(); i++ {
+		key := currentValue.Type().Field(i).Tag.Get("koanf")
+		if key == "" || reflect.DeepEqual(currentValue.Field(i).Interface(), loadedValue.Field(i).Interface()) {
+			continue
+		}
+		if !isReloadable(key) {
+			ignored = append(ignored, key)
+			continue
+		}
+		nextValue.Field(i).Set(loadedValue.Field(i))
+		applied = append(applied, key)
+	}
+
+	if len(ignored) > 0 {
+		slog.Warn("configuration changes require a restart to take effect", "keys", ignored)
+	}
+	if len(applied) == 0 {
+		slog.Info("configuration reloaded, nothing to apply")
+		return true
+	}
+
+	if err := next.Validate(); err != nil {
+		slog.Error("configuration reload rejected", "keys", applied, "error", err)
+		return false
+	}
+
+	r.current.Store(&next)
+	for _, fn := range r.subscribers {
+		fn(&next)
+	}
+
+	slog.Info("configuration reloaded", "keys", applied)
+	return true
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// weakJWTSecrets are placeholder values that must never reach production
+var weakJWTSecrets = []string{"template", "secret", "supersecret", "changeme", "your_super_secret_jwt_key"}
+
+const minProductionJWTSecretLength = 32
+
+// ValidationError lists every configuration problem found
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the configuration and reports all problems at once
+func (c *Config) Validate() error {
+	v := &validation{}
+
+	for _, key := range c.invalidLegacyKeys {
+		legacy := legacyDurationKeys[key]
+		v.addf("%s is deprecated and must be a whole number, use %s instead", key, legacy.key)
+	}
+
+	v.required("DB_HOST", c.DBHost)
+	v.required("DB_USER", c.DBUser)
+	v.required("DB_NAME", c.DBName)
+	v.between("DB_PORT", c.DBPort, 1, 65535)
+	if c.AppPort != "" {
+		if port, err := strconv.Atoi(c.AppPort); err != nil || port < 1 || port > 65535 {
+			v.addf("APP_PORT must be a port number, got '%s'", c.AppPort)
+		}
+	}
+	if c.MetricsPort != "" {
+		if port, err := strconv.Atoi(c.MetricsPort); err != nil || port < 1 || port > 65535 {
+			v.addf("METRICS_PORT must be a port number, got '%s'", c.MetricsPort)
+		}
+	}
+	if c.GetMetricsPort() == c.AppPort {
+		v.addf("METRICS_PORT must differ from APP_PORT, both are %s", c.AppPort)
+	}
+
+	if c.LogLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+			v.addf("LOG_LEVEL must be debug, info, warn or error, got '%s'", c.LogLevel)
+		}
+	}
+
+	v.oneOf("TRACING_EXPORTER", c.TracingExporter, "", "otlp", "stdout", "none")
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		v.addf("TRACING_SAMPLE_RATIO must be between 0 and 1, got %g", c.TracingSampleRatio)
+	}
+
+	v.required("JWT_SECRET", c.JWTSecret)
+	if c.IsProduction() && c.JWTSecret != "" {
+		if len(c.JWTSecret) < minProductionJWTSecretLength {
+			v.addf("JWT_SECRET must be at least %d characters in production", minProductionJWTSecretLength)
+		}
+		for _, weak := range weakJWTSecrets {
+			if strings.EqualFold(strings.TrimSpace(c.JWTSecret), weak) {
+				v.addf("JWT_SECRET is a placeholder value and can't be used in production")
+				break
+			}
+		}
+	}
+
+	switch c.GetPasswordHashAlgorithm() {
+	case "bcrypt", "argon2id":
+	default:
+		v.addf("PASSWORD_HASH_ALGORITHM must be bcrypt or argon2id, got '%s'", c.PasswordHashAlgorithm)
+	}
+	if c.BcryptCost != 0 {
+		v.between("BCRYPT_COST", c.BcryptCost, 4, 31)
+	}
+	if c.IsProduction() && c.GetPasswordHashAlgorithm() == "bcrypt" && c.GetBcryptCost() < 10 {
+		v.addf("BCRYPT_COST must be at least 10 in production")
+	}
+	v.nonNegative("ARGON2_MEMORY_KB", c.Argon2MemoryKB)
+	v.nonNegative("ARGON2_ITERATIONS", c.Argon2Iterations)
+	v.between("ARGON2_PARALLELISM", c.Argon2Parallelism, 0, 255)
+
+	v.required("MINIO_ENDPOINT", c.MinIOEndpoint)
+	v.required("MINIO_ACCESS_KEY", c.MinIOAccessKey)
+	v.required("MINIO_SECRET_KEY", c.MinIOSecretKey)
+	v.required("MINIO_BUCKET", c.MinIOBucket)
+	v.nonNegative("MINIO_PRESIGN_EXPIRY", int(c.MinIOPresignExpiry))
+	if c.MinIOPresignExpiry > 7*24*time.Hour {
+		v.addf("MINIO_PRESIGN_EXPIRY can't exceed 7 days")
+	}
+
+	for _, scheme := range c.GetImageURIAllowedSchemes() {
+		v.oneOf("IMAGE_URI_ALLOWED_SCHEMES", strings.TrimSpace(scheme), "http", "https")
+	}
+
+	v.oneOf("CACHE_DRIVER", c.CacheDriver, "", "redis", "memory", "tiered")
+	v.nonNegative("CACHE_LOCAL_MAX_ENTRIES", c.CacheLocalMaxEntries)
+	v.nonNegative("CACHE_LOCAL_TTL", int(c.CacheLocalTTL))
+	v.nonNegative("CACHE_BREAKER_THRESHOLD", c.CacheBreakerThreshold)
+	v.nonNegative("CACHE_BREAKER_COOLDOWN", int(c.CacheBreakerCooldown))
+	v.nonNegative("IDEMPOTENCY_KEY_TTL", int(c.IdempotencyKeyTTL))
+	v.nonNegative("TRASH_RETENTION_DAYS", c.TrashRetentionDays)
+	v.nonNegative("TRASH_PURGE_INTERVAL", int(c.TrashPurgeInterval))
+	v.nonNegative("OUTBOX_POLL_INTERVAL", int(c.OutboxPollInterval))
+	v.nonNegative("OUTBOX_RETRY_BACKOFF", int(c.OutboxRetryBackoff))
+	v.nonNegative("OUTBOX_MAX_ATTEMPTS", c.OutboxMaxAttempts)
+	v.nonNegative("OUTBOX_RETENTION_DAYS", c.OutboxRetentionDays)
+	for _, id := range c.GetAdminUserIDs() {
+		if _, err := uuid.Parse(id); err != nil {
+			v.addf("ADMIN_USER_IDS must list user UUIDs, %q isn't one", id)
+		}
+	}
+	for _, proxy := range c.GetTrustedProxies() {
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			v.addf("TRUSTED_PROXIES must list IPs or CIDRs, %q isn't one", proxy)
+		}
+	}
+
+	v.nonNegative("CORS_MAX_AGE", int(c.CORSMaxAge))
+	if c.CORSAllowCredentials {
+		for _, origin := range c.GetCORSAllowedOrigins() {
+			if strings.TrimSpace(origin) == "*" {
+				v.addf("CORS_ALLOWED_ORIGINS can't contain '*' when CORS_ALLOW_CREDENTIALS is true")
+			}
+		}
+	}
+
+	v.oneOf("RATE_LIMIT_DRIVER", c.RateLimitDriver, "", "redis", "memory")
+	v.nonNegative("RATE_LIMIT_PER_SECOND", c.RateLimitPerSecond)
+	v.nonNegative("RATE_LIMIT_BURST", c.RateLimitBurst)
+	v.nonNegative("RATE_LIMIT_AUTH_PER_MINUTE", c.RateLimitAuthPerMinute)
+	v.nonNegative("RATE_LIMIT_AUTH_BURST", c.RateLimitAuthBurst)
+	v.nonNegative("RATE_LIMIT_USER_PER_SECOND", c.RateLimitUserPerSecond)
+	v.nonNegative("RATE_LIMIT_USER_BURST", c.RateLimitUserBurst)
+
+	v.nonNegative("LOGIN_MAX_ATTEMPTS_PER_EMAIL", c.LoginMaxAttemptsPerEmail)
+	v.nonNegative("LOGIN_MAX_ATTEMPTS_PER_IP", c.LoginMaxAttemptsPerIP)
+	v.nonNegative("LOGIN_ATTEMPT_WINDOW", int(c.LoginAttemptWindow))
+	v.nonNegative("LOGIN_LOCKOUT_BASE", int(c.LoginLockoutBase))
+	v.nonNegative("LOGIN_LOCKOUT_MAX", int(c.LoginLockoutMax))
+	if c.GetLoginLockoutBase() > c.GetLoginLockoutMax() {
+		v.addf("LOGIN_LOCKOUT_BASE can't be greater than LOGIN_LOCKOUT_MAX")
+	}
+
+	v.nonNegative("HEALTH_CHECK_TIMEOUT", int(c.HealthCheckTimeout))
+	v.nonNegative("HEALTH_CHECK_CACHE_TTL", int(c.HealthCheckCacheTTL))
+
+	v.nonNegative("REQUEST_TIMEOUT", int(c.RequestTimeout))
+	v.nonNegative("DB_QUERY_TIMEOUT", int(c.DBQueryTimeout))
+	v.nonNegative("CACHE_TIMEOUT", int(c.CacheTimeout))
+	v.nonNegative("STORAGE_TIMEOUT", int(c.StorageTimeout))
+	if c.GetRequestTimeout() >= ServerWriteTimeout {
+		v.addf("REQUEST_TIMEOUT must be less than the %s server write timeout", ServerWriteTimeout)
+	}
+
+	v.nonNegative("DB_MAX_IDLE_CONNS", c.DBMaxIdleConns)
+	v.nonNegative("DB_MAX_OPEN_CONNS", c.DBMaxOpenConns)
+	v.nonNegative("DB_CONN_MAX_LIFETIME", int(c.DBConnMaxLifetime))
+	v.nonNegative("DB_CONN_MAX_IDLE_TIME", int(c.DBConnMaxIdleTime))
+	if c.GetDBMaxIdleConns() > c.GetDBMaxOpenConns() {
+		v.addf("DB_MAX_IDLE_CONNS can't be greater than DB_MAX_OPEN_CONNS")
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+type validation struct {
+	problems []string
+}
+
+func (v *validation) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validation) required(key, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf("%s is required", key)
+	}
+}
+
+func (v *validation) nonNegative(key string, value int) {
+	if value < 0 {
+		v.addf("%s can't be negative", key)
+	}
+}
+
+func (v *validation) between(key string, value, min, max int) {
+	if value < min || value > max {
+		v.addf("%s must be between %d and %d, got %d", key, min, max, value)
+	}
+}
+
+func (v *validation) oneOf(key, value string, allowed ...string) {
+	var named []string
+	for _, a := range allowed {
+		if strings.EqualFold(value, a) {
+			return
+		}
+		if a != "" {
+			named = append(named, a)
+		}
+	}
+	v.addf("%s must be one of %s, got '%s'", key, strings.Join(named, ", "), value)
+}
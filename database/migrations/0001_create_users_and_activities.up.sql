@@ -0,0 +1,37 @@
+-- Baseline schema. IF NOT EXISTS keeps it a no-op on databases created by the
+-- previous GORM AutoMigrate setup.
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS users (
+    id          uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name        varchar(65),
+    email       varchar(255),
+    password    text,
+    preference  varchar(10),
+    weight_unit varchar(5),
+    height_unit varchar(5),
+    weight      bigint,
+    height      bigint,
+    image_key   text,
+    created_at  timestamptz,
+    updated_at  timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS activities (
+    id                  uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    activity_type       varchar(15),
+    done_at             timestamptz,
+    duration_in_minutes bigint,
+    calories_burned     bigint,
+    user_id             uuid,
+    created_at          timestamptz,
+    updated_at          timestamptz,
+    CONSTRAINT fk_activities_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_activities_activity_type ON activities (activity_type);
+CREATE INDEX IF NOT EXISTS idx_activities_done_at ON activities (done_at);
+CREATE INDEX IF NOT EXISTS idx_activities_calories_burned ON activities (calories_burned);
+CREATE INDEX IF NOT EXISTS idx_activities_user_id ON activities (user_id);
@@ -0,0 +1,5 @@
+-- image_uri still holds every URL that was converted, only images set since
+-- are restored from their key
+ALTER TABLE users ADD COLUMN IF NOT EXISTS image_uri text;
+UPDATE users SET image_uri = image_key WHERE COALESCE(image_uri, '') = '' AND COALESCE(image_key, '') <> '';
+ALTER TABLE users DROP COLUMN IF EXISTS image_key;
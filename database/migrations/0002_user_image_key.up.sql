@@ -0,0 +1,33 @@
+-- Profile images used to be stored as public URLs
+-- ("http(s)://host/bucket/key"), keep only the object key of the ones
+-- pointing at our own storage. The hosts and bucket come from the migrate
+-- command (fitbyte.storage_hosts and fitbyte.storage_bucket). When image_uri
+-- exists the migration fails without them rather than convert nothing and
+-- still be recorded as applied.
+--
+-- image_uri is kept, external URLs included, so nothing is lost until the
+-- converted keys have been checked.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS image_key text;
+
+DO $$
+DECLARE
+    storage_hosts  text[] := string_to_array(lower(coalesce(current_setting('fitbyte.storage_hosts', true), '')), ',');
+    storage_bucket text   := coalesce(current_setting('fitbyte.storage_bucket', true), '');
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'image_uri'
+    ) THEN
+        RETURN;
+    END IF;
+
+    IF storage_bucket = '' OR array_remove(storage_hosts, '') = '{}' THEN
+        RAISE EXCEPTION 'fitbyte.storage_hosts and fitbyte.storage_bucket must be set to convert users.image_uri';
+    END IF;
+
+    UPDATE users
+    SET image_key = substring(split_part(image_uri, '?', 1) FROM '^https?://[^/]+/[^/]+/(.+)$')
+    WHERE COALESCE(image_key, '') = ''
+      AND lower(substring(image_uri FROM '^https?://([^/?#]+)/')) = ANY (storage_hosts)
+      AND substring(image_uri FROM '^https?://[^/]+/([^/?#]+)/') = storage_bucket;
+END $$;
@@ -0,0 +1,4 @@
+-- Versions back the ETags of users and activities, every update increments
+-- them so concurrent edits can be detected
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE activities ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+-- Trashed activities would reappear once the column is gone
+DELETE FROM activities WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_activities_deleted_at;
+ALTER TABLE activities DROP COLUMN IF EXISTS deleted_at;
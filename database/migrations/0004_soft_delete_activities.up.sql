@@ -0,0 +1,5 @@
+-- Deleted activities stay in the trash until restored or purged by the
+-- retention job
+ALTER TABLE activities ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_activities_deleted_at ON activities (deleted_at);
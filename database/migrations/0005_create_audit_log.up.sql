@@ -0,0 +1,29 @@
+-- Append-only history of changes to activities and profiles. Entries outlive
+-- what they describe, so there are no foreign keys.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id          uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    actor_id    uuid NOT NULL,
+    owner_id    uuid NOT NULL,
+    action      varchar(20) NOT NULL,
+    entity_type varchar(20) NOT NULL,
+    entity_id   uuid NOT NULL,
+    changes     jsonb NOT NULL DEFAULT '{}',
+    request_id  varchar(128),
+    created_at  timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log (entity_type, entity_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_owner ON audit_log (owner_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,22 @@
+-- Domain events written in the same transaction as the change they describe,
+-- delivered to the in-process handlers by the outbox dispatcher
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id              uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    type            varchar(50) NOT NULL,
+    aggregate_id    uuid NOT NULL,
+    user_id         uuid NOT NULL,
+    payload         jsonb NOT NULL,
+    request_id      varchar(128),
+    attempts        integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL,
+    last_error      text,
+    published_at    timestamptz,
+    failed_at       timestamptz,
+    created_at      timestamptz NOT NULL
+);
+
+-- Only pending events are polled
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (next_attempt_at, created_at)
+    WHERE published_at IS NULL AND failed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events (published_at)
+    WHERE published_at IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Each claim of an event gets a new token, the outcome of a delivery is only
+-- recorded by the dispatcher still holding it
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS claim_token uuid;
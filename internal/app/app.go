@@ -0,0 +1,402 @@
+// Package app wires the API together. New builds every component from the
+// config unless it is passed in as an Option, Start serves it and Shutdown
+// releases what New acquired in reverse order:
+//
+//	a, err := app.New(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	return a.Run(ctx)
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/health"
+	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/fikrialwan/FitByte/internal/routes"
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/fikrialwan/FitByte/internal/tracing"
+	"github.com/fikrialwan/FitByte/middlewares"
+	"github.com/fikrialwan/FitByte/pkg/helpers"
+	"github.com/gin-gonic/gin"
+)
+
+// shutdownTimeout bounds Run's graceful shutdown
+const shutdownTimeout = 30 * time.Second
+
+// App is the API with the resources it runs on
+type App struct {
+	cfg      *config.Config
+	clock    clock.Clock
+	engine   *gin.Engine
+	server   *http.Server
+	listener net.Listener
+	serveErr chan error
+
+	// internal serves metrics on METRICS_PORT, apart from the public API
+	internal         *http.Server
+	internalListener net.Listener
+
+	// jobs run in the background from Start until Shutdown
+	jobs []job
+
+	mu    sync.Mutex
+	hooks []hook
+
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+type hook struct {
+	name string
+	fn   func(context.Context) error
+}
+
+type job struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+// New builds the API from cfg. When a component fails to build, the ones
+// already acquired are released before the error is returned.
+func New(cfg *config.Config, opts ...Option) (_ *App, err error) {
+	o := options{clock: clock.System}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	a := &App{cfg: cfg, clock: o.clock, listener: o.listener, internalListener: o.internalListener}
+	defer func() {
+		if err != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if cleanupErr := a.runHooks(ctx); cleanupErr != nil {
+				slog.Error("failed to release resources", "error", cleanupErr)
+			}
+		}
+	}()
+
+	// Registered first so it runs last, flushing the spans of the requests
+	// that finished during shutdown
+	shutdownTracing, err := tracing.Init(tracing.Options{
+		Exporter:     cfg.GetTracingExporter(),
+		ServiceName:  cfg.GetTracingServiceName(),
+		Environment:  cfg.AppEnv,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		OTLPInsecure: cfg.TracingOTLPInsecure,
+		SampleRatio:  cfg.GetTracingSampleRatio(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("setting up tracing: %w", err)
+	}
+	a.OnShutdown("tracing", shutdownTracing)
+
+	passwordHasher, err := helpers.NewPasswordHasher(cfg.GetPasswordHashAlgorithm(), cfg.GetBcryptCost(), helpers.Argon2Params{
+		Memory:      cfg.GetArgon2MemoryKB(),
+		Iterations:  cfg.GetArgon2Iterations(),
+		Parallelism: cfg.GetArgon2Parallelism(),
+		SaltLength:  16,
+		KeyLength:   32,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid password hashing config: %w", err)
+	}
+
+	deps, err := a.dependencies(o)
+	if err != nil {
+		return nil, err
+	}
+	deps.PasswordHasher = passwordHasher
+
+	dispatcher := service.NewEventDispatcher(cfg, deps.Repositories.Outbox, a.clock)
+	for _, h := range o.eventHandlers {
+		dispatcher.Subscribe(h.eventType, h.name, h.handler)
+	}
+	a.jobs = []job{
+		{"trash retention", service.NewTrashRetention(cfg, deps.Repositories.Activities, a.clock).Run},
+		{"event dispatcher", dispatcher.Run},
+	}
+
+	// CORS, rate limits and log level follow config file changes and SIGHUP
+	// from Start on
+	reloader := config.NewReloader(cfg)
+	if o.logLevel != nil {
+		reloader.Subscribe(func(cfg *config.Config) {
+			o.logLevel.Set(cfg.GetLogLevel())
+		})
+	}
+	a.jobs = append(a.jobs, job{"config watcher", reloader.Watch})
+
+	a.engine = gin.New()
+	// Without trusted proxies gin believes any X-Forwarded-For, and client IPs
+	// key rate limits and login lockouts
+	if err := a.engine.SetTrustedProxies(cfg.GetTrustedProxies()); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	routes.Register(a.engine, cfg, reloader, deps)
+
+	a.server = &http.Server{
+		Addr:         listenAddr(cfg),
+		Handler:      a.engine,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: config.ServerWriteTimeout,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	internalEngine := gin.New()
+	routes.RegisterInternal(internalEngine, deps)
+	a.internal = &http.Server{
+		Addr:         ":" + cfg.GetMetricsPort(),
+		Handler:      internalEngine,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+	}
+	return a, nil
+}
+
+// background runs fn until the App shuts down, the shutdown hook named name
+// waits for it to return
+func (a *App) background(name string, fn func(ctx context.Context)) {
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+	a.OnShutdown(name, func(shutdownCtx context.Context) error {
+		stop()
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+	})
+}
+
+// dependencies opens the backends that weren't passed in, registering the
+// release of each one it opens
+func (a *App) dependencies(o options) (routes.Dependencies, error) {
+	cfg := a.cfg
+
+	if o.repositories == nil {
+		if o.db == nil {
+			db, err := config.InitDb(cfg, a.clock)
+			if err != nil {
+				return routes.Dependencies{}, err
+			}
+			a.OnShutdown("database", func(context.Context) error {
+				sqlDB, err := db.DB()
+				if err != nil {
+					return err
+				}
+				return sqlDB.Close()
+			})
+			o.db = db
+		}
+		repos := repository.NewRepositories(o.db, cfg.GetDBQueryTimeout())
+		o.repositories = &repos
+	}
+
+	if o.redis == nil && (o.cacheService == nil || o.rateLimiter == nil) {
+		client := config.InitRedis(cfg)
+		a.OnShutdown("redis", func(context.Context) error {
+			return client.Close()
+		})
+		o.redis = client
+	}
+	if o.cacheService == nil {
+		o.cacheService = service.NewCacheService(cfg, o.redis)
+	}
+	if o.rateLimiter == nil {
+		o.rateLimiter = middlewares.NewRateLimiter(cfg, o.redis)
+	}
+
+	if o.fileService == nil {
+		fileService, err := service.NewFileService(cfg)
+		if err != nil {
+			return routes.Dependencies{}, err
+		}
+		o.fileService = fileService
+	}
+
+	if o.healthChecks == nil {
+		// Only the database is critical, the API degrades without the cache
+		// and storage but keeps serving
+		if o.db != nil {
+			o.healthChecks = append(o.healthChecks, health.DatabaseCheck(o.db))
+		}
+		o.healthChecks = append(o.healthChecks,
+			health.CacheCheck(o.cacheService),
+			health.StorageCheck(o.fileService),
+		)
+	}
+
+	return routes.Dependencies{
+		Repositories: *o.repositories,
+		CacheService: o.cacheService,
+		FileService:  o.fileService,
+		RateLimiter:  o.rateLimiter,
+		Clock:        a.clock,
+		HealthChecks: o.healthChecks,
+	}, nil
+}
+
+// listenAddr is APP_PORT on every interface
+func listenAddr(cfg *config.Config) string {
+	port := cfg.AppPort
+	if port == "" {
+		port = "8080"
+	}
+
+	if cfg.AppEnv == "develop" {
+		return "0.0.0.0:" + port
+	}
+	return ":" + port
+}
+
+// Handler serves the API, for embedding it or calling it without a listener
+func (a *App) Handler() http.Handler {
+	return a.engine
+}
+
+// OnShutdown registers fn to run on Shutdown. Hooks run in reverse order of
+// registration, so resources are released before the ones they depend on.
+func (a *App) OnShutdown(name string, fn func(context.Context) error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.hooks = append(a.hooks, hook{name: name, fn: fn})
+}
+
+// Start listens and serves the API and the internal routes, and starts the
+// trash retention and event dispatcher, all in the background. It returns
+// once both listeners are open, see Addr and InternalAddr.
+func (a *App) Start(ctx context.Context) error {
+	if a.listener == nil {
+		listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", a.server.Addr)
+		if err != nil {
+			return fmt.Errorf("listening on %s: %w", a.server.Addr, err)
+		}
+		a.listener = listener
+	}
+	if a.internalListener == nil {
+		listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", a.internal.Addr)
+		if err != nil {
+			a.listener.Close()
+			return fmt.Errorf("listening on %s: %w", a.internal.Addr, err)
+		}
+		a.internalListener = listener
+	}
+
+	// Either server failing stops Run
+	a.serveErr = make(chan error, 2)
+	var serving sync.WaitGroup
+	serve := func(name string, server *http.Server, listener net.Listener) {
+		serving.Add(1)
+		go func() {
+			defer serving.Done()
+			slog.Info("starting server", "server", name, "addr", listener.Addr().String())
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				a.serveErr <- fmt.Errorf("%s server: %w", name, err)
+			}
+		}()
+	}
+	serve("api", a.server, a.listener)
+	serve("internal", a.internal, a.internalListener)
+	go func() {
+		serving.Wait()
+		close(a.serveErr)
+	}()
+
+	// Stopped before the database closes, waiting for the purge or delivery
+	// in progress
+	for _, job := range a.jobs {
+		a.background(job.name, job.run)
+	}
+	return nil
+}
+
+// Addr is the address Start listens on, e.g. the port picked for ":0"
+func (a *App) Addr() string {
+	if a.listener == nil {
+		return ""
+	}
+	return a.listener.Addr().String()
+}
+
+// InternalAddr is the address Start serves metrics on
+func (a *App) InternalAddr() string {
+	if a.internalListener == nil {
+		return ""
+	}
+	return a.internalListener.Addr().String()
+}
+
+// Run starts the server and shuts it down once ctx is done, giving
+// outstanding requests shutdownTimeout to complete
+func (a *App) Run(ctx context.Context) error {
+	if err := a.Start(ctx); err != nil {
+		_ = a.Shutdown(context.Background())
+		return err
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-a.serveErr:
+	}
+	slog.Info("shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return errors.Join(serveErr, a.Shutdown(shutdownCtx))
+}
+
+// Shutdown stops accepting requests, waits for the outstanding ones until ctx
+// is done, then runs the shutdown hooks. Later calls return the first
+// result.
+func (a *App) Shutdown(ctx context.Context) error {
+	a.shutdownOnce.Do(func() {
+		var errs []error
+		if a.serveErr != nil {
+			if err := a.server.Shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("server forced to shutdown: %w", err))
+			} else {
+				slog.Info("server exited gracefully")
+			}
+			// Metrics stay up until the API is drained
+			if err := a.internal.Shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("internal server forced to shutdown: %w", err))
+			}
+		}
+		errs = append(errs, a.runHooks(ctx))
+		a.shutdownErr = errors.Join(errs...)
+	})
+	return a.shutdownErr
+}
+
+// runHooks runs every hook, most recent first, even when one fails
+func (a *App) runHooks(ctx context.Context) error {
+	a.mu.Lock()
+	hooks := a.hooks
+	a.hooks = nil
+	a.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hooks[i].name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
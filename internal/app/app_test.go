@@ -0,0 +1,264 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/app"
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/events"
+	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/fikrialwan/FitByte/middlewares"
+)
+
+// inMemory replaces every backend, so New opens no connections
+func inMemory(clk clock.Clock) []app.Option {
+	return []app.Option{
+		app.WithClock(clk),
+		app.WithRepositories(repository.NewMemoryRepositories(clk)),
+		app.WithCache(service.NewMemoryCacheService(100)),
+		app.WithStorage(service.NewMemoryFileService("http://storage.test")),
+		app.WithRateLimiter(middlewares.NewMemoryRateLimiter()),
+	}
+}
+
+func testConfig() *config.Config {
+	return &config.Config{AppEnv: "test", JWTSecret: "app-test-secret"}
+}
+
+// listeners picks free ports for the API and the internal routes
+func listeners(t *testing.T) []app.Option {
+	t.Helper()
+
+	var opts []app.Option
+	for _, with := range []func(net.Listener) app.Option{app.WithListener, app.WithInternalListener} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen: %v", err)
+		}
+		opts = append(opts, with(listener))
+	}
+	return opts
+}
+
+func TestAppStartShutdown(t *testing.T) {
+	a, err := app.New(testConfig(), append(inMemory(clock.System), listeners(t)...)...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := a.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// Metrics and cache stats are only served on the internal port
+	for _, tt := range []struct {
+		addr, path string
+		want       int
+	}{
+		{a.Addr(), "/v1/health", http.StatusOK},
+		{a.Addr(), "/metrics", http.StatusNotFound},
+		{a.Addr(), "/v1/cache/stats", http.StatusNotFound},
+		{a.InternalAddr(), "/metrics", http.StatusOK},
+		{a.InternalAddr(), "/v1/cache/stats", http.StatusOK},
+		{a.InternalAddr(), "/v1/health", http.StatusNotFound},
+	} {
+		res, err := http.Get("http://" + tt.addr + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.want {
+			t.Errorf("GET %s%s: status = %d, want %d", tt.addr, tt.path, res.StatusCode, tt.want)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if _, err := http.Get("http://" + a.Addr() + "/v1/health"); err == nil {
+		t.Error("server still answering after Shutdown")
+	}
+	if _, err := http.Get("http://" + a.InternalAddr() + "/metrics"); err == nil {
+		t.Error("internal server still answering after Shutdown")
+	}
+}
+
+func TestAppRunStopsWithContext(t *testing.T) {
+	a, err := app.New(testConfig(), append(inMemory(clock.System), listeners(t)...)...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- a.Run(ctx) }()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after the context was cancelled")
+	}
+}
+
+func TestAppShutdownHooks(t *testing.T) {
+	a, err := app.New(testConfig(), inMemory(clock.System)...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var order []string
+	record := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			order = append(order, name)
+			return err
+		}
+	}
+	failure := errors.New("close failed")
+	a.OnShutdown("database", record("database", nil))
+	a.OnShutdown("redis", record("redis", failure))
+	a.OnShutdown("outbox", record("outbox", nil))
+
+	err = a.Shutdown(context.Background())
+	if !errors.Is(err, failure) {
+		t.Errorf("err = %v, want %v", err, failure)
+	}
+	// A failing hook doesn't keep the others from running
+	if want := []string{"outbox", "redis", "database"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("hooks ran in order %v, want %v", order, want)
+	}
+
+	if err := a.Shutdown(context.Background()); !errors.Is(err, failure) || len(order) != 3 {
+		t.Errorf("second Shutdown: err = %v, hooks run %d times, want the first result without rerunning", err, len(order))
+	}
+}
+
+func TestAppNewWithoutStorageConfig(t *testing.T) {
+	clk := clock.System
+	_, err := app.New(testConfig(),
+		app.WithClock(clk),
+		app.WithRepositories(repository.NewMemoryRepositories(clk)),
+		app.WithCache(service.NewMemoryCacheService(100)),
+		app.WithRateLimiter(middlewares.NewMemoryRateLimiter()),
+	)
+	if err == nil {
+		t.Fatal("New succeeded without MINIO_ENDPOINT, want an error")
+	}
+}
+
+func TestAppEventHandlers(t *testing.T) {
+	cfg := testConfig()
+	cfg.OutboxPollInterval = 10 * time.Millisecond
+	delivered := make(chan events.Event, 1)
+	opts := append(inMemory(clock.System), listeners(t)...)
+	a, err := app.New(cfg, append(opts,
+		app.WithEventHandler(events.ActivityCreated, "test", func(ctx context.Context, event events.Event) error {
+			delivered <- event
+			return nil
+		}),
+	)...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer a.Shutdown(context.Background())
+
+	serve := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res := httptest.NewRecorder()
+		a.Handler().ServeHTTP(res, req)
+		return res
+	}
+	res := serve(http.MethodPost, "/v1/register", "", `{"email":"john@example.com","password":"password123"}`)
+	var registered struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &registered); err != nil || res.Code != http.StatusCreated {
+		t.Fatalf("register: status = %d: %s", res.Code, res.Body)
+	}
+	res = serve(http.MethodPost, "/v1/activity", registered.Token, `{"activityType":"Running","doneAt":"2025-01-15T07:30:00Z","durationInMinutes":30}`)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", res.Code, res.Body)
+	}
+
+	// Nothing runs in the background until Start
+	select {
+	case <-delivered:
+		t.Fatal("ActivityCreated delivered before Start")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := a.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// Delivered by the dispatcher running in the background
+	select {
+	case event := <-delivered:
+		var activity events.Activity
+		if err := event.Decode(&activity); err != nil || activity.DurationInMinutes != 30 {
+			t.Errorf("payload = %s (%v), want the created activity", event.Payload, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ActivityCreated wasn't delivered")
+	}
+}
+
+func TestAppTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies string
+		wantLimited    bool
+	}{
+		// Each X-Forwarded-For would otherwise get its own login rate limit
+		{name: "spoofed header ignored", trustedProxies: "", wantLimited: true},
+		{name: "trusted proxy", trustedProxies: "203.0.113.0/24", wantLimited: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.TrustedProxies = tt.trustedProxies
+			cfg.RateLimitEnabled = true
+			cfg.RateLimitAuthPerMinute = 1
+			cfg.RateLimitAuthBurst = 1
+			a, err := app.New(cfg, inMemory(clock.System)...)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			defer a.Shutdown(context.Background())
+
+			login := func(forwardedFor string) int {
+				req := httptest.NewRequest(http.MethodPost, "/v1/login", strings.NewReader(`{"email":"john@example.com","password":"password123"}`))
+				req.RemoteAddr = "203.0.113.7:41000"
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+				res := httptest.NewRecorder()
+				a.Handler().ServeHTTP(res, req)
+				return res.Code
+			}
+			if code := login("198.51.100.1"); code == http.StatusTooManyRequests {
+				t.Fatalf("first login: status = %d, want it let through", code)
+			}
+			if limited := login("198.51.100.2") == http.StatusTooManyRequests; limited != tt.wantLimited {
+				t.Errorf("second login rate limited = %v, want %v", limited, tt.wantLimited)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"log/slog"
+	"net"
+
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/events"
+	"github.com/fikrialwan/FitByte/internal/health"
+	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/fikrialwan/FitByte/middlewares"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Option replaces a component New would otherwise build from the config.
+// Components passed in belong to the caller, the App never closes them.
+type Option func(*options)
+
+type options struct {
+	clock        clock.Clock
+	db           *gorm.DB
+	redis        *redis.Client
+	repositories *repository.Repositories
+	cacheService service.CacheService
+	fileService  service.FileService
+	rateLimiter  middlewares.RateLimiter
+	healthChecks []health.Check
+	logLevel     *slog.LevelVar
+	listener     net.Listener
+	// internalListener serves metrics instead of METRICS_PORT
+	internalListener net.Listener
+	// eventHandlers are subscribed to the event dispatcher in order
+	eventHandlers []eventHandler
+}
+
+type eventHandler struct {
+	eventType events.Type
+	name      string
+	handler   events.Handler
+}
+
+// WithClock sets the clock tokens and timestamps are based on
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
+
+// WithDB uses db instead of connecting to DB_HOST
+func WithDB(db *gorm.DB) Option {
+	return func(o *options) {
+		o.db = db
+	}
+}
+
+// WithRedis uses client for the cache and rate limits instead of connecting
+// to REDIS_ADDR
+func WithRedis(client *redis.Client) Option {
+	return func(o *options) {
+		o.redis = client
+	}
+}
+
+// WithRepositories stores users, activities, the audit log and the outbox in
+// repos. No
+// database is opened, so the readiness checks leave it out unless set with
+// WithHealthChecks.
+func WithRepositories(repos repository.Repositories) Option {
+	return func(o *options) {
+		o.repositories = &repos
+	}
+}
+
+// WithCache replaces the cache selected by CACHE_DRIVER
+func WithCache(cacheService service.CacheService) Option {
+	return func(o *options) {
+		o.cacheService = cacheService
+	}
+}
+
+// WithStorage replaces the MinIO bucket uploads go to
+func WithStorage(fileService service.FileService) Option {
+	return func(o *options) {
+		o.fileService = fileService
+	}
+}
+
+// WithRateLimiter replaces the limiter selected by RATE_LIMIT_DRIVER
+func WithRateLimiter(limiter middlewares.RateLimiter) Option {
+	return func(o *options) {
+		o.rateLimiter = limiter
+	}
+}
+
+// WithHealthChecks replaces the checks run by /v1/ready
+func WithHealthChecks(checks ...health.Check) Option {
+	return func(o *options) {
+		o.healthChecks = checks
+	}
+}
+
+// WithLogLevel has level follow LOG_LEVEL when the config is reloaded. The
+// logger itself is the caller's, the App logs with slog's default.
+func WithLogLevel(level *slog.LevelVar) Option {
+	return func(o *options) {
+		o.logLevel = level
+	}
+}
+
+// WithListener makes Start serve on l instead of listening on APP_PORT
+func WithListener(l net.Listener) Option {
+	return func(o *options) {
+		o.listener = l
+	}
+}
+
+// WithInternalListener makes Start serve metrics on l instead of listening
+// on METRICS_PORT
+func WithInternalListener(l net.Listener) Option {
+	return func(o *options) {
+		o.internalListener = l
+	}
+}
+
+// WithEventHandler subscribes handler to the domain events of eventType, name
+// identifies it in logs. It may be given several times, also for the same
+// type. See events.Handler for the delivery guarantees.
+func WithEventHandler(eventType events.Type, name string, handler events.Handler) Option {
+	return func(o *options) {
+		o.eventHandlers = append(o.eventHandlers, eventHandler{eventType, name, handler})
+	}
+}
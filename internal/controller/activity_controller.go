@@ -2,13 +2,13 @@ package controller
 
 import (
 	"io"
-	"log"
 	"net/http"
 	"strings"
 
 	"github.com/fikrialwan/FitByte/internal/dto"
 	"github.com/fikrialwan/FitByte/internal/service"
 	"github.com/fikrialwan/FitByte/pkg/handler"
+	"github.com/fikrialwan/FitByte/pkg/problem"
 	"github.com/fikrialwan/FitByte/pkg/validator"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -24,7 +24,7 @@ func NewActivityController(activityService service.ActivityService) ActivityCont
 
 // GetActivities godoc
 // @Summary      Get all activities
-// @Description  Retrieve activities with optional filters (limit, offset, activity type, date range, calories burned range).
+// @Description  Retrieve activities with optional filters (limit, offset, activity type, date range, calories burned range). Trashed activities are left out.
 // @Tags         activities
 // @Accept       json
 // @Produce      json
@@ -36,42 +36,75 @@ func NewActivityController(activityService service.ActivityService) ActivityCont
 // @Param        caloriesBurnedMin  query     int     false  "Minimum calories burned"
 // @Param        caloriesBurnedMax  query     int     false  "Maximum calories burned"
 // @Success 200 {array} dto.ActivityResponse
-// @Failure 401 {object} map[string]interface{} "Unauthorized"
-// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
 // @Security BearerAuth
 // @Router /activity [get]
 func (c ActivityController) GetActivity(ctx *gin.Context) {
 	var filter dto.ActivityFilter
 
 	if err := ctx.ShouldBindQuery(&filter); err != nil {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Invalid query parameters")
+		handler.ResponseValidationError(ctx, err)
 		return
 	}
 
 	// Validate filter parameters
 	if filter.Limit < 0 || filter.Limit > 100 {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Limit must be between 0 and 100")
+		handler.ResponseFieldError(ctx, "limit", "Limit must be between 0 and 100")
 		return
 	}
 	if filter.Offset < 0 {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Offset must be non-negative")
+		handler.ResponseFieldError(ctx, "offset", "Offset must be non-negative")
 		return
 	}
-	if filter.CaloriesBurnedMin < 0 || filter.CaloriesBurnedMax < 0 {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Calories burned values must be non-negative")
+	if filter.CaloriesBurnedMin < 0 {
+		handler.ResponseFieldError(ctx, "caloriesBurnedMin", "Calories burned values must be non-negative")
+		return
+	}
+	if filter.CaloriesBurnedMax < 0 {
+		handler.ResponseFieldError(ctx, "caloriesBurnedMax", "Calories burned values must be non-negative")
 		return
 	}
 	if filter.CaloriesBurnedMin > 0 && filter.CaloriesBurnedMax > 0 && filter.CaloriesBurnedMin > filter.CaloriesBurnedMax {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Minimum calories burned cannot be greater than maximum")
+		handler.ResponseFieldError(ctx, "caloriesBurnedMin", "Minimum calories burned cannot be greater than maximum")
+		return
+	}
+
+	userID := ctx.GetString("user_id")
+	res, err := c.activityService.GetActivity(ctx.Request.Context(), filter, userID)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	log.Printf("DEBUG FILTER: %+v\n", filter)
+	handler.ResponseSuccess(ctx, http.StatusOK, res)
+}
+
+// GetActivitySummary godoc
+// @Summary      Get activity summary
+// @Description  Aggregate the user's activities (count, duration and calories burned), overall and per activity type, with an optional date range. Trashed activities are left out.
+// @Tags         activities
+// @Produce      json
+// @Param        doneAtFrom  query  string  false  "Filter from date (ISO8601)" format(date-time)
+// @Param        doneAtTo    query  string  false  "Filter to date (ISO8601)" format(date-time)
+// @Success 200 {object} dto.ActivitySummaryResponse
+// @Failure 400 {object} problem.Problem "Bad Request - Invalid query parameters"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Security BearerAuth
+// @Router /activity/summary [get]
+func (c ActivityController) GetActivitySummary(ctx *gin.Context) {
+	var filter dto.ActivitySummaryFilter
+
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		handler.ResponseValidationError(ctx, err)
+		return
+	}
 
 	userID := ctx.GetString("user_id")
-	res, err := c.activityService.GetActivity(filter, userID)
+	res, err := c.activityService.GetActivitySummary(ctx.Request.Context(), filter, userID)
 	if err != nil {
-		handler.ResponseError(ctx, http.StatusInternalServerError, "Internal server error")
+		respondError(ctx, err)
 		return
 	}
 
@@ -85,10 +118,14 @@ func (c ActivityController) GetActivity(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param request body dto.ActivityRequest true "Activity data"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
 // @Success 201 {object} dto.CreateActivityResponse "Activity created successfully"
-// @Failure 400 {object} map[string]interface{} "Bad Request - Invalid input format"
-// @Failure 401 {object} map[string]interface{} "Unauthorized"
-// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Header 201 {string} ETag "Version of the activity"
+// @Failure 400 {object} problem.Problem "Bad Request - Invalid input format"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 409 {object} problem.Problem "A request with the same Idempotency-Key is in progress"
+// @Failure 422 {object} problem.Problem "Idempotency-Key reused for a different request"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
 // @Security BearerAuth
 // @Router /activity [post]
 func (c ActivityController) CreateActivity(ctx *gin.Context) {
@@ -97,19 +134,46 @@ func (c ActivityController) CreateActivity(ctx *gin.Context) {
 		return
 	}
 
-	response, err := c.activityService.CreateActivity(request, ctx.GetString("user_id"))
+	response, err := c.activityService.CreateActivity(ctx.Request.Context(), request, ctx.GetString("user_id"))
 	if err != nil {
-		// If it's an activity type validation error, return 400
-		if strings.Contains(err.Error(), "invalid activity type") {
-			handler.ResponseError(ctx, http.StatusBadRequest, err.Error())
-			return
-		}
-		// For other errors, return 500
-		handler.ResponseError(ctx, http.StatusInternalServerError, "Internal server error")
+		respondError(ctx, err)
 		return
 	}
 
-	handler.ResponseSuccess(ctx, http.StatusCreated, response)
+	handler.ResponseWithETag(ctx, http.StatusCreated, response.Version, response)
+}
+
+// GetActivityByID godoc
+// @Summary Get activity
+// @Description Get one of the user's activities along with its ETag
+// @Tags activities
+// @Produce json
+// @Param activityId path string true "Activity ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
+// @Success 200 {object} dto.ActivityResponse
+// @Header 200 {string} ETag "Version of the activity"
+// @Success 304 "The cached copy is current"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 404 {object} problem.Problem "Activity not found"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Security BearerAuth
+// @Router /activity/{activityId} [get]
+func (c ActivityController) GetActivityByID(ctx *gin.Context) {
+	activityID := ctx.Param("activityId")
+
+	// Validate UUID format - return 404 for invalid format as it means "not found"
+	if _, err := uuid.Parse(activityID); err != nil {
+		respondError(ctx, service.ErrActivityNotFound)
+		return
+	}
+
+	response, err := c.activityService.GetActivityByID(ctx.Request.Context(), activityID, ctx.GetString("user_id"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	handler.ResponseWithETag(ctx, http.StatusOK, response.Version, response)
 }
 
 // UpdateActivity godoc
@@ -119,45 +183,54 @@ func (c ActivityController) CreateActivity(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param activityId path string true "Activity ID"
+// @Param If-Match header string false "ETag of the version the update is based on"
 // @Param request body dto.ActivityUpdateRequest true "Activity update data"
 // @Success 200 {object} dto.ActivityResponse "Activity updated successfully"
-// @Failure 400 {object} map[string]interface{} "Bad Request - Invalid input format"
-// @Failure 401 {object} map[string]interface{} "Unauthorized"
-// @Failure 404 {object} map[string]interface{} "Activity not found"
-// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Header 200 {string} ETag "Version of the updated activity"
+// @Failure 400 {object} problem.Problem "Bad Request - Invalid input format"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 404 {object} problem.Problem "Activity not found"
+// @Failure 409 {object} problem.Problem "Modified by a concurrent request"
+// @Failure 412 {object} problem.Problem "Modified since the If-Match version"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
 // @Security BearerAuth
 // @Router /activity/{activityId} [patch]
 func (c ActivityController) UpdateActivity(ctx *gin.Context) {
 	// Check content type first
 	contentType := ctx.GetHeader("Content-Type")
 	if contentType != "application/json" && !strings.HasPrefix(contentType, "application/json") {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Content-Type must be application/json")
+		handler.ResponseError(ctx, http.StatusBadRequest, problem.CodeInvalidRequest, "Content-Type must be application/json")
 		return
 	}
 
 	activityID := ctx.Param("activityId")
 	if activityID == "" {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Activity ID is required")
+		handler.ResponseError(ctx, http.StatusBadRequest, problem.CodeInvalidRequest, "Activity ID is required")
 		return
 	}
 
 	// Validate UUID format - return 404 for invalid format as it means "not found"
 	if _, err := uuid.Parse(activityID); err != nil {
-		handler.ResponseError(ctx, http.StatusNotFound, "Activity not found")
+		respondError(ctx, service.ErrActivityNotFound)
+		return
+	}
+
+	version, ok := handler.IfMatchVersion(ctx)
+	if !ok {
+		respondError(ctx, service.ErrVersionMismatch)
 		return
 	}
 
 	// Validate JSON payload using the improved validator
 	body, err := ctx.GetRawData()
 	if err != nil {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Invalid request format")
+		handler.ResponseError(ctx, http.StatusBadRequest, problem.CodeInvalidRequest, "Request body can't be read")
 		return
 	}
 
-	// Use the JSON validator for comprehensive validation
-	schema := validator.GetActivityValidationSchema()
-	if err := validator.ValidateJSON(body, schema); err != nil {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Invalid request format")
+	// Use the JSON validator with the schema declared on the request DTO
+	if err := validator.ValidateJSONFor(body, dto.ActivityUpdateRequest{}); err != nil {
+		handler.ResponseValidationError(ctx, err)
 		return
 	}
 
@@ -170,65 +243,164 @@ func (c ActivityController) UpdateActivity(ctx *gin.Context) {
 	}
 
 	userID := ctx.GetString("user_id")
-	response, err := c.activityService.UpdateActivity(activityID, userID, request)
+	response, err := c.activityService.UpdateActivity(ctx.Request.Context(), activityID, userID, version, request)
 	if err != nil {
-		// If it's a GORM record not found error, return 404
-		if strings.Contains(err.Error(), "record not found") {
-			handler.ResponseError(ctx, http.StatusNotFound, "Activity not found")
-			return
-		}
-		// If it's an activity type validation error, return 400
-		if strings.Contains(err.Error(), "invalid activity type") {
-			handler.ResponseError(ctx, http.StatusBadRequest, err.Error())
-			return
-		}
-		// For other errors, return 500
-		handler.ResponseError(ctx, http.StatusInternalServerError, "Internal server error")
-		return
-	}
-
-	handler.ResponseSuccess(ctx, http.StatusOK, response)
+		respondError(ctx, err)
+		return
+	}
+
+	handler.ResponseWithETag(ctx, http.StatusOK, response.Version, response)
 }
 
 // DeleteActivity godoc
 // @Summary Delete activity
-// @Description Delete an existing activity by ID
+// @Description Move an activity to the trash, from which it can be restored until it is purged after TRASH_RETENTION_DAYS
 // @Tags activities
 // @Accept json
 // @Produce json
 // @Param activityId path string true "Activity ID"
 // @Success 200 {object} map[string]interface{} "Activity deleted successfully"
-// @Failure 400 {object} map[string]interface{} "Bad Request - Invalid activity ID"
-// @Failure 401 {object} map[string]interface{} "Unauthorized"
-// @Failure 404 {object} map[string]interface{} "Activity not found"
-// @Failure 500 {object} map[string]interface{} "Internal Server Error"
+// @Failure 400 {object} problem.Problem "Bad Request - Invalid activity ID"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 404 {object} problem.Problem "Activity not found"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
 // @Security BearerAuth
 // @Router /activity/{activityId} [delete]
 func (c ActivityController) DeleteActivity(ctx *gin.Context) {
 	activityID := ctx.Param("activityId")
 	if activityID == "" {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Activity ID is required")
+		handler.ResponseError(ctx, http.StatusBadRequest, problem.CodeInvalidRequest, "Activity ID is required")
 		return
 	}
 
 	// Validate UUID format - return 404 for invalid format as it means "not found"
 	if _, err := uuid.Parse(activityID); err != nil {
-		handler.ResponseError(ctx, http.StatusNotFound, "Activity not found")
+		respondError(ctx, service.ErrActivityNotFound)
 		return
 	}
 
 	userID := ctx.GetString("user_id")
-	err := c.activityService.DeleteActivity(activityID, userID)
+	err := c.activityService.DeleteActivity(ctx.Request.Context(), activityID, userID)
 	if err != nil {
-		// If it's a GORM record not found error, return 404
-		if strings.Contains(err.Error(), "record not found") {
-			handler.ResponseError(ctx, http.StatusNotFound, "Activity not found")
-			return
-		}
-		// For other errors, return 500
-		handler.ResponseError(ctx, http.StatusInternalServerError, "Internal server error")
+		respondError(ctx, err)
 		return
 	}
 
 	handler.ResponseSuccess(ctx, http.StatusOK, gin.H{"message": "Activity deleted successfully"})
 }
+
+// GetTrash godoc
+// @Summary      Get trashed activities
+// @Description  List deleted activities that can still be restored, most recently deleted first.
+// @Tags         activities
+// @Produce      json
+// @Param        limit   query  int  false  "Limit (default: 5)"   minimum(1) maximum(100)
+// @Param        offset  query  int  false  "Offset (default: 0)"  minimum(0)
+// @Success 200 {array} dto.TrashedActivityResponse
+// @Failure 400 {object} problem.Problem "Bad Request - Invalid query parameters"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Security BearerAuth
+// @Router /activity/trash [get]
+func (c ActivityController) GetTrash(ctx *gin.Context) {
+	var filter dto.TrashFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		handler.ResponseValidationError(ctx, err)
+		return
+	}
+
+	if filter.Limit < 0 || filter.Limit > 100 {
+		handler.ResponseFieldError(ctx, "limit", "Limit must be between 0 and 100")
+		return
+	}
+	if filter.Offset < 0 {
+		handler.ResponseFieldError(ctx, "offset", "Offset must be non-negative")
+		return
+	}
+
+	res, err := c.activityService.GetTrash(ctx.Request.Context(), filter, ctx.GetString("user_id"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	handler.ResponseSuccess(ctx, http.StatusOK, res)
+}
+
+// RestoreActivity godoc
+// @Summary Restore activity
+// @Description Take an activity out of the trash
+// @Tags activities
+// @Produce json
+// @Param activityId path string true "Activity ID"
+// @Success 200 {object} dto.ActivityResponse "Activity restored successfully"
+// @Header 200 {string} ETag "Version of the restored activity"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 404 {object} problem.Problem "Activity not in the trash"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Security BearerAuth
+// @Router /activity/{activityId}/restore [post]
+func (c ActivityController) RestoreActivity(ctx *gin.Context) {
+	activityID := ctx.Param("activityId")
+
+	// Validate UUID format - return 404 for invalid format as it means "not found"
+	if _, err := uuid.Parse(activityID); err != nil {
+		respondError(ctx, service.ErrActivityNotFound)
+		return
+	}
+
+	response, err := c.activityService.RestoreActivity(ctx.Request.Context(), activityID, ctx.GetString("user_id"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	handler.ResponseWithETag(ctx, http.StatusOK, response.Version, response)
+}
+
+// GetActivityHistory godoc
+// @Summary      Get activity history
+// @Description  List who changed an activity, when and how, most recent first. The history outlives the activity.
+// @Tags         activities
+// @Produce      json
+// @Param        activityId  path   string  true   "Activity ID"
+// @Param        limit       query  int     false  "Limit (default: 20)"  minimum(1) maximum(100)
+// @Param        offset      query  int     false  "Offset (default: 0)"  minimum(0)
+// @Success 200 {array} dto.AuditEntryResponse
+// @Failure 400 {object} problem.Problem "Bad Request - Invalid query parameters"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 404 {object} problem.Problem "Activity not found"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Security BearerAuth
+// @Router /activity/{activityId}/history [get]
+func (c ActivityController) GetActivityHistory(ctx *gin.Context) {
+	activityID := ctx.Param("activityId")
+
+	// Validate UUID format - return 404 for invalid format as it means "not found"
+	if _, err := uuid.Parse(activityID); err != nil {
+		respondError(ctx, service.ErrActivityNotFound)
+		return
+	}
+
+	var filter dto.HistoryFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		handler.ResponseValidationError(ctx, err)
+		return
+	}
+	if filter.Limit < 0 || filter.Limit > 100 {
+		handler.ResponseFieldError(ctx, "limit", "Limit must be between 0 and 100")
+		return
+	}
+	if filter.Offset < 0 {
+		handler.ResponseFieldError(ctx, "offset", "Offset must be non-negative")
+		return
+	}
+
+	res, err := c.activityService.GetHistory(ctx.Request.Context(), activityID, ctx.GetString("user_id"), filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	handler.ResponseSuccess(ctx, http.StatusOK, res)
+}
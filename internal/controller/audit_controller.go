@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/fikrialwan/FitByte/pkg/handler"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AuditController struct {
+	auditService service.AuditService
+}
+
+func NewAuditController(auditService service.AuditService) AuditController {
+	return AuditController{auditService}
+}
+
+// SearchAudit godoc
+// @Summary      Search the audit log
+// @Description  Search the changes made to every user's activities and profiles, most recent first. Administrators only, see ADMIN_USER_IDS.
+// @Tags         admin
+// @Produce      json
+// @Param        actorId     query  string  false  "User who made the change" format(uuid)
+// @Param        ownerId     query  string  false  "User whose data changed" format(uuid)
+// @Param        entityType  query  string  false  "Entity type" Enums(activity,user)
+// @Param        entityId    query  string  false  "Entity ID" format(uuid)
+// @Param        action      query  string  false  "Action" Enums(create,update,delete,restore)
+// @Param        from        query  string  false  "Changes from (ISO8601)" format(date-time)
+// @Param        to          query  string  false  "Changes until (ISO8601)" format(date-time)
+// @Param        limit       query  int     false  "Limit (default: 20)"  minimum(1) maximum(100)
+// @Param        offset      query  int     false  "Offset (default: 0)"  minimum(0)
+// @Success 200 {array} dto.AuditEntryResponse
+// @Failure 400 {object} problem.Problem "Bad Request - Invalid query parameters"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Not an administrator"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Security BearerAuth
+// @Router /admin/audit [get]
+func (c AuditController) SearchAudit(ctx *gin.Context) {
+	var filter dto.AuditFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		handler.ResponseValidationError(ctx, err)
+		return
+	}
+
+	for field, id := range map[string]string{"actorId": filter.ActorID, "ownerId": filter.OwnerID, "entityId": filter.EntityID} {
+		if _, err := uuid.Parse(id); id != "" && err != nil {
+			handler.ResponseFieldError(ctx, field, "Must be a UUID")
+			return
+		}
+	}
+	switch filter.EntityType {
+	case "", entity.AuditEntityActivity, entity.AuditEntityUser:
+	default:
+		handler.ResponseFieldError(ctx, "entityType", "Entity type must be activity or user")
+		return
+	}
+	switch entity.AuditAction(filter.Action) {
+	case "", entity.AuditCreate, entity.AuditUpdate, entity.AuditDelete, entity.AuditRestore:
+	default:
+		handler.ResponseFieldError(ctx, "action", "Action must be create, update, delete or restore")
+		return
+	}
+	if filter.Limit < 0 || filter.Limit > 100 {
+		handler.ResponseFieldError(ctx, "limit", "Limit must be between 0 and 100")
+		return
+	}
+	if filter.Offset < 0 {
+		handler.ResponseFieldError(ctx, "offset", "Offset must be non-negative")
+		return
+	}
+
+	res, err := c.auditService.Search(ctx.Request.Context(), filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	handler.ResponseSuccess(ctx, http.StatusOK, res)
+}
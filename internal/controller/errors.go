@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/fikrialwan/FitByte/pkg/problem"
+	"github.com/gin-gonic/gin"
+)
+
+var errorKindStatus = map[service.ErrorKind]int{
+	service.KindNotFound:           http.StatusNotFound,
+	service.KindConflict:           http.StatusConflict,
+	service.KindValidation:         http.StatusBadRequest,
+	service.KindForbidden:          http.StatusForbidden,
+	service.KindPreconditionFailed: http.StatusPreconditionFailed,
+}
+
+// statusClientClosedRequest is the nginx convention for requests abandoned by
+// the client, so they show up in logs and metrics without counting as errors
+const statusClientClosedRequest = 499
+
+// respondError maps service errors to problem responses. Requests cancelled
+// by the client get no body, ones that ran out of time are reported as 504,
+// and anything else that isn't a domain error is logged and reported as a 500
+// without details.
+func respondError(ctx *gin.Context, err error) {
+	var domainErr *service.Error
+	switch {
+	case errors.Is(err, context.Canceled) && ctx.Request.Context().Err() != nil:
+		ctx.AbortWithStatus(statusClientClosedRequest)
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.WarnContext(ctx.Request.Context(), "request timed out", "method", ctx.Request.Method, "route", ctx.FullPath(), "error", err)
+		problem.Write(ctx, problem.New(http.StatusGatewayTimeout, problem.CodeTimeout, "The request took too long, please retry"))
+		return
+	case !errors.As(err, &domainErr):
+		slog.ErrorContext(ctx.Request.Context(), "request failed", "method", ctx.Request.Method, "route", ctx.FullPath(), "error", err)
+		problem.Write(ctx, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Internal server error"))
+		return
+	}
+
+	status, ok := errorKindStatus[domainErr.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	p := problem.New(status, domainErr.Code, domainErr.Message)
+	if domainErr.Field != "" {
+		p = p.WithErrors(problem.FieldError{Field: domainErr.Field, Message: domainErr.Message})
+	}
+	problem.Write(ctx, p)
+}
@@ -21,28 +21,31 @@ func NewFileController(fileService service.FileService) FileController {
 
 // UploadFile godoc
 // @Summary Upload file to S3
-// @Description Upload an image file (JPEG, JPG, PNG) to S3 storage with max size of 100KB
+// @Description Upload an image file (JPEG, JPG, PNG) to private S3 storage with max size of 100KB. The returned URI is a short-lived presigned download URL.
 // @Tags files
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "Image file to upload (max 100KB, JPEG/JPG/PNG only)"
-// @Success 200 {object} map[string]string "Returns S3 file URL"
-// @Failure 400 {object} map[string]string "Bad request - invalid file or size"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} map[string]string "Returns presigned file URL"
+// @Failure 400 {object} problem.Problem "Bad request - invalid file or size"
+// @Failure 409 {object} problem.Problem "A request with the same Idempotency-Key is in progress"
+// @Failure 422 {object} problem.Problem "Idempotency-Key reused for a different request"
+// @Failure 500 {object} problem.Problem "Internal server error"
 // @Security BearerAuth
 // @Router /file [post]
 func (c FileController) UploadFile(ctx *gin.Context) {
 	// Get file from form data
 	file, header, err := ctx.Request.FormFile("file")
 	if err != nil {
-		handler.ResponseError(ctx, http.StatusBadRequest, "File is required")
+		handler.ResponseFieldError(ctx, "file", "File is required")
 		return
 	}
 	defer file.Close()
 
 	// Validate file size (max 100KB as per your spec)
 	if header.Size > 100*1024 {
-		handler.ResponseError(ctx, http.StatusBadRequest, "File size exceeds 100KB limit")
+		handler.ResponseFieldError(ctx, "file", "File size exceeds 100KB limit")
 		return
 	}
 
@@ -61,14 +64,21 @@ func (c FileController) UploadFile(ctx *gin.Context) {
 	}
 
 	if !validExtension {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Invalid file type. Only JPEG, JPG, and PNG are allowed")
+		handler.ResponseFieldError(ctx, "file", "Invalid file type. Only JPEG, JPG, and PNG are allowed")
 		return
 	}
 
 	// Upload to S3
-	fileURL, err := c.fileService.UploadToS3(file, header.Filename, contentType)
+	key, err := c.fileService.UploadToS3(ctx.Request.Context(), ctx.GetString("user_id"), file, header.Filename, contentType)
 	if err != nil {
-		handler.ResponseError(ctx, http.StatusInternalServerError, "Internal server error")
+		respondError(ctx, err)
+		return
+	}
+
+	// The bucket is private, hand out a signed URL instead of the raw object URL
+	fileURL, err := c.fileService.PresignGetURL(ctx.Request.Context(), key)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
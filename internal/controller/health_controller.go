@@ -1,32 +1,33 @@
 package controller
 
 import (
-	"context"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/fikrialwan/FitByte/internal/buildinfo"
+	"github.com/fikrialwan/FitByte/internal/health"
 	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/fikrialwan/FitByte/pkg/handler"
+	"github.com/fikrialwan/FitByte/pkg/problem"
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 type HealthController struct {
-	db           *gorm.DB
+	checker      *health.Checker
 	cacheService service.CacheService
-	fileService  service.FileService
 }
 
-func NewHealthController(db *gorm.DB, cacheService service.CacheService, fileService service.FileService) HealthController {
+func NewHealthController(checker *health.Checker, cacheService service.CacheService) HealthController {
 	return HealthController{
-		db:           db,
+		checker:      checker,
 		cacheService: cacheService,
-		fileService:  fileService,
 	}
 }
 
 // HealthCheck godoc
 // @Summary Health check endpoint
-// @Description Returns the health status of the application
+// @Description Returns the liveness of the application and the build it runs. Dependencies aren't checked.
 // @Tags health
 // @Produce json
 // @Success 200 {object} map[string]interface{}
@@ -36,123 +37,58 @@ func (h HealthController) HealthCheck(ctx *gin.Context) {
 		"status":    "healthy",
 		"timestamp": time.Now().UTC(),
 		"service":   "FitByte API",
-		"version":   "1.0.0",
+		"build":     buildinfo.Get(),
 	})
 }
 
 // ReadinessCheck godoc
 // @Summary Readiness check endpoint
-// @Description Returns the readiness status of the application with actual health checks
+// @Description Checks every dependency concurrently. Fails with 503 when a critical one (the database) is unhealthy, non-critical failures report "degraded" with 200. Results are cached for a few seconds.
 // @Tags health
 // @Produce json
-// @Success 200 {object} map[string]interface{}
-// @Failure 503 {object} map[string]interface{}
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
 // @Router /ready [get]
 func (h HealthController) ReadinessCheck(ctx *gin.Context) {
-	checks := make(map[string]interface{})
-	allHealthy := true
+	report := h.checker.CheckAll(ctx.Request.Context())
 
-	// Database connectivity check
-	if h.db != nil {
-		sqlDB, err := h.db.DB()
-		if err != nil {
-			checks["database"] = map[string]interface{}{
-				"status": "error",
-				"error":  "failed to get database instance: " + err.Error(),
-			}
-			allHealthy = false
-		} else if err := sqlDB.Ping(); err != nil {
-			checks["database"] = map[string]interface{}{
-				"status": "error",
-				"error":  "database ping failed: " + err.Error(),
-			}
-			allHealthy = false
-		} else {
-			checks["database"] = map[string]interface{}{
-				"status": "healthy",
-			}
-		}
-	} else {
-		checks["database"] = map[string]interface{}{
-			"status": "error",
-			"error":  "database not initialized",
-		}
-		allHealthy = false
-	}
-
-	// Redis connectivity check
-	if h.cacheService != nil {
-		// Test Redis with a simple ping operation
-		testKey := "health_check_" + time.Now().Format("20060102150405")
-		testValue := "ping"
-
-		// Try to set and get a test value
-		err := h.cacheService.Set(testKey, testValue, 10*time.Second)
-		if err != nil {
-			checks["redis"] = map[string]interface{}{
-				"status": "error",
-				"error":  "redis set operation failed: " + err.Error(),
-			}
-			allHealthy = false
-		} else {
-			// Try to get the value back
-			_, err := h.cacheService.Get(testKey)
-			if err != nil {
-				checks["redis"] = map[string]interface{}{
-					"status": "error",
-					"error":  "redis get operation failed: " + err.Error(),
-				}
-				allHealthy = false
-			} else {
-				checks["redis"] = map[string]interface{}{
-					"status": "healthy",
-				}
-				// Clean up test key
-				h.cacheService.Delete(testKey)
-			}
-		}
-	} else {
-		checks["redis"] = map[string]interface{}{
-			"status": "error",
-			"error":  "cache service not initialized",
-		}
-		allHealthy = false
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
 	}
+	ctx.JSON(status, report)
+}
 
-	// MinIO connectivity check
-	if h.fileService != nil {
-		// Test MinIO connectivity using the interface method
-		err := h.fileService.CheckConnectivity(context.Background())
-		if err != nil {
-			checks["minio"] = map[string]interface{}{
-				"status": "error",
-				"error":  "MinIO connectivity check failed: " + err.Error(),
-			}
-			allHealthy = false
-		} else {
-			checks["minio"] = map[string]interface{}{
-				"status": "healthy",
-			}
-		}
-	} else {
-		checks["minio"] = map[string]interface{}{
-			"status": "error",
-			"error":  "file service not initialized",
-		}
-		allHealthy = false
+// ComponentReadinessCheck godoc
+// @Summary Component readiness check endpoint
+// @Description Checks a single dependency, failing with 503 when it is unhealthy regardless of whether it is critical
+// @Tags health
+// @Produce json
+// @Param component path string true "Component name" Enums(database, redis, minio)
+// @Success 200 {object} health.Result
+// @Failure 404 {object} problem.Problem
+// @Failure 503 {object} health.Result
+// @Router /ready/{component} [get]
+func (h HealthController) ComponentReadinessCheck(ctx *gin.Context) {
+	result, ok := h.checker.CheckOne(ctx.Request.Context(), ctx.Param("component"))
+	if !ok {
+		handler.ResponseError(ctx, http.StatusNotFound, problem.CodeNotFound,
+			"Unknown component, expected one of: "+strings.Join(h.checker.Names(), ", "))
+		return
 	}
 
-	// Determine overall status and HTTP status code
-	status := "ready"
-	httpStatus := http.StatusOK
-	if !allHealthy {
-		status = "not ready"
-		httpStatus = http.StatusServiceUnavailable
+	status := http.StatusOK
+	if !result.Healthy() {
+		status = http.StatusServiceUnavailable
 	}
+	ctx.JSON(status, result)
+}
 
-	ctx.JSON(httpStatus, gin.H{
-		"status":    status,
+// CacheStats returns the hit/miss counters per cached resource since the
+// process started. It is served on METRICS_PORT, not the public API.
+func (h HealthController) CacheStats(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
 		"timestamp": time.Now().UTC(),
-		"checks":    checks,
+		"cache":     h.cacheService.Stats(),
 	})
 }
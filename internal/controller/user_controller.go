@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"errors"
 	"io"
 	"net/http"
 	"strings"
@@ -9,17 +8,22 @@ import (
 	"github.com/fikrialwan/FitByte/internal/dto"
 	"github.com/fikrialwan/FitByte/internal/service"
 	"github.com/fikrialwan/FitByte/pkg/handler"
+	"github.com/fikrialwan/FitByte/pkg/problem"
 	"github.com/fikrialwan/FitByte/pkg/validator"
 	"github.com/gin-gonic/gin"
 )
 
 type UserController struct {
 	userService service.UserService
+	// imageURIRule checks the scheme and host of profile images, see
+	// IMAGE_URI_ALLOWED_HOSTS
+	imageURIRule validator.RuleFunc
 }
 
-func NewUserController(userService service.UserService) UserController {
+func NewUserController(userService service.UserService, imageURIRule validator.RuleFunc) UserController {
 	return UserController{
-		userService: userService,
+		userService:  userService,
+		imageURIRule: imageURIRule,
 	}
 }
 
@@ -31,9 +35,9 @@ func NewUserController(userService service.UserService) UserController {
 // @Produce json
 // @Param request body dto.LoginRegisterRequest true "Login credentials"
 // @Success 200 {object} dto.LoginRegisterResponse
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /login [post]
 func (c UserController) Login(ctx *gin.Context) {
 	var request dto.LoginRegisterRequest
@@ -41,12 +45,9 @@ func (c UserController) Login(ctx *gin.Context) {
 		return
 	}
 
-	response, err := c.userService.Verify(request.Email, request.Password)
-	if errors.Is(err, dto.ErrUserNotFound) {
-		handler.ResponseError(ctx, http.StatusNotFound, "Invalid email or password")
-		return
-	} else if err != nil {
-		handler.ResponseError(ctx, http.StatusInternalServerError, "Internal server error")
+	response, err := c.userService.Verify(ctx.Request.Context(), request.Email, request.Password, ctx.ClientIP())
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
@@ -61,9 +62,9 @@ func (c UserController) Login(ctx *gin.Context) {
 // @Produce json
 // @Param request body dto.LoginRegisterRequest true "Registration credentials"
 // @Success 201 {object} dto.LoginRegisterResponse
-// @Failure 400 {object} map[string]interface{}
-// @Failure 409 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /register [post]
 func (c UserController) Register(ctx *gin.Context) {
 	var request dto.LoginRegisterRequest
@@ -71,12 +72,9 @@ func (c UserController) Register(ctx *gin.Context) {
 		return
 	}
 
-	response, err := c.userService.Register(request.Email, request.Password)
-	if errors.Is(err, dto.ErrUserEmailExist) {
-		handler.ResponseError(ctx, http.StatusConflict, "Email already exists")
-		return
-	} else if err != nil {
-		handler.ResponseError(ctx, http.StatusInternalServerError, "Internal server error")
+	response, err := c.userService.Register(ctx.Request.Context(), request.Email, request.Password)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
@@ -89,23 +87,29 @@ func (c UserController) Register(ctx *gin.Context) {
 // @Tags users
 // @Accept json
 // @Produce json
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Security BearerAuth
 // @Success 200 {object} dto.UserResponse
-// @Failure 404 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Header 200 {string} ETag "Version of the profile"
+// @Success 304 "The cached copy is current, never sent for a profile with an image"
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /user [get]
 func (c UserController) GetProfile(ctx *gin.Context) {
 	userId := ctx.GetString("user_id")
-	response, err := c.userService.GetProfile(userId)
-	if errors.Is(err, dto.ErrUserNotFound) {
-		handler.ResponseError(ctx, http.StatusNotFound, "User not found")
-		return
-	} else if err != nil {
-		handler.ResponseError(ctx, http.StatusInternalServerError, "Internal server error")
+	response, err := c.userService.GetProfile(ctx.Request.Context(), userId)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	handler.ResponseSuccess(ctx, http.StatusOK, response)
+	// The presigned imageUri expires long before the version changes, a
+	// cached copy would keep a dead link
+	if response.ImageUri != "" {
+		handler.ResponseWithVersion(ctx, http.StatusOK, response.Version, response)
+		return
+	}
+	handler.ResponseWithETag(ctx, http.StatusOK, response.Version, response)
 }
 
 // Register godoc
@@ -114,33 +118,43 @@ func (c UserController) GetProfile(ctx *gin.Context) {
 // @Tags users
 // @Accept json
 // @Produce json
+// @Param If-Match header string false "ETag of the version the update is based on"
 // @Param request body dto.UserRequest true "profile data"
 // @Security BearerAuth
 // @Success 200 {object} dto.UserResponse
-// @Failure 401 {object} utils.FailedResponse
-// @Failure 500 {object} utils.FailedResponse
+// @Header 200 {string} ETag "Version of the updated profile"
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 409 {object} problem.Problem "Modified by a concurrent request"
+// @Failure 412 {object} problem.Problem "Modified since the If-Match version"
+// @Failure 500 {object} problem.Problem
 // @Router /user [patch]
 func (c UserController) UpdateProfile(ctx *gin.Context) {
 	// Check content type first
 	contentType := ctx.GetHeader("Content-Type")
 	if contentType != "application/json" && !strings.HasPrefix(contentType, "application/json") {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Content-Type must be application/json")
+		handler.ResponseError(ctx, http.StatusBadRequest, problem.CodeInvalidRequest, "Content-Type must be application/json")
 		return
 	}
 
 	userId := ctx.GetString("user_id")
 
+	version, ok := handler.IfMatchVersion(ctx)
+	if !ok {
+		respondError(ctx, service.ErrVersionMismatch)
+		return
+	}
+
 	// Validate JSON payload using the improved validator
 	body, err := ctx.GetRawData()
 	if err != nil {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Invalid request format")
+		handler.ResponseError(ctx, http.StatusBadRequest, problem.CodeInvalidRequest, "Request body can't be read")
 		return
 	}
 
-	// Use the JSON validator for comprehensive validation
-	schema := validator.GetUserValidationSchema()
-	if err := validator.ValidateJSON(body, schema); err != nil {
-		handler.ResponseError(ctx, http.StatusBadRequest, "Invalid request format")
+	// Use the JSON validator with the schema declared on the request DTO
+	if err := validator.ValidateJSONFor(body, dto.UserRequest{}); err != nil {
+		handler.ResponseValidationError(ctx, err)
 		return
 	}
 
@@ -151,12 +165,18 @@ func (c UserController) UpdateProfile(ctx *gin.Context) {
 	if handler.BindAndValidate(ctx, &request) {
 		return
 	}
+	if request.ImageUri != "" {
+		if err := c.imageURIRule(request.ImageUri); err != nil {
+			handler.ResponseValidationError(ctx, validator.ValidationErrors{{Field: "imageUri", Message: err.Error()}})
+			return
+		}
+	}
 
-	response, err := c.userService.UpdateProfile(userId, request)
+	response, err := c.userService.UpdateProfile(ctx.Request.Context(), userId, version, request)
 	if err != nil {
-		handler.ResponseError(ctx, http.StatusInternalServerError, "Internal server error")
+		respondError(ctx, err)
 		return
 	}
 
-	handler.ResponseSuccess(ctx, http.StatusOK, response)
+	handler.ResponseWithETag(ctx, http.StatusOK, response.Version, response)
 }
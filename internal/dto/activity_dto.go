@@ -2,6 +2,8 @@ package dto
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -9,6 +11,13 @@ import (
 	"github.com/google/uuid"
 )
 
+var (
+	ErrActivityNotFound = errors.New("activity not found")
+	// ErrVersionConflict is returned by updates of a record that changed since
+	// it was read
+	ErrVersionConflict = errors.New("version conflict")
+)
+
 // PreciseTime wraps time.Time to preserve exact millisecond formatting
 type PreciseTime struct {
 	time.Time
@@ -49,18 +58,30 @@ type (
 		CaloriesBurnedMax int       `form:"caloriesBurnedMax"`
 	}
 
+	// ActivitySummaryFilter narrows the activities aggregated by the summary
+	ActivitySummaryFilter struct {
+		DoneAtFrom time.Time `form:"doneAtFrom"`
+		DoneAtTo   time.Time `form:"doneAtTo"`
+	}
+
+	// TrashFilter pages through the trash, most recently deleted first
+	TrashFilter struct {
+		Limit  int `form:"limit"`
+		Offset int `form:"offset"`
+	}
+
 	// ActivityRequest represents the request payload for creating an activity
 	ActivityRequest struct {
-		ActivityType      entity.ActivityType `json:"activityType" binding:"required,oneof=Walking Yoga Stretching Cycling Swimming Dancing Hiking Running HIIT JumpRope" example:"Running" enums:"Walking,Yoga,Stretching,Cycling,Swimming,Dancing,Hiking,Running,HIIT,JumpRope"`
+		ActivityType      entity.ActivityType `json:"activityType" binding:"required,oneof=Walking Yoga Stretching Cycling Swimming Dancing Hiking Running HIIT JumpRope" example:"Running"`
 		DoneAt            time.Time           `json:"doneAt" binding:"required" example:"2024-01-15T07:30:00Z"`
-		DurationInMinutes int                 `json:"durationInMinutes" binding:"required,numeric,min=1,max=1440" example:"30" minimum:"1" maximum:"1440"`
+		DurationInMinutes int                 `json:"durationInMinutes" binding:"required,numeric,min=1,max=1440" example:"30"`
 	}
 
 	// ActivityUpdateRequest represents the request payload for updating an activity
 	ActivityUpdateRequest struct {
-		ActivityType      *entity.ActivityType `json:"activityType,omitempty" binding:"omitempty,oneof=Walking Yoga Stretching Cycling Swimming Dancing Hiking Running HIIT JumpRope" example:"Running" enums:"Walking,Yoga,Stretching,Cycling,Swimming,Dancing,Hiking,Running,HIIT,JumpRope"`
+		ActivityType      *entity.ActivityType `json:"activityType,omitempty" binding:"omitempty,oneof=Walking Yoga Stretching Cycling Swimming Dancing Hiking Running HIIT JumpRope" example:"Running"`
 		DoneAt            *PreciseTime         `json:"doneAt,omitempty" binding:"omitempty" swaggertype:"string" format:"date-time" example:"2024-01-15T07:30:00Z"`
-		DurationInMinutes *int                 `json:"durationInMinutes,omitempty" binding:"omitempty,min=1,max=1440" example:"30" minimum:"1" maximum:"1440"`
+		DurationInMinutes *int                 `json:"durationInMinutes,omitempty" binding:"omitempty,min=1,max=1440" example:"30"`
 	}
 
 	// ActivityResponse represents the response payload for activity operations
@@ -70,10 +91,17 @@ type (
 		DoneAt            PreciseTime         `json:"doneAt" swaggertype:"string" format:"date-time" example:"2024-01-15T07:30:00Z"`
 		DurationInMinutes int                 `json:"durationInMinutes" example:"30"`
 		CaloriesBurned    int                 `json:"caloriesBurned" example:"300"`
+		Version           int                 `json:"version" example:"1"`
 		CreatedAt         time.Time           `json:"createdAt" example:"2024-01-15T10:30:00Z"`
 		UpdatedAt         time.Time           `json:"updatedAt" example:"2024-01-15T10:30:00Z"`
 	}
 
+	// TrashedActivityResponse is an activity in the trash
+	TrashedActivityResponse struct {
+		ActivityResponse
+		DeletedAt time.Time `json:"deletedAt" example:"2024-01-16T08:00:00Z"`
+	}
+
 	// CreateActivityResponse represents the response payload for activity operations
 	CreateActivityResponse struct {
 		ID                uuid.UUID           `json:"activityId" example:"123e4567-e89b-12d3-a456-426614174000"`
@@ -81,7 +109,37 @@ type (
 		DoneAt            time.Time           `json:"doneAt" example:"2024-01-15T07:30:00Z"`
 		DurationInMinutes int                 `json:"durationInMinutes" example:"30"`
 		CaloriesBurned    int                 `json:"caloriesBurned" example:"300"`
+		Version           int                 `json:"version" example:"1"`
 		CreatedAt         time.Time           `json:"createdAt" example:"2024-01-15T10:30:00Z"`
 		UpdatedAt         time.Time           `json:"updatedAt" example:"2024-01-15T10:30:00Z"`
 	}
 )
+
+type (
+	// ActivityTypeSummary aggregates the activities of a single type
+	ActivityTypeSummary struct {
+		ActivityType           entity.ActivityType `json:"activityType" example:"Running"`
+		TotalActivities        int                 `json:"totalActivities" example:"4"`
+		TotalDurationInMinutes int                 `json:"totalDurationInMinutes" example:"120"`
+		TotalCaloriesBurned    int                 `json:"totalCaloriesBurned" example:"1200"`
+	}
+
+	// ActivitySummaryResponse represents the aggregated activities of a user
+	ActivitySummaryResponse struct {
+		TotalActivities        int                   `json:"totalActivities" example:"4"`
+		TotalDurationInMinutes int                   `json:"totalDurationInMinutes" example:"120"`
+		TotalCaloriesBurned    int                   `json:"totalCaloriesBurned" example:"1200"`
+		ByType                 []ActivityTypeSummary `json:"byType"`
+	}
+)
+
+// CacheKey identifies the page described by the filter
+func (f ActivityFilter) CacheKey() string {
+	return fmt.Sprintf("%d:%d:%s:%d:%d:%d:%d", f.Limit, f.Offset, f.ActivityType,
+		f.DoneAtFrom.UnixNano(), f.DoneAtTo.UnixNano(), f.CaloriesBurnedMin, f.CaloriesBurnedMax)
+}
+
+// CacheKey identifies the summary described by the filter
+func (f ActivitySummaryFilter) CacheKey() string {
+	return fmt.Sprintf("%d:%d", f.DoneAtFrom.UnixNano(), f.DoneAtTo.UnixNano())
+}
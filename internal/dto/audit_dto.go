@@ -0,0 +1,59 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/google/uuid"
+)
+
+type (
+	// HistoryFilter pages through the history of one entity, most recent
+	// change first
+	HistoryFilter struct {
+		Limit  int `form:"limit"`
+		Offset int `form:"offset"`
+	}
+
+	// AuditFilter narrows the audit log search, empty fields match every
+	// entry. IDs are UUIDs.
+	AuditFilter struct {
+		ActorID    string    `form:"actorId"`
+		OwnerID    string    `form:"ownerId"`
+		EntityType string    `form:"entityType"`
+		EntityID   string    `form:"entityId"`
+		Action     string    `form:"action"`
+		From       time.Time `form:"from"`
+		To         time.Time `form:"to"`
+		Limit      int       `form:"limit"`
+		Offset     int       `form:"offset"`
+	}
+
+	// AuditEntryResponse is a change recorded in the audit log
+	AuditEntryResponse struct {
+		ID         uuid.UUID           `json:"id" example:"7c1a3e0b-2f5d-4e8a-9b6c-1d2e3f4a5b6c"`
+		ActorID    uuid.UUID           `json:"actorId" example:"123e4567-e89b-12d3-a456-426614174000"`
+		OwnerID    uuid.UUID           `json:"ownerId" example:"123e4567-e89b-12d3-a456-426614174000"`
+		Action     entity.AuditAction  `json:"action" example:"update"`
+		EntityType string              `json:"entityType" example:"activity"`
+		EntityID   uuid.UUID           `json:"entityId" example:"123e4567-e89b-12d3-a456-426614174000"`
+		Changes    entity.AuditChanges `json:"changes" swaggertype:"object"`
+		RequestID  string              `json:"requestId,omitempty" example:"01J9Z6X4T0Q3K8V2M5N7P9R1S3"`
+		CreatedAt  time.Time           `json:"createdAt" example:"2024-01-15T10:30:00Z"`
+	}
+)
+
+// NewAuditEntryResponse maps an audit entry to its response
+func NewAuditEntryResponse(entry entity.AuditEntry) AuditEntryResponse {
+	return AuditEntryResponse{
+		ID:         entry.ID,
+		ActorID:    entry.ActorID,
+		OwnerID:    entry.OwnerID,
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Changes:    entry.Changes,
+		RequestID:  entry.RequestID,
+		CreatedAt:  entry.CreatedAt,
+	}
+}
@@ -0,0 +1,18 @@
+package dto
+
+// IdempotencyRecord is what is kept for an Idempotency-Key: the fingerprint
+// of the first request and, once it finished, its response
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	// Status stays 0 while the first request is in progress
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	// ETag is replayed so retries can still make conditional updates
+	ETag string `json:"etag,omitempty"`
+	Body []byte `json:"body,omitempty"`
+}
+
+// Completed reports whether the response has been stored
+func (r IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
@@ -42,6 +42,7 @@ type (
 		Height     int    `json:"height"`
 		Name       string `json:"name"`
 		ImageUri   string `json:"imageUri"`
+		Version    int    `json:"version"`
 	}
 )
 
@@ -58,10 +59,12 @@ func (req UserRequest) ToUserEntity(userIdStr string) (entity.User, error) {
 		HeightUnit: req.HeightUnit,
 		Weight:     req.Weight,
 		Height:     req.Height,
-		ImageUri:   req.ImageUri,
+		ImageKey:   req.ImageUri,
 	}, nil
 }
 
+// NewUserResponseFromEntity maps a user to its response. ImageUri carries the
+// stored object key; the service swaps it for a presigned URL before replying.
 func NewUserResponseFromEntity(user entity.User) UserResponse {
 	return UserResponse{
 		Email:      user.Email,
@@ -71,6 +74,7 @@ func NewUserResponseFromEntity(user entity.User) UserResponse {
 		Weight:     user.Weight,
 		Height:     user.Height,
 		Name:       user.Name,
-		ImageUri:   user.ImageUri,
+		ImageUri:   user.ImageKey,
+		Version:    user.Version,
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Activity struct {
@@ -12,6 +13,11 @@ type Activity struct {
 	DoneAt            time.Time    `gorm:"index" json:"doneAt"`
 	DurationInMinutes int          `json:"durationInMinutes"`
 	CaloriesBurned    int          `gorm:"index" json:"caloriesBurned"`
+	// Version is incremented by every update, see ActivityRepository.UpdateActivity
+	Version int `gorm:"not null;default:1" json:"version"`
+	// DeletedAt is set while the activity is in the trash. Queries leave
+	// trashed activities out unless they are Unscoped.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
 	UserID uuid.UUID `gorm:"index"`
 	User   User
@@ -0,0 +1,119 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEntry records a change made to a user's data. Entries are only ever
+// appended, the audit_log table rejects updates and deletes.
+type AuditEntry struct {
+	ID uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	// ActorID made the change to data owned by OwnerID, the same user unless
+	// someone acted on their behalf
+	ActorID    uuid.UUID    `gorm:"type:uuid"`
+	OwnerID    uuid.UUID    `gorm:"type:uuid"`
+	Action     AuditAction  `gorm:"type:varchar(20)"`
+	EntityType string       `gorm:"type:varchar(20)"`
+	EntityID   uuid.UUID    `gorm:"type:uuid"`
+	Changes    AuditChanges `gorm:"type:jsonb;not null"`
+	RequestID  string       `gorm:"type:varchar(128)"`
+	CreatedAt  time.Time
+}
+
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
+
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+)
+
+// Audited entity types
+const (
+	AuditEntityActivity = "activity"
+	AuditEntityUser     = "user"
+)
+
+// FieldChange is a field's value before and after a change, null when the
+// entity didn't exist on that side
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditChanges are the changed fields by JSON name, stored as jsonb
+type AuditChanges map[string]FieldChange
+
+// DiffAuditFields returns the fields whose value differs between the two
+// snapshots of AuditFields, nil standing for a missing entity
+func DiffAuditFields(before, after map[string]any) AuditChanges {
+	changes := AuditChanges{}
+	for field, value := range before {
+		if after == nil || after[field] != value {
+			changes[field] = FieldChange{Before: value, After: after[field]}
+		}
+	}
+	for field, value := range after {
+		if _, ok := before[field]; !ok {
+			changes[field] = FieldChange{After: value}
+		}
+	}
+	return changes
+}
+
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(c)
+	return string(data), err
+}
+
+func (c *AuditChanges) Scan(src any) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, c)
+	case string:
+		return json.Unmarshal([]byte(src), c)
+	case nil:
+		*c = nil
+		return nil
+	default:
+		return fmt.Errorf("can't scan %T into AuditChanges", src)
+	}
+}
+
+// AuditFields are the fields of the activity an audit entry tracks. Values
+// are comparable and JSON friendly.
+func (a Activity) AuditFields() map[string]any {
+	return map[string]any{
+		"activityType":      string(a.ActivityType),
+		"doneAt":            a.DoneAt.UTC().Format(time.RFC3339Nano),
+		"durationInMinutes": a.DurationInMinutes,
+		"caloriesBurned":    a.CaloriesBurned,
+	}
+}
+
+// AuditFields are the profile fields an audit entry tracks, never the
+// credentials
+func (u User) AuditFields() map[string]any {
+	return map[string]any{
+		"name":       u.Name,
+		"preference": u.Preference,
+		"weightUnit": u.WeightUnit,
+		"heightUnit": u.HeightUnit,
+		"weight":     u.Weight,
+		"height":     u.Height,
+		"imageKey":   u.ImageKey,
+	}
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent is a domain event waiting to be, or already, delivered to the
+// event handlers. It is written in the transaction making the change it
+// describes, so an event is only ever sent for a committed change.
+type OutboxEvent struct {
+	ID   uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Type string    `gorm:"type:varchar(50)"`
+	// AggregateID is the activity or user the event is about, owned by UserID
+	AggregateID uuid.UUID       `gorm:"type:uuid"`
+	UserID      uuid.UUID       `gorm:"type:uuid"`
+	Payload     json.RawMessage `gorm:"type:jsonb;not null"`
+	RequestID   string          `gorm:"type:varchar(128)"`
+	// Attempts counts the deliveries started, the next one is due at
+	// NextAttemptAt
+	Attempts      int
+	NextAttemptAt time.Time
+	// ClaimToken identifies the latest claim, NextAttemptAt is when its lease
+	// ends
+	ClaimToken uuid.UUID `gorm:"type:uuid"`
+	LastError  string    `gorm:"type:text"`
+	// PublishedAt is set once every handler succeeded, FailedAt once the
+	// dispatcher gave up on the event
+	PublishedAt *time.Time
+	FailedAt    *time.Time
+	CreatedAt   time.Time
+}
@@ -1,7 +1,6 @@
 package entity
 
 import (
-	"github.com/fikrialwan/FitByte/pkg/helpers"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -16,21 +15,15 @@ type User struct {
 	HeightUnit string    `gorm:"type:varchar(5)" json:"height_unit"`
 	Weight     int       `json:"weight"`
 	Height     int       `json:"height"`
-	ImageUri   string    `gorm:"type:text" json:"image_uri"`
+	ImageKey   string    `gorm:"type:text" json:"image_key"`
+	// Version is incremented by every profile update, see UserRepository.Update
+	Version int `gorm:"not null;default:1" json:"version"`
 
 	Timestamp
 }
 
-// BeforeCreate hook to hash password and set defaults
+// BeforeCreate hook to set defaults
 func (u *User) BeforeCreate(_ *gorm.DB) (err error) {
-	// Hash password
-	if u.Password != "" {
-		u.Password, err = helpers.HashPassword(u.Password)
-		if err != nil {
-			return err
-		}
-	}
-
 	// Ensure UUID is set
 	if u.ID == uuid.Nil {
 		u.ID = uuid.New()
@@ -38,15 +31,3 @@ func (u *User) BeforeCreate(_ *gorm.DB) (err error) {
 
 	return nil
 }
-
-// BeforeUpdate hook to handle password updates
-func (u *User) BeforeUpdate(_ *gorm.DB) (err error) {
-	// Only hash password if it has been changed
-	if u.Password != "" {
-		u.Password, err = helpers.HashPassword(u.Password)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
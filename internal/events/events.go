@@ -0,0 +1,114 @@
+// Package events defines the domain events the services emit when users
+// change their activities and profiles. Events are delivered at least once:
+// handlers must tolerate duplicates, e.g. by remembering the IDs they've seen.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/google/uuid"
+)
+
+// Type names an event, the payload it carries depends on it
+type Type string
+
+const (
+	// ActivityCreated carries an Activity
+	ActivityCreated Type = "activity.created"
+	// ActivityUpdated carries an ActivityUpdate
+	ActivityUpdated Type = "activity.updated"
+	// ActivityDeleted carries the Activity as it was when moved to the trash
+	ActivityDeleted Type = "activity.deleted"
+	// ActivityRestored carries the Activity taken out of the trash
+	ActivityRestored Type = "activity.restored"
+	// ProfileUpdated carries a ProfileUpdate
+	ProfileUpdated Type = "profile.updated"
+)
+
+// Event is a change that happened, as handed to the handlers
+type Event struct {
+	ID   uuid.UUID
+	Type Type
+	// AggregateID is the activity or user the event is about, owned by UserID
+	AggregateID uuid.UUID
+	UserID      uuid.UUID
+	Payload     json.RawMessage
+	// RequestID is the request that made the change, if any
+	RequestID  string
+	OccurredAt time.Time
+	// Attempt is 1 on the first delivery and grows with every retry
+	Attempt int
+}
+
+// Decode unmarshals the payload into v, which should be the payload type
+// documented for the event's Type
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// Handler reacts to an event. Returning an error, or panicking, has the event
+// delivered again later to every handler of its type.
+type Handler func(ctx context.Context, event Event) error
+
+// Activity is the state of an activity
+type Activity struct {
+	ID                uuid.UUID           `json:"id"`
+	UserID            uuid.UUID           `json:"userId"`
+	ActivityType      entity.ActivityType `json:"activityType"`
+	DoneAt            time.Time           `json:"doneAt"`
+	DurationInMinutes int                 `json:"durationInMinutes"`
+	CaloriesBurned    int                 `json:"caloriesBurned"`
+	Version           int                 `json:"version"`
+}
+
+// ActivityUpdate is an activity before and after an update
+type ActivityUpdate struct {
+	Previous Activity `json:"previous"`
+	Current  Activity `json:"current"`
+}
+
+// Profile is the state of a user's profile, without credentials
+type Profile struct {
+	UserID     uuid.UUID `json:"userId"`
+	Name       string    `json:"name"`
+	Preference string    `json:"preference"`
+	WeightUnit string    `json:"weightUnit"`
+	HeightUnit string    `json:"heightUnit"`
+	Weight     int       `json:"weight"`
+	Height     int       `json:"height"`
+	Version    int       `json:"version"`
+}
+
+// ProfileUpdate is a profile before and after an update
+type ProfileUpdate struct {
+	Previous Profile `json:"previous"`
+	Current  Profile `json:"current"`
+}
+
+func NewActivity(activity entity.Activity) Activity {
+	return Activity{
+		ID:                activity.ID,
+		UserID:            activity.UserID,
+		ActivityType:      activity.ActivityType,
+		DoneAt:            activity.DoneAt,
+		DurationInMinutes: activity.DurationInMinutes,
+		CaloriesBurned:    activity.CaloriesBurned,
+		Version:           activity.Version,
+	}
+}
+
+func NewProfile(user entity.User) Profile {
+	return Profile{
+		UserID:     user.ID,
+		Name:       user.Name,
+		Preference: user.Preference,
+		WeightUnit: user.WeightUnit,
+		HeightUnit: user.HeightUnit,
+		Weight:     user.Weight,
+		Height:     user.Height,
+		Version:    user.Version,
+	}
+}
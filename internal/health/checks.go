@@ -0,0 +1,61 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fikrialwan/FitByte/internal/service"
+	"gorm.io/gorm"
+)
+
+// DatabaseCheck pings Postgres. Nothing works without it, so it is critical.
+func DatabaseCheck(db *gorm.DB) Check {
+	return Check{
+		Name:     "database",
+		Critical: true,
+		Run: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return fmt.Errorf("failed to get database instance: %w", err)
+			}
+			return sqlDB.PingContext(ctx)
+		},
+	}
+}
+
+// CacheCheck writes, reads back and deletes a key. Requests fall back to the
+// database when the cache is down, so it isn't critical.
+func CacheCheck(cacheService service.CacheService) Check {
+	// One key per replica so concurrent checks don't delete each other's
+	hostname, _ := os.Hostname()
+	key := "health_check:" + hostname
+
+	return Check{
+		Name: "redis",
+		Run: func(ctx context.Context) error {
+			if err := cacheService.Set(ctx, key, "ping", 10*time.Second); err != nil {
+				return fmt.Errorf("set failed: %w", err)
+			}
+			value, err := cacheService.Get(ctx, key)
+			if err != nil {
+				return fmt.Errorf("get failed: %w", err)
+			}
+			if value != "ping" {
+				return errors.New("get returned an unexpected value")
+			}
+			return cacheService.Delete(ctx, key)
+		},
+	}
+}
+
+// StorageCheck lists the MinIO buckets. Only uploads need it, presigned URLs
+// are signed locally, so it isn't critical.
+func StorageCheck(fileService service.FileService) Check {
+	return Check{
+		Name: "minio",
+		Run:  fileService.CheckConnectivity,
+	}
+}
@@ -0,0 +1,184 @@
+// Package health runs the readiness checks of the API's dependencies. Checks
+// run concurrently, each bounded by its own deadline, and their results are
+// cached briefly so frequent probes don't hammer the dependencies.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	StatusHealthy   = "healthy"
+	StatusUnhealthy = "unhealthy"
+
+	StatusReady    = "ready"
+	StatusDegraded = "degraded"
+	StatusNotReady = "not ready"
+)
+
+// Check probes a single dependency. A failing critical check makes the
+// service not ready, a failing non-critical one only degrades it.
+type Check struct {
+	Name     string
+	Critical bool
+	// Timeout overrides the checker's default deadline when set
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+type Result struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs float64   `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+func (r Result) Healthy() bool {
+	return r.Status == StatusHealthy
+}
+
+type Report struct {
+	Status    string            `json:"status"`
+	Timestamp time.Time         `json:"timestamp"`
+	Checks    map[string]Result `json:"checks"`
+}
+
+// Ready reports whether every critical check passed
+func (r Report) Ready() bool {
+	return r.Status != StatusNotReady
+}
+
+type Checker struct {
+	checks  []*registeredCheck
+	byName  map[string]*registeredCheck
+	timeout time.Duration
+	ttl     time.Duration
+}
+
+type registeredCheck struct {
+	Check
+
+	// mu serializes runs so concurrent probes share one result instead of
+	// each hitting the dependency
+	mu     sync.Mutex
+	result Result
+}
+
+// NewChecker returns a checker running checks with the given default timeout
+// and reusing results for ttl
+func NewChecker(timeout, ttl time.Duration, checks ...Check) *Checker {
+	c := &Checker{
+		byName:  make(map[string]*registeredCheck, len(checks)),
+		timeout: timeout,
+		ttl:     ttl,
+	}
+	for _, check := range checks {
+		registered := &registeredCheck{Check: check}
+		c.checks = append(c.checks, registered)
+		c.byName[check.Name] = registered
+	}
+	return c
+}
+
+// Names lists the registered checks in registration order
+func (c *Checker) Names() []string {
+	names := make([]string, len(c.checks))
+	for i, check := range c.checks {
+		names[i] = check.Name
+	}
+	return names
+}
+
+// CheckAll runs every check concurrently
+func (c *Checker) CheckAll(ctx context.Context) Report {
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:    StatusReady,
+		Timestamp: time.Now().UTC(),
+		Checks:    make(map[string]Result, len(results)),
+	}
+	for _, result := range results {
+		report.Checks[result.Name] = result
+		switch {
+		case result.Healthy():
+		case result.Critical:
+			report.Status = StatusNotReady
+		case report.Status == StatusReady:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// CheckOne runs the named check. It returns false when no such check exists.
+func (c *Checker) CheckOne(ctx context.Context, name string) (Result, bool) {
+	check, ok := c.byName[name]
+	if !ok {
+		return Result{}, false
+	}
+	return c.run(ctx, check), true
+}
+
+func (c *Checker) run(ctx context.Context, check *registeredCheck) Result {
+	check.mu.Lock()
+	defer check.mu.Unlock()
+
+	if !check.result.CheckedAt.IsZero() && time.Since(check.result.CheckedAt) < c.ttl {
+		return check.result
+	}
+
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = c.timeout
+	}
+
+	// The result is shared with other probes, so it must not be cut short
+	// because the probe that triggered it went away
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	// A check that ignores its context is abandoned at the deadline so it
+	// can't hold up the probes
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	result := Result{
+		Name:      check.Name,
+		Status:    StatusHealthy,
+		Critical:  check.Critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		result.Status = StatusUnhealthy
+		result.Error = err.Error()
+	}
+
+	check.result = result
+	return result
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys match attribute, header and query parameter names after they
+// are lowercased and stripped of '-' and '_', so "Authorization",
+// "refresh_token" and "X-Api-Key" are all covered
+var sensitiveKeys = []string{"password", "passwd", "token", "secret", "authorization", "cookie", "apikey", "accesskey", "credential"}
+
+// IsSensitive reports whether a value named key must not be logged
+func IsSensitive(key string) bool {
+	key = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactQuery returns the raw query with the values of sensitive parameters
+// replaced. Unparseable queries are dropped entirely.
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return redacted
+	}
+	for key := range values {
+		if IsSensitive(key) {
+			values[key] = []string{redacted}
+		}
+	}
+	return values.Encode()
+}
+
+// redact is the slog ReplaceAttr hook. It hides sensitive attributes by name,
+// bearer credentials by value and sensitive headers of a logged http.Header.
+func redact(_ []string, attr slog.Attr) slog.Attr {
+	if IsSensitive(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		if value := attr.Value.String(); len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+			return slog.String(attr.Key, redacted)
+		}
+	case slog.KindAny:
+		if header, ok := attr.Value.Any().(http.Header); ok {
+			return slog.Any(attr.Key, redactHeader(header))
+		}
+	}
+	return attr
+}
+
+func redactHeader(header http.Header) http.Header {
+	clean := header.Clone()
+	for name := range clean {
+		if IsSensitive(name) {
+			clean[name] = []string{redacted}
+		}
+	}
+	return clean
+}
@@ -1,22 +1,61 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/fikrialwan/FitByte/internal/dto"
 	"github.com/fikrialwan/FitByte/internal/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type ActivityRepository struct {
-	db *gorm.DB
+// ActivityRepository stores the activities of every user. Lookups are scoped
+// to a user and return dto.ErrActivityNotFound for other users' activities.
+// Deleted activities go to the trash, where only GetTrash, RestoreActivity and
+// PurgeTrash see them.
+type ActivityRepository interface {
+	// GetActivity returns a page of the user's activities, 5 by default
+	GetActivity(ctx context.Context, filter dto.ActivityFilter, userID string) ([]entity.Activity, error)
+	// GetActivitySummary aggregates the user's activities per type, ordered by type
+	GetActivitySummary(ctx context.Context, filter dto.ActivitySummaryFilter, userID string) ([]dto.ActivityTypeSummary, error)
+	CreateActivity(ctx context.Context, activity entity.Activity) (entity.Activity, error)
+	GetActivityByID(ctx context.Context, activityID, userID string) (entity.Activity, error)
+	// UpdateActivity saves the activity if it is still at activity.Version,
+	// returning it with the next version. It fails with dto.ErrVersionConflict
+	// when it was updated or deleted in the meantime.
+	UpdateActivity(ctx context.Context, activity entity.Activity) (entity.Activity, error)
+	// DeleteActivity moves the activity to the trash, returning it as it was
+	DeleteActivity(ctx context.Context, activityID, userID string) (entity.Activity, error)
+	// GetTrash returns a page of the user's trashed activities, most recently
+	// deleted first, 5 by default
+	GetTrash(ctx context.Context, filter dto.TrashFilter, userID string) ([]entity.Activity, error)
+	// RestoreActivity takes the activity out of the trash, returning it with
+	// the next version. Activities not in the trash are dto.ErrActivityNotFound.
+	RestoreActivity(ctx context.Context, activityID, userID string) (entity.Activity, error)
+	// PurgeTrash permanently deletes up to limit activities of any user that
+	// were trashed before deletedBefore, returning how many it deleted
+	PurgeTrash(ctx context.Context, deletedBefore time.Time, limit int) (int64, error)
+}
+
+type activityRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
 }
 
-func NewActivityRepository(db *gorm.DB) ActivityRepository {
-	return ActivityRepository{db}
+// NewActivityRepository returns a Postgres repository bounding each query by
+// queryTimeout, on top of any deadline of the caller's context
+func NewActivityRepository(db *gorm.DB, queryTimeout time.Duration) ActivityRepository {
+	return activityRepository{db: db, queryTimeout: queryTimeout}
 }
 
-func (r ActivityRepository) GetActivity(filter dto.ActivityFilter, userID string) ([]entity.Activity, error) {
+func (r activityRepository) GetActivity(ctx context.Context, filter dto.ActivityFilter, userID string) ([]entity.Activity, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var activities []entity.Activity
-	query := r.db.Model(&entity.Activity{}).Where("user_id = ?", userID)
+	query := conn(ctx, r.db).WithContext(ctx).Model(&entity.Activity{}).Where("user_id = ?", userID)
 
 	if filter.ActivityType != "" {
 		query = query.Where("activity_type = ?", filter.ActivityType)
@@ -50,8 +89,35 @@ func (r ActivityRepository) GetActivity(filter dto.ActivityFilter, userID string
 	return activities, nil
 }
 
-func (r ActivityRepository) CreateActivity(activity entity.Activity) (entity.Activity, error) {
-	result := r.db.Create(&activity)
+func (r activityRepository) GetActivitySummary(ctx context.Context, filter dto.ActivitySummaryFilter, userID string) ([]dto.ActivityTypeSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var summaries []dto.ActivityTypeSummary
+	query := conn(ctx, r.db).WithContext(ctx).Model(&entity.Activity{}).
+		Select("activity_type, COUNT(*) AS total_activities, "+
+			"COALESCE(SUM(duration_in_minutes), 0) AS total_duration_in_minutes, "+
+			"COALESCE(SUM(calories_burned), 0) AS total_calories_burned").
+		Where("user_id = ?", userID)
+
+	if !filter.DoneAtFrom.IsZero() {
+		query = query.Where("done_at >= ?", filter.DoneAtFrom)
+	}
+	if !filter.DoneAtTo.IsZero() {
+		query = query.Where("done_at <= ?", filter.DoneAtTo)
+	}
+
+	if err := query.Group("activity_type").Order("activity_type").Scan(&summaries).Error; err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+func (r activityRepository) CreateActivity(ctx context.Context, activity entity.Activity) (entity.Activity, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := conn(ctx, r.db).WithContext(ctx).Create(&activity)
 
 	if result.Error != nil {
 		return entity.Activity{}, result.Error
@@ -60,38 +126,117 @@ func (r ActivityRepository) CreateActivity(activity entity.Activity) (entity.Act
 	return activity, nil
 }
 
-func (r ActivityRepository) GetActivityByID(activityID, userID string) (entity.Activity, error) {
+func (r activityRepository) GetActivityByID(ctx context.Context, activityID, userID string) (entity.Activity, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var activity entity.Activity
-	result := r.db.Where("id = ? AND user_id = ?", activityID, userID).First(&activity)
+	result := conn(ctx, r.db).WithContext(ctx).Where("id = ? AND user_id = ?", activityID, userID).First(&activity)
 
-	if result.Error != nil {
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return entity.Activity{}, dto.ErrActivityNotFound
+	} else if result.Error != nil {
 		return entity.Activity{}, result.Error
 	}
 
 	return activity, nil
 }
 
-func (r ActivityRepository) UpdateActivity(activity entity.Activity) (entity.Activity, error) {
-	result := r.db.Save(&activity)
+func (r activityRepository) UpdateActivity(ctx context.Context, activity entity.Activity) (entity.Activity, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	readVersion := activity.Version
+	activity.Version++
+	result := conn(ctx, r.db).WithContext(ctx).Model(&activity).
+		Where("user_id = ? AND version = ?", activity.UserID, readVersion).
+		Select("activity_type", "done_at", "duration_in_minutes", "calories_burned", "version", "updated_at").
+		Updates(&activity)
 
 	if result.Error != nil {
 		return entity.Activity{}, result.Error
 	}
+	if result.RowsAffected == 0 {
+		return entity.Activity{}, dto.ErrVersionConflict
+	}
 
 	return activity, nil
 }
 
-func (r ActivityRepository) DeleteActivity(activityID, userID string) error {
-	result := r.db.Where("id = ? AND user_id = ?", activityID, userID).Delete(&entity.Activity{})
+func (r activityRepository) DeleteActivity(ctx context.Context, activityID, userID string) (entity.Activity, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var activity entity.Activity
+	result := conn(ctx, r.db).WithContext(ctx).
+		Where("id = ? AND user_id = ?", activityID, userID).
+		Clauses(clause.Returning{}).
+		Delete(&activity)
 
 	if result.Error != nil {
-		return result.Error
+		return entity.Activity{}, result.Error
 	}
 
 	// Check if any rows were affected (activity existed and was deleted)
 	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+		return entity.Activity{}, dto.ErrActivityNotFound
 	}
 
-	return nil
+	return activity, nil
+}
+
+func (r activityRepository) GetTrash(ctx context.Context, filter dto.TrashFilter, userID string) ([]entity.Activity, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 5
+	}
+	offset := max(filter.Offset, 0)
+
+	var activities []entity.Activity
+	err := conn(ctx, r.db).WithContext(ctx).Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC, id").
+		Limit(limit).Offset(offset).
+		Find(&activities).Error
+	if err != nil {
+		return nil, err
+	}
+	return activities, nil
+}
+
+func (r activityRepository) RestoreActivity(ctx context.Context, activityID, userID string) (entity.Activity, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var activity entity.Activity
+	result := conn(ctx, r.db).WithContext(ctx).Unscoped().Model(&activity).
+		Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", activityID, userID).
+		Clauses(clause.Returning{}).
+		Updates(map[string]any{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+
+	if result.Error != nil {
+		return entity.Activity{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.Activity{}, dto.ErrActivityNotFound
+	}
+
+	return activity, nil
+}
+
+func (r activityRepository) PurgeTrash(ctx context.Context, deletedBefore time.Time, limit int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	// Postgres has no DELETE ... LIMIT, the batch is picked by a subquery
+	batch := r.db.Unscoped().Model(&entity.Activity{}).
+		Select("id").
+		Where("deleted_at < ?", deletedBefore).
+		Limit(limit)
+	result := conn(ctx, r.db).WithContext(ctx).Unscoped().Where("id IN (?)", batch).Delete(&entity.Activity{})
+
+	return result.RowsAffected, result.Error
 }
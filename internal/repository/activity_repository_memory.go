@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryActivityRepository keeps activities in insertion order, which is the
+// order Postgres returns them in without an ORDER BY on a fresh table
+type memoryActivityRepository struct {
+	mu         sync.RWMutex
+	activities []entity.Activity
+	clock      clock.Clock
+}
+
+// NewMemoryActivityRepository returns an ActivityRepository held in memory,
+// for tests and local runs without Postgres
+func NewMemoryActivityRepository(clk clock.Clock) ActivityRepository {
+	return &memoryActivityRepository{clock: clk}
+}
+
+func (r *memoryActivityRepository) GetActivity(ctx context.Context, filter dto.ActivityFilter, userID string) ([]entity.Activity, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []entity.Activity
+	for _, activity := range r.activities {
+		if activity.UserID.String() != userID || activity.DeletedAt.Valid {
+			continue
+		}
+		if filter.ActivityType != "" && string(activity.ActivityType) != filter.ActivityType {
+			continue
+		}
+		if !inRange(activity.DoneAt, filter.DoneAtFrom, filter.DoneAtTo) {
+			continue
+		}
+		if filter.CaloriesBurnedMin > 0 && activity.CaloriesBurned < filter.CaloriesBurnedMin {
+			continue
+		}
+		if filter.CaloriesBurnedMax > 0 && activity.CaloriesBurned > filter.CaloriesBurnedMax {
+			continue
+		}
+		matched = append(matched, activity)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 5
+	}
+	offset := max(filter.Offset, 0)
+	if offset >= len(matched) {
+		return []entity.Activity{}, nil
+	}
+	return slices.Clone(matched[offset:min(offset+limit, len(matched))]), nil
+}
+
+func (r *memoryActivityRepository) GetActivitySummary(ctx context.Context, filter dto.ActivitySummaryFilter, userID string) ([]dto.ActivityTypeSummary, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	byType := make(map[entity.ActivityType]*dto.ActivityTypeSummary)
+	for _, activity := range r.activities {
+		if activity.UserID.String() != userID || activity.DeletedAt.Valid || !inRange(activity.DoneAt, filter.DoneAtFrom, filter.DoneAtTo) {
+			continue
+		}
+		summary, ok := byType[activity.ActivityType]
+		if !ok {
+			summary = &dto.ActivityTypeSummary{ActivityType: activity.ActivityType}
+			byType[activity.ActivityType] = summary
+		}
+		summary.TotalActivities++
+		summary.TotalDurationInMinutes += activity.DurationInMinutes
+		summary.TotalCaloriesBurned += activity.CaloriesBurned
+	}
+
+	summaries := make([]dto.ActivityTypeSummary, 0, len(byType))
+	for _, summary := range byType {
+		summaries = append(summaries, *summary)
+	}
+	slices.SortFunc(summaries, func(a, b dto.ActivityTypeSummary) int {
+		return strings.Compare(string(a.ActivityType), string(b.ActivityType))
+	})
+	return summaries, nil
+}
+
+func (r *memoryActivityRepository) CreateActivity(ctx context.Context, activity entity.Activity) (entity.Activity, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.Activity{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if activity.ID == uuid.Nil {
+		activity.ID = uuid.New()
+	}
+	now := r.clock.Now()
+	if activity.CreatedAt.IsZero() {
+		activity.CreatedAt = now
+	}
+	if activity.UpdatedAt.IsZero() {
+		activity.UpdatedAt = now
+	}
+	if activity.Version == 0 {
+		activity.Version = 1
+	}
+
+	r.activities = append(r.activities, activity)
+	onRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.activities = slices.DeleteFunc(r.activities, func(stored entity.Activity) bool {
+			return stored.ID == activity.ID
+		})
+	})
+	return activity, nil
+}
+
+func (r *memoryActivityRepository) GetActivityByID(ctx context.Context, activityID, userID string) (entity.Activity, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.Activity{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if i := r.index(activityID, userID); i >= 0 {
+		return r.activities[i], nil
+	}
+	return entity.Activity{}, dto.ErrActivityNotFound
+}
+
+// UpdateActivity saves every field when the stored activity is at the same
+// version
+func (r *memoryActivityRepository) UpdateActivity(ctx context.Context, activity entity.Activity) (entity.Activity, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.Activity{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(activity.ID.String(), activity.UserID.String())
+	if i < 0 || r.activities[i].Version != activity.Version {
+		return entity.Activity{}, dto.ErrVersionConflict
+	}
+
+	previous := r.activities[i]
+	activity.UpdatedAt = r.clock.Now()
+	activity.Version++
+	r.activities[i] = activity
+	onRollback(ctx, func() { r.replace(previous) })
+	return activity, nil
+}
+
+func (r *memoryActivityRepository) DeleteActivity(ctx context.Context, activityID, userID string) (entity.Activity, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.Activity{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(activityID, userID)
+	if i < 0 {
+		return entity.Activity{}, dto.ErrActivityNotFound
+	}
+	previous := r.activities[i]
+	r.activities[i].DeletedAt = gorm.DeletedAt{Time: r.clock.Now(), Valid: true}
+	onRollback(ctx, func() { r.replace(previous) })
+	return r.activities[i], nil
+}
+
+func (r *memoryActivityRepository) GetTrash(ctx context.Context, filter dto.TrashFilter, userID string) ([]entity.Activity, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var trashed []entity.Activity
+	for _, activity := range r.activities {
+		if activity.UserID.String() == userID && activity.DeletedAt.Valid {
+			trashed = append(trashed, activity)
+		}
+	}
+	slices.SortStableFunc(trashed, func(a, b entity.Activity) int {
+		return b.DeletedAt.Time.Compare(a.DeletedAt.Time)
+	})
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 5
+	}
+	offset := max(filter.Offset, 0)
+	if offset >= len(trashed) {
+		return []entity.Activity{}, nil
+	}
+	return trashed[offset:min(offset+limit, len(trashed))], nil
+}
+
+func (r *memoryActivityRepository) RestoreActivity(ctx context.Context, activityID, userID string) (entity.Activity, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.Activity{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.activities, func(activity entity.Activity) bool {
+		return activity.ID.String() == activityID && activity.UserID.String() == userID && activity.DeletedAt.Valid
+	})
+	if i < 0 {
+		return entity.Activity{}, dto.ErrActivityNotFound
+	}
+
+	previous := r.activities[i]
+	onRollback(ctx, func() { r.replace(previous) })
+	activity := &r.activities[i]
+	activity.DeletedAt = gorm.DeletedAt{}
+	activity.UpdatedAt = r.clock.Now()
+	activity.Version++
+	return *activity, nil
+}
+
+func (r *memoryActivityRepository) PurgeTrash(ctx context.Context, deletedBefore time.Time, limit int) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	r.activities = slices.DeleteFunc(r.activities, func(activity entity.Activity) bool {
+		if purged < int64(limit) && activity.DeletedAt.Valid && activity.DeletedAt.Time.Before(deletedBefore) {
+			purged++
+			return true
+		}
+		return false
+	})
+	return purged, nil
+}
+
+// replace puts activity back in place of the stored one with its ID, undoing
+// a write
+func (r *memoryActivityRepository) replace(activity entity.Activity) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := slices.IndexFunc(r.activities, func(stored entity.Activity) bool { return stored.ID == activity.ID }); i >= 0 {
+		r.activities[i] = activity
+	}
+}
+
+// index returns the position of the user's activity, or -1 when there is
+// none or it is in the trash. Callers hold mu.
+func (r *memoryActivityRepository) index(activityID, userID string) int {
+	return slices.IndexFunc(r.activities, func(activity entity.Activity) bool {
+		return activity.ID.String() == activityID && activity.UserID.String() == userID && !activity.DeletedAt.Valid
+	})
+}
+
+// inRange applies the inclusive doneAt bounds of the filters, zero bounds are
+// open
+func inRange(doneAt, from, to time.Time) bool {
+	if !from.IsZero() && doneAt.Before(from) {
+		return false
+	}
+	if !to.IsZero() && doneAt.After(to) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/google/uuid"
+)
+
+func TestMemoryActivityRepositoryGetActivity(t *testing.T) {
+	repo := repository.NewMemoryActivityRepository(clock.System)
+	userID := uuid.New()
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 8, 0, 0, 0, time.UTC) }
+
+	seed := []entity.Activity{
+		{ActivityType: entity.Running, DoneAt: day(1), DurationInMinutes: 30, CaloriesBurned: 300},
+		{ActivityType: entity.Yoga, DoneAt: day(2), DurationInMinutes: 60, CaloriesBurned: 240},
+		{ActivityType: entity.Running, DoneAt: day(3), DurationInMinutes: 10, CaloriesBurned: 100},
+		{ActivityType: entity.Cycling, DoneAt: day(4), DurationInMinutes: 45, CaloriesBurned: 360},
+		{ActivityType: entity.Walking, DoneAt: day(5), DurationInMinutes: 20, CaloriesBurned: 80},
+		{ActivityType: entity.HIIT, DoneAt: day(6), DurationInMinutes: 15, CaloriesBurned: 150},
+	}
+	for _, activity := range seed {
+		activity.UserID = userID
+		if _, err := repo.CreateActivity(context.Background(), activity); err != nil {
+			t.Fatalf("CreateActivity: %v", err)
+		}
+	}
+	// Another user's activity must never show up
+	if _, err := repo.CreateActivity(context.Background(), entity.Activity{UserID: uuid.New(), ActivityType: entity.Running, DoneAt: day(1)}); err != nil {
+		t.Fatalf("CreateActivity: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		filter    dto.ActivityFilter
+		wantTypes []entity.ActivityType
+	}{
+		{name: "default page of 5", filter: dto.ActivityFilter{}, wantTypes: []entity.ActivityType{entity.Running, entity.Yoga, entity.Running, entity.Cycling, entity.Walking}},
+		{name: "offset", filter: dto.ActivityFilter{Limit: 2, Offset: 4}, wantTypes: []entity.ActivityType{entity.Walking, entity.HIIT}},
+		{name: "offset past the end", filter: dto.ActivityFilter{Offset: 10}, wantTypes: []entity.ActivityType{}},
+		{name: "type", filter: dto.ActivityFilter{ActivityType: "Running"}, wantTypes: []entity.ActivityType{entity.Running, entity.Running}},
+		{name: "inclusive date range", filter: dto.ActivityFilter{DoneAtFrom: day(2), DoneAtTo: day(4)}, wantTypes: []entity.ActivityType{entity.Yoga, entity.Running, entity.Cycling}},
+		{name: "calories range", filter: dto.ActivityFilter{CaloriesBurnedMin: 150, CaloriesBurnedMax: 300}, wantTypes: []entity.ActivityType{entity.Running, entity.Yoga, entity.HIIT}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activities, err := repo.GetActivity(context.Background(), tt.filter, userID.String())
+			if err != nil {
+				t.Fatalf("GetActivity: %v", err)
+			}
+
+			got := make([]entity.ActivityType, len(activities))
+			for i, activity := range activities {
+				got[i] = activity.ActivityType
+			}
+			if len(got) != len(tt.wantTypes) {
+				t.Fatalf("got %v, want %v", got, tt.wantTypes)
+			}
+			for i := range got {
+				if got[i] != tt.wantTypes[i] {
+					t.Fatalf("got %v, want %v", got, tt.wantTypes)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryActivityRepositoryPurgeTrash(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC))
+	repo := repository.NewMemoryActivityRepository(clk)
+	userID := uuid.New()
+
+	var ids []string
+	for range 5 {
+		activity, err := repo.CreateActivity(context.Background(), entity.Activity{UserID: userID, ActivityType: entity.Running})
+		if err != nil {
+			t.Fatalf("CreateActivity: %v", err)
+		}
+		ids = append(ids, activity.ID.String())
+	}
+	// Four in the trash, the last deleted after the cutoff
+	for i, id := range ids[:4] {
+		if i == 3 {
+			clk.Advance(time.Hour)
+		}
+		if _, err := repo.DeleteActivity(context.Background(), id, userID.String()); err != nil {
+			t.Fatalf("DeleteActivity: %v", err)
+		}
+	}
+	cutoff := clk.Now()
+
+	// Batches never exceed the limit
+	for _, want := range []int64{2, 1, 0} {
+		purged, err := repo.PurgeTrash(context.Background(), cutoff, 2)
+		if err != nil {
+			t.Fatalf("PurgeTrash: %v", err)
+		}
+		if purged != want {
+			t.Fatalf("purged %d, want %d", purged, want)
+		}
+	}
+
+	trash, err := repo.GetTrash(context.Background(), dto.TrashFilter{}, userID.String())
+	if err != nil {
+		t.Fatalf("GetTrash: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID.String() != ids[3] {
+		t.Errorf("trash = %+v, want the activity deleted after the cutoff", trash)
+	}
+	if _, err := repo.GetActivityByID(context.Background(), ids[4], userID.String()); err != nil {
+		t.Errorf("activity never deleted: %v", err)
+	}
+}
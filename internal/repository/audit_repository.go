@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"gorm.io/gorm"
+)
+
+// AuditRepository is the append-only log of changes to users' data. There is
+// deliberately no way to change or remove an entry.
+type AuditRepository interface {
+	// Append stores entry, assigning its ID and time. Called within a
+	// transaction it is only kept if the change it records is.
+	Append(ctx context.Context, entry *entity.AuditEntry) error
+	// Search returns the entries matching filter, most recent first, 20 by
+	// default
+	Search(ctx context.Context, filter dto.AuditFilter) ([]entity.AuditEntry, error)
+}
+
+type auditRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewAuditRepository returns a Postgres repository bounding each query by
+// queryTimeout, on top of any deadline of the caller's context
+func NewAuditRepository(db *gorm.DB, queryTimeout time.Duration) AuditRepository {
+	return auditRepository{db: db, queryTimeout: queryTimeout}
+}
+
+func (r auditRepository) Append(ctx context.Context, entry *entity.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return conn(ctx, r.db).WithContext(ctx).Create(entry).Error
+}
+
+func (r auditRepository) Search(ctx context.Context, filter dto.AuditFilter) ([]entity.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := conn(ctx, r.db).WithContext(ctx).Model(&entity.AuditEntry{})
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.OwnerID != "" {
+		query = query.Where("owner_id = ?", filter.OwnerID)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at <= ?", filter.To)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	offset := max(filter.Offset, 0)
+
+	var entries []entity.AuditEntry
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/google/uuid"
+)
+
+// memoryAuditRepository keeps entries in the order they were appended
+type memoryAuditRepository struct {
+	mu      sync.RWMutex
+	entries []entity.AuditEntry
+	clock   clock.Clock
+}
+
+// NewMemoryAuditRepository returns an AuditRepository held in memory, for
+// tests and local runs without Postgres
+func NewMemoryAuditRepository(clk clock.Clock) AuditRepository {
+	return &memoryAuditRepository{clock: clk}
+}
+
+func (r *memoryAuditRepository) Append(ctx context.Context, entry *entity.AuditEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = r.clock.Now()
+	}
+	r.entries = append(r.entries, *entry)
+
+	id := entry.ID
+	onRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.entries = slices.DeleteFunc(r.entries, func(stored entity.AuditEntry) bool {
+			return stored.ID == id
+		})
+	})
+	return nil
+}
+
+func (r *memoryAuditRepository) Search(ctx context.Context, filter dto.AuditFilter) ([]entity.AuditEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []entity.AuditEntry
+	for _, entry := range slices.Backward(r.entries) {
+		if filter.ActorID != "" && entry.ActorID.String() != filter.ActorID ||
+			filter.OwnerID != "" && entry.OwnerID.String() != filter.OwnerID ||
+			filter.EntityType != "" && entry.EntityType != filter.EntityType ||
+			filter.EntityID != "" && entry.EntityID.String() != filter.EntityID ||
+			filter.Action != "" && string(entry.Action) != filter.Action ||
+			!inRange(entry.CreatedAt, filter.From, filter.To) {
+			continue
+		}
+		matched = append(matched, entry)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	offset := max(filter.Offset, 0)
+	if offset >= len(matched) {
+		return []entity.AuditEntry{}, nil
+	}
+	return matched[offset:min(offset+limit, len(matched))], nil
+}
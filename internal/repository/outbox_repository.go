@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrOutboxLeaseLost is returned when the claim of an event expired and it
+// was claimed again, the outcome is left to the new claim
+var ErrOutboxLeaseLost = errors.New("outbox event lease lost")
+
+// OutboxRepository stores domain events until they are delivered. Renew,
+// MarkPublished, Retry and MarkFailed only change an event still claimed with
+// the given token, otherwise they return ErrOutboxLeaseLost.
+type OutboxRepository interface {
+	// Append stores a pending event, due right away. Called within a
+	// transaction it is only kept if the change it describes is.
+	Append(ctx context.Context, event *entity.OutboxEvent) error
+	// Claim returns up to limit pending events due at now, oldest first,
+	// counting an attempt for each and leasing them until leaseUntil under a
+	// new ClaimToken. Events claimed by one dispatcher aren't claimed by
+	// another before the lease expires, so a dispatcher that dies has its
+	// events delivered again.
+	Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]entity.OutboxEvent, error)
+	// Renew extends the lease of claimToken until leaseUntil
+	Renew(ctx context.Context, id, claimToken uuid.UUID, leaseUntil time.Time) error
+	// MarkPublished records that every handler succeeded
+	MarkPublished(ctx context.Context, id, claimToken uuid.UUID, at time.Time) error
+	// Retry schedules another attempt at nextAttemptAt
+	Retry(ctx context.Context, id, claimToken uuid.UUID, nextAttemptAt time.Time, lastError string) error
+	// MarkFailed gives up on the event, it is kept but never claimed again
+	MarkFailed(ctx context.Context, id, claimToken uuid.UUID, at time.Time, lastError string) error
+	// PurgePublished deletes up to limit events published before
+	// publishedBefore and returns how many it deleted
+	PurgePublished(ctx context.Context, publishedBefore time.Time, limit int) (int64, error)
+}
+
+type outboxRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewOutboxRepository returns a Postgres repository bounding each query by
+// queryTimeout, on top of any deadline of the caller's context
+func NewOutboxRepository(db *gorm.DB, queryTimeout time.Duration) OutboxRepository {
+	return outboxRepository{db: db, queryTimeout: queryTimeout}
+}
+
+func (r outboxRepository) Append(ctx context.Context, event *entity.OutboxEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if event.NextAttemptAt.IsZero() {
+		event.NextAttemptAt = r.db.NowFunc()
+	}
+	return conn(ctx, r.db).WithContext(ctx).Create(event).Error
+}
+
+func (r outboxRepository) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]entity.OutboxEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	// Rows locked by a concurrent claim are skipped rather than waited for
+	batch := r.db.Model(&entity.OutboxEvent{}).
+		Select("id").
+		Where("published_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", now).
+		Order("next_attempt_at, created_at").
+		Limit(limit).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+
+	var claimed []entity.OutboxEvent
+	err := conn(ctx, r.db).WithContext(ctx).Model(&claimed).
+		Clauses(clause.Returning{}).
+		Where("id IN (?)", batch).
+		Updates(map[string]any{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": leaseUntil,
+			"claim_token":     uuid.New(),
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// RETURNING follows no particular order
+	slices.SortFunc(claimed, func(a, b entity.OutboxEvent) int {
+		return cmp.Compare(a.CreatedAt.UnixNano(), b.CreatedAt.UnixNano())
+	})
+	return claimed, nil
+}
+
+func (r outboxRepository) Renew(ctx context.Context, id, claimToken uuid.UUID, leaseUntil time.Time) error {
+	return r.update(ctx, id, claimToken, map[string]any{"next_attempt_at": leaseUntil})
+}
+
+func (r outboxRepository) MarkPublished(ctx context.Context, id, claimToken uuid.UUID, at time.Time) error {
+	return r.update(ctx, id, claimToken, map[string]any{"published_at": at, "last_error": ""})
+}
+
+func (r outboxRepository) Retry(ctx context.Context, id, claimToken uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	return r.update(ctx, id, claimToken, map[string]any{"next_attempt_at": nextAttemptAt, "last_error": lastError})
+}
+
+func (r outboxRepository) MarkFailed(ctx context.Context, id, claimToken uuid.UUID, at time.Time, lastError string) error {
+	return r.update(ctx, id, claimToken, map[string]any{"failed_at": at, "last_error": lastError})
+}
+
+// update changes a pending event still claimed with claimToken
+func (r outboxRepository) update(ctx context.Context, id, claimToken uuid.UUID, values map[string]any) error {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	result := conn(ctx, r.db).WithContext(ctx).Model(&entity.OutboxEvent{}).
+		Where("id = ? AND claim_token = ? AND published_at IS NULL AND failed_at IS NULL", id, claimToken).
+		Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOutboxLeaseLost
+	}
+	return nil
+}
+
+func (r outboxRepository) PurgePublished(ctx context.Context, publishedBefore time.Time, limit int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	// Postgres has no DELETE ... LIMIT, the batch is picked by a subquery
+	batch := r.db.Model(&entity.OutboxEvent{}).
+		Select("id").
+		Where("published_at < ?", publishedBefore).
+		Limit(limit)
+	result := conn(ctx, r.db).WithContext(ctx).Where("id IN (?)", batch).Delete(&entity.OutboxEvent{})
+
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/google/uuid"
+)
+
+// memoryOutboxRepository keeps events in the order they were appended
+type memoryOutboxRepository struct {
+	mu     sync.Mutex
+	events []entity.OutboxEvent
+	clock  clock.Clock
+}
+
+// NewMemoryOutboxRepository returns an OutboxRepository held in memory, for
+// tests and local runs without Postgres
+func NewMemoryOutboxRepository(clk clock.Clock) OutboxRepository {
+	return &memoryOutboxRepository{clock: clk}
+}
+
+func (r *memoryOutboxRepository) Append(ctx context.Context, event *entity.OutboxEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = r.clock.Now()
+	}
+	if event.NextAttemptAt.IsZero() {
+		event.NextAttemptAt = event.CreatedAt
+	}
+	r.events = append(r.events, *event)
+
+	id := event.ID
+	onRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = slices.DeleteFunc(r.events, func(stored entity.OutboxEvent) bool {
+			return stored.ID == id
+		})
+	})
+	return nil
+}
+
+func (r *memoryOutboxRepository) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]entity.OutboxEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	claimed := []entity.OutboxEvent{}
+	claimToken := uuid.New()
+	for i := range r.events {
+		event := &r.events[i]
+		if len(claimed) == limit {
+			break
+		}
+		if event.PublishedAt != nil || event.FailedAt != nil || event.NextAttemptAt.After(now) {
+			continue
+		}
+		event.Attempts++
+		event.NextAttemptAt = leaseUntil
+		event.ClaimToken = claimToken
+		claimed = append(claimed, *event)
+	}
+	return claimed, nil
+}
+
+func (r *memoryOutboxRepository) Renew(ctx context.Context, id, claimToken uuid.UUID, leaseUntil time.Time) error {
+	return r.update(ctx, id, claimToken, func(event *entity.OutboxEvent) {
+		event.NextAttemptAt = leaseUntil
+	})
+}
+
+func (r *memoryOutboxRepository) MarkPublished(ctx context.Context, id, claimToken uuid.UUID, at time.Time) error {
+	return r.update(ctx, id, claimToken, func(event *entity.OutboxEvent) {
+		event.PublishedAt = &at
+		event.LastError = ""
+	})
+}
+
+func (r *memoryOutboxRepository) Retry(ctx context.Context, id, claimToken uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	return r.update(ctx, id, claimToken, func(event *entity.OutboxEvent) {
+		event.NextAttemptAt = nextAttemptAt
+		event.LastError = lastError
+	})
+}
+
+func (r *memoryOutboxRepository) MarkFailed(ctx context.Context, id, claimToken uuid.UUID, at time.Time, lastError string) error {
+	return r.update(ctx, id, claimToken, func(event *entity.OutboxEvent) {
+		event.FailedAt = &at
+		event.LastError = lastError
+	})
+}
+
+// update changes a pending event still claimed with claimToken
+func (r *memoryOutboxRepository) update(ctx context.Context, id, claimToken uuid.UUID, fn func(event *entity.OutboxEvent)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.events, func(stored entity.OutboxEvent) bool { return stored.ID == id })
+	if i < 0 {
+		return ErrOutboxLeaseLost
+	}
+	event := &r.events[i]
+	if event.ClaimToken != claimToken || event.PublishedAt != nil || event.FailedAt != nil {
+		return ErrOutboxLeaseLost
+	}
+	fn(event)
+	return nil
+}
+
+func (r *memoryOutboxRepository) PurgePublished(ctx context.Context, publishedBefore time.Time, limit int) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	r.events = slices.DeleteFunc(r.events, func(event entity.OutboxEvent) bool {
+		if purged < int64(limit) && event.PublishedAt != nil && event.PublishedAt.Before(publishedBefore) {
+			purged++
+			return true
+		}
+		return false
+	})
+	return purged, nil
+}
@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/google/uuid"
+)
+
+func TestMemoryOutboxRepositoryClaim(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC))
+	repo := repository.NewMemoryOutboxRepository(clk)
+
+	for range 3 {
+		if err := repo.Append(context.Background(), &entity.OutboxEvent{Type: "activity.created", AggregateID: uuid.New(), Payload: []byte("{}")}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		clk.Advance(time.Second)
+	}
+
+	now := clk.Now()
+	first, err := repo.Claim(context.Background(), now, now.Add(time.Minute), 2)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if len(first) != 2 || first[0].Attempts != 1 || !first[0].CreatedAt.Before(first[1].CreatedAt) {
+		t.Fatalf("first claim = %+v, want the two oldest on their first attempt", first)
+	}
+
+	// Leased events are left to the dispatcher that claimed them
+	second, err := repo.Claim(context.Background(), now, now.Add(time.Minute), 10)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if len(second) != 1 {
+		t.Fatalf("second claim got %d events, want the unclaimed one", len(second))
+	}
+	if err := repo.MarkPublished(context.Background(), first[0].ID, first[0].ClaimToken, now); err != nil {
+		t.Fatalf("MarkPublished: %v", err)
+	}
+
+	// Once the lease expires, unpublished events are claimed again
+	later := now.Add(2 * time.Minute)
+	again, err := repo.Claim(context.Background(), later, later.Add(time.Minute), 10)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if len(again) != 2 || again[0].ID != first[1].ID || again[0].Attempts != 2 {
+		t.Errorf("claim after the lease = %+v, want the two unpublished, the oldest on its second attempt", again)
+	}
+}
+
+func TestMemoryOutboxRepositoryLeaseLost(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC))
+	repo := repository.NewMemoryOutboxRepository(clk)
+	if err := repo.Append(context.Background(), &entity.OutboxEvent{Type: "activity.created", AggregateID: uuid.New(), Payload: []byte("{}")}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	now := clk.Now()
+	stale, err := repo.Claim(context.Background(), now, now.Add(time.Minute), 1)
+	if err != nil || len(stale) != 1 {
+		t.Fatalf("Claim = %v, %v, want the event", stale, err)
+	}
+	// The lease expires and another dispatcher claims the event
+	later := now.Add(2 * time.Minute)
+	current, err := repo.Claim(context.Background(), later, later.Add(time.Minute), 1)
+	if err != nil || len(current) != 1 {
+		t.Fatalf("Claim = %v, %v, want the event again", current, err)
+	}
+
+	id, token := stale[0].ID, stale[0].ClaimToken
+	for name, err := range map[string]error{
+		"Renew":         repo.Renew(context.Background(), id, token, later.Add(time.Hour)),
+		"MarkPublished": repo.MarkPublished(context.Background(), id, token, later),
+		"Retry":         repo.Retry(context.Background(), id, token, later.Add(time.Hour), "stale"),
+		"MarkFailed":    repo.MarkFailed(context.Background(), id, token, later, "stale"),
+	} {
+		if !errors.Is(err, repository.ErrOutboxLeaseLost) {
+			t.Errorf("%s with the expired claim: err = %v, want %v", name, err, repository.ErrOutboxLeaseLost)
+		}
+	}
+
+	if err := repo.MarkPublished(context.Background(), id, current[0].ClaimToken, later); err != nil {
+		t.Fatalf("MarkPublished with the current claim: %v", err)
+	}
+	// The outcome is only recorded once
+	if err := repo.MarkFailed(context.Background(), id, current[0].ClaimToken, later, "again"); !errors.Is(err, repository.ErrOutboxLeaseLost) {
+		t.Errorf("MarkFailed after publishing: err = %v, want %v", err, repository.ErrOutboxLeaseLost)
+	}
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"gorm.io/gorm"
+)
+
+// Repositories are the stores the API runs on. Their Transactor makes writes
+// to several of them atomic, so they must all come from the same backend.
+type Repositories struct {
+	Users      UserRepository
+	Activities ActivityRepository
+	Audit      AuditRepository
+	Outbox     OutboxRepository
+	Transactor Transactor
+}
+
+// NewRepositories returns the Postgres repositories sharing db, each query
+// bounded by queryTimeout
+func NewRepositories(db *gorm.DB, queryTimeout time.Duration) Repositories {
+	return Repositories{
+		Users:      NewUserRepository(db, queryTimeout),
+		Activities: NewActivityRepository(db, queryTimeout),
+		Audit:      NewAuditRepository(db, queryTimeout),
+		Outbox:     NewOutboxRepository(db, queryTimeout),
+		Transactor: NewTransactor(db),
+	}
+}
+
+// NewMemoryRepositories returns empty in-memory repositories running on clk
+func NewMemoryRepositories(clk clock.Clock) Repositories {
+	return Repositories{
+		Users:      NewMemoryUserRepository(clk),
+		Activities: NewMemoryActivityRepository(clk),
+		Audit:      NewMemoryAuditRepository(clk),
+		Outbox:     NewMemoryOutboxRepository(clk),
+		Transactor: NewMemoryTransactor(),
+	}
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+type memoryTxKey struct{}
+
+// memoryTx collects how to undo the writes made in a transaction
+type memoryTx struct {
+	mu   sync.Mutex
+	undo []func()
+}
+
+type memoryTransactor struct{}
+
+// NewMemoryTransactor returns a Transactor for the in-memory repositories.
+// Writes are undone on rollback, but unlike Postgres they are visible to
+// other callers before the commit.
+func NewMemoryTransactor() Transactor {
+	return memoryTransactor{}
+}
+
+func (memoryTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok {
+		return fn(ctx)
+	}
+
+	tx := &memoryTx{}
+	err := fn(context.WithValue(ctx, memoryTxKey{}, tx))
+	if err != nil {
+		tx.mu.Lock()
+		defer tx.mu.Unlock()
+		for _, undo := range slices.Backward(tx.undo) {
+			undo()
+		}
+	}
+	return err
+}
+
+// onRollback registers undo to run if the transaction ctx runs in is rolled
+// back. Outside of a transaction writes are final and it does nothing. undo
+// runs after the transaction's function returned, so it may take locks.
+func onRollback(ctx context.Context, undo func()) {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.undo = append(tx.undo, undo)
+}
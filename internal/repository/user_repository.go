@@ -1,29 +1,55 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/fikrialwan/FitByte/internal/dto"
 	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-type (
-	UserRepository struct {
-		db *gorm.DB
-	}
-)
+// UserRepository stores user accounts. Lookups of unknown users return
+// dto.ErrUserNotFound.
+type UserRepository interface {
+	GetByEmail(ctx context.Context, email string) (entity.User, error)
+	// CreateUser inserts the user, whose password must already be hashed,
+	// assigning an ID when missing. It fails with dto.ErrUserEmailExist when the email is
+	// already registered.
+	CreateUser(ctx context.Context, user *entity.User) error
+	GetById(ctx context.Context, userId string) (entity.User, error)
+	// Update saves the user's non-zero fields if the user is still at
+	// user.Version, and reloads the stored row with the next version into it.
+	// It fails with dto.ErrVersionConflict when the user was updated in the
+	// meantime.
+	Update(ctx context.Context, user *entity.User) error
+	// UpdatePassword stores an already hashed password
+	UpdatePassword(ctx context.Context, userId uuid.UUID, passwordHash string) error
+}
+
+type userRepository struct {
+	db           *gorm.DB
+	queryTimeout time.Duration
+}
 
-func NewUserRepository(db *gorm.DB) UserRepository {
-	return UserRepository{
-		db: db,
+// NewUserRepository returns a Postgres repository bounding each query by
+// queryTimeout, on top of any deadline of the caller's context
+func NewUserRepository(db *gorm.DB, queryTimeout time.Duration) UserRepository {
+	return userRepository{
+		db:           db,
+		queryTimeout: queryTimeout,
 	}
 }
 
-func (r UserRepository) GetByEmail(email string) (entity.User, error) {
+func (r userRepository) GetByEmail(ctx context.Context, email string) (entity.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var user entity.User
-	result := r.db.Where("email=?", email).First(&user)
+	result := conn(ctx, r.db).WithContext(ctx).Where("email=?", email).First(&user)
 
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return user, dto.ErrUserNotFound
@@ -34,19 +60,27 @@ func (r UserRepository) GetByEmail(email string) (entity.User, error) {
 	return user, nil
 }
 
-func (r UserRepository) CreateUser(user *entity.User) error {
-	result := r.db.Create(user)
+func (r userRepository) CreateUser(ctx context.Context, user *entity.User) error {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
 
-	if result.Error != nil {
+	result := conn(ctx, r.db).WithContext(ctx).Create(user)
+
+	if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+		return dto.ErrUserEmailExist
+	} else if result.Error != nil {
 		return result.Error
 	}
 
 	return nil
 }
 
-func (r UserRepository) GetById(userId string) (entity.User, error) {
+func (r userRepository) GetById(ctx context.Context, userId string) (entity.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var user entity.User
-	result := r.db.Where("id=?", userId).First(&user)
+	result := conn(ctx, r.db).WithContext(ctx).Where("id=?", userId).First(&user)
 
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return user, dto.ErrUserNotFound
@@ -57,12 +91,32 @@ func (r UserRepository) GetById(userId string) (entity.User, error) {
 	return user, nil
 }
 
-func (r UserRepository) Update(user *entity.User) error {
-	if err := r.db.Model(user).
-		Where("id = ?", user.ID).
+func (r userRepository) Update(ctx context.Context, user *entity.User) error {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	readVersion := user.Version
+	user.Version++
+	result := conn(ctx, r.db).WithContext(ctx).Model(user).
+		Where("id = ? AND version = ?", user.ID, readVersion).
 		Clauses(clause.Returning{}).
-		Updates(user).Error; err != nil {
-		return err
+		Updates(user)
+	if result.Error != nil {
+		user.Version = readVersion
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		user.Version = readVersion
+		return dto.ErrVersionConflict
 	}
 	return nil
 }
+
+func (r userRepository) UpdatePassword(ctx context.Context, userId uuid.UUID, passwordHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return conn(ctx, r.db).WithContext(ctx).Model(&entity.User{}).
+		Where("id = ?", userId).
+		UpdateColumn("password", passwordHash).Error
+}
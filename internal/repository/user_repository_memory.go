@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/google/uuid"
+)
+
+// memoryUserRepository runs the entity hooks GORM would, so passwords are
+// hashed on create exactly as in Postgres
+type memoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]entity.User
+	clock clock.Clock
+}
+
+// NewMemoryUserRepository returns a UserRepository held in memory, for tests
+// and local runs without Postgres
+func NewMemoryUserRepository(clk clock.Clock) UserRepository {
+	return &memoryUserRepository{users: make(map[uuid.UUID]entity.User), clock: clk}
+}
+
+func (r *memoryUserRepository) GetByEmail(ctx context.Context, email string) (entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.User{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return entity.User{}, dto.ErrUserNotFound
+}
+
+func (r *memoryUserRepository) CreateUser(ctx context.Context, user *entity.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.users {
+		if existing.Email == user.Email {
+			return dto.ErrUserEmailExist
+		}
+	}
+
+	created := *user
+	if err := created.BeforeCreate(nil); err != nil {
+		return err
+	}
+	now := r.clock.Now()
+	if created.CreatedAt.IsZero() {
+		created.CreatedAt = now
+	}
+	if created.UpdatedAt.IsZero() {
+		created.UpdatedAt = now
+	}
+	if created.Version == 0 {
+		created.Version = 1
+	}
+
+	r.users[created.ID] = created
+	*user = created
+	return nil
+}
+
+func (r *memoryUserRepository) GetById(ctx context.Context, userId string) (entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.User{}, err
+	}
+
+	id, err := uuid.Parse(userId)
+	if err != nil {
+		return entity.User{}, dto.ErrUserNotFound
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return entity.User{}, dto.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// Update mirrors GORM's Updates with a struct: zero fields are left as stored.
+// Unknown users and stale versions fail with dto.ErrVersionConflict.
+func (r *memoryUserRepository) Update(ctx context.Context, user *entity.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[user.ID]
+	if !ok || stored.Version != user.Version {
+		return dto.ErrVersionConflict
+	}
+
+	previous := stored
+	changes := *user
+	setIfNotZero(&stored.Name, changes.Name)
+	setIfNotZero(&stored.Email, changes.Email)
+	setIfNotZero(&stored.Password, changes.Password)
+	setIfNotZero(&stored.Preference, changes.Preference)
+	setIfNotZero(&stored.WeightUnit, changes.WeightUnit)
+	setIfNotZero(&stored.HeightUnit, changes.HeightUnit)
+	setIfNotZero(&stored.Weight, changes.Weight)
+	setIfNotZero(&stored.Height, changes.Height)
+	setIfNotZero(&stored.ImageKey, changes.ImageKey)
+	stored.UpdatedAt = r.clock.Now()
+	stored.Version++
+
+	r.users[stored.ID] = stored
+	onRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.users[previous.ID] = previous
+	})
+	*user = stored
+	return nil
+}
+
+func (r *memoryUserRepository) UpdatePassword(ctx context.Context, userId uuid.UUID, passwordHash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[userId]; ok {
+		user.Password = passwordHash
+		r.users[userId] = user
+	}
+	return nil
+}
+
+func setIfNotZero[T comparable](dst *T, value T) {
+	var zero T
+	if value != zero {
+		*dst = value
+	}
+}
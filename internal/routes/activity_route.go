@@ -7,10 +7,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterActivityRoutes(router gin.IRouter, activityController controller.ActivityController, jwtService service.JwtService) {
-	router.Use(middlewares.Authenticate(jwtService))
-	router.GET("/activity", activityController.GetActivity)
-	router.POST("/activity", activityController.CreateActivity)
-	router.PATCH("/activity/:activityId", activityController.UpdateActivity)
-	router.DELETE("/activity/:activityId", activityController.DeleteActivity)
+func RegisterActivityRoutes(router gin.IRouter, activityController controller.ActivityController, jwtService service.JwtService, rateLimits middlewares.RateLimits, idempotency gin.HandlerFunc) {
+	activityRoutes := router.Group("/activity")
+	activityRoutes.Use(middlewares.Authenticate(jwtService), rateLimits.User)
+	activityRoutes.GET("", activityController.GetActivity)
+	activityRoutes.GET("/summary", activityController.GetActivitySummary)
+	activityRoutes.GET("/trash", activityController.GetTrash)
+	activityRoutes.POST("", idempotency, activityController.CreateActivity)
+	activityRoutes.GET("/:activityId", activityController.GetActivityByID)
+	activityRoutes.PATCH("/:activityId", activityController.UpdateActivity)
+	activityRoutes.DELETE("/:activityId", activityController.DeleteActivity)
+	activityRoutes.POST("/:activityId/restore", activityController.RestoreActivity)
+	activityRoutes.GET("/:activityId/history", activityController.GetActivityHistory)
 }
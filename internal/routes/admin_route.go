@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/fikrialwan/FitByte/internal/controller"
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/fikrialwan/FitByte/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterAdminRoutes(router gin.IRouter, auditController controller.AuditController, jwtService service.JwtService, rateLimits middlewares.RateLimits, requireAdmin gin.HandlerFunc) {
+	adminRoutes := router.Group("/admin")
+	adminRoutes.Use(middlewares.Authenticate(jwtService), rateLimits.User, requireAdmin)
+	adminRoutes.GET("/audit", auditController.SearchAudit)
+}
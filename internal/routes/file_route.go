@@ -7,7 +7,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterFileRoutes(router gin.IRouter, fileController controller.FileController, jwtService service.JwtService) {
-	router.Use(middlewares.Authenticate(jwtService))
-	router.POST("/file", fileController.UploadFile)
+func RegisterFileRoutes(router gin.IRouter, fileController controller.FileController, jwtService service.JwtService, rateLimits middlewares.RateLimits, idempotency gin.HandlerFunc) {
+	fileRoutes := router.Group("/file")
+	fileRoutes.Use(middlewares.Authenticate(jwtService), rateLimits.User)
+	fileRoutes.POST("", idempotency, fileController.UploadFile)
 }
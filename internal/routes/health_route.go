@@ -8,4 +8,5 @@ import (
 func RegisterHealthRoutes(router gin.IRouter, healthController controller.HealthController) {
 	router.GET("/health", healthController.HealthCheck)
 	router.GET("/ready", healthController.ReadinessCheck)
+	router.GET("/ready/:component", healthController.ComponentReadinessCheck)
 }
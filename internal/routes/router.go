@@ -0,0 +1,109 @@
+package routes
+
+import (
+	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/controller"
+	"github.com/fikrialwan/FitByte/internal/health"
+	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/fikrialwan/FitByte/middlewares"
+	"github.com/fikrialwan/FitByte/pkg/helpers"
+	"github.com/fikrialwan/FitByte/pkg/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// Dependencies are the backends the API runs on. The server passes Postgres,
+// Redis and MinIO, tests pass the in-memory implementations.
+type Dependencies struct {
+	Repositories repository.Repositories
+	CacheService service.CacheService
+	FileService  service.FileService
+	RateLimiter  middlewares.RateLimiter
+	// Clock issues and expires tokens
+	Clock clock.Clock
+	// PasswordHasher hashes new passwords, tests pass a cheap one
+	PasswordHasher helpers.PasswordHasher
+	// HealthChecks are run by /v1/ready, in this order
+	HealthChecks []health.Check
+}
+
+// Register builds the services and controllers on top of deps, then installs
+// the middlewares and every route on server
+func Register(server *gin.Engine, cfg *config.Config, reloader *config.Reloader, deps Dependencies) {
+	jwtService := service.NewJwtService(cfg, deps.Clock)
+	loginAttemptService := service.NewLoginAttemptService(cfg, deps.CacheService)
+	repos := deps.Repositories
+	auditService := service.NewAuditService(repos.Audit)
+	outboxService := service.NewOutboxService(repos.Outbox)
+	userService := service.NewUserService(repos.Users, jwtService, deps.CacheService, deps.FileService, loginAttemptService, repos.Transactor, auditService, outboxService, deps.PasswordHasher)
+	activityService := service.NewActivityService(repos.Activities, deps.CacheService, repos.Transactor, auditService, outboxService)
+
+	// imageUri must point at our own storage, see IMAGE_URI_ALLOWED_HOSTS
+	imageURIRule := validator.URLRule(cfg.GetImageURIAllowedSchemes(), cfg.GetImageURIAllowedHosts())
+	userController := controller.NewUserController(userService, imageURIRule)
+	fileController := controller.NewFileController(deps.FileService)
+	activityController := controller.NewActivityController(activityService)
+	auditController := controller.NewAuditController(auditService)
+	checker := health.NewChecker(cfg.GetHealthCheckTimeout(), cfg.GetHealthCheckCacheTTL(), deps.HealthChecks...)
+	healthController := controller.NewHealthController(checker, deps.CacheService)
+
+	// Request IDs, tracing, logging and metrics come first so they see every
+	// request, including the ones rejected by CORS or rate limits. Recovery
+	// sits below the logger so panics are logged as 500s.
+	server.Use(middlewares.RequestID())
+	server.Use(middlewares.Tracing(cfg.GetTracingServiceName()))
+	server.Use(middlewares.Logger())
+	server.Use(middlewares.Recovery())
+	server.Use(middlewares.Metrics())
+
+	// Everything below runs against a deadline, see REQUEST_TIMEOUT
+	server.Use(middlewares.Timeout(cfg.GetRequestTimeout()))
+
+	// Add CORS middleware
+	server.Use(middlewares.CORS(reloader))
+
+	// Add rate limiting middleware, the limits are no-ops unless enabled
+	rateLimits := middlewares.NewRateLimits(reloader, deps.RateLimiter)
+	server.Use(rateLimits.Global)
+
+	// Swagger endpoints with custom configuration
+	server.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.PersistAuthorization(true)))
+
+	// Create v1 route group
+	v1 := server.Group("/v1")
+
+	// Health check endpoints under v1
+	RegisterHealthRoutes(v1, healthController)
+
+	// User routes under v1
+	RegisterUserRoutes(v1, userController, jwtService, rateLimits)
+
+	// Retried uploads and activity creations replay their first response
+	idempotency := middlewares.Idempotency(deps.CacheService, cfg.GetIdempotencyKeyTTL())
+
+	// File routes under v1
+	RegisterFileRoutes(v1, fileController, jwtService, rateLimits, idempotency)
+
+	// Activity routes under v1
+	RegisterActivityRoutes(v1, activityController, jwtService, rateLimits, idempotency)
+
+	// Admin routes under v1, for the users in ADMIN_USER_IDS
+	RegisterAdminRoutes(v1, auditController, jwtService, rateLimits, middlewares.RequireAdmin(reloader))
+}
+
+// RegisterInternal installs the routes for operators on server, which
+// listens on METRICS_PORT and is never exposed publicly
+func RegisterInternal(server *gin.Engine, deps Dependencies) {
+	server.Use(middlewares.Recovery())
+
+	// Prometheus metrics, scraped from inside the cluster
+	server.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// Only the cache stats are served here, they need no health checker
+	healthController := controller.NewHealthController(nil, deps.CacheService)
+	server.GET("/v1/cache/stats", healthController.CacheStats)
+}
@@ -0,0 +1,611 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/apitest"
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/fikrialwan/FitByte/internal/health"
+	"github.com/fikrialwan/FitByte/internal/routes"
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/fikrialwan/FitByte/middlewares"
+	"github.com/fikrialwan/FitByte/pkg/problem"
+	"github.com/google/uuid"
+)
+
+func TestAuthentication(t *testing.T) {
+	api := apitest.New(t)
+	api.Register("john@example.com", "password123")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "login", method: http.MethodPost, path: "/v1/login", body: map[string]string{"email": "john@example.com", "password": "password123"}, wantStatus: http.StatusOK},
+		{name: "wrong password", method: http.MethodPost, path: "/v1/login", body: map[string]string{"email": "john@example.com", "password": "password124"}, wantStatus: http.StatusNotFound, wantCode: "invalid_credentials"},
+		{name: "taken email", method: http.MethodPost, path: "/v1/register", body: map[string]string{"email": "john@example.com", "password": "password123"}, wantStatus: http.StatusConflict, wantCode: "email_exists"},
+		{name: "short password", method: http.MethodPost, path: "/v1/register", body: map[string]string{"email": "jane@example.com", "password": "short"}, wantStatus: http.StatusBadRequest, wantCode: problem.CodeValidationFailed},
+		{name: "missing token", method: http.MethodGet, path: "/v1/user", wantStatus: http.StatusUnauthorized, wantCode: problem.CodeUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := api.Do(tt.method, tt.path, "", tt.body)
+
+			if res.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", res.Code, tt.wantStatus, res.Body)
+			}
+			if tt.wantCode != "" {
+				if p := apitest.Decode[problem.Problem](t, res); p.Code != tt.wantCode {
+					t.Errorf("code = %q, want %q", p.Code, tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	api := apitest.New(t)
+	token := api.Register("john@example.com", "password123")
+
+	if res := api.Do(http.MethodGet, "/v1/user", token, nil); res.Code != http.StatusOK {
+		t.Fatalf("fresh token: status = %d: %s", res.Code, res.Body)
+	}
+
+	api.Clock.Advance(24*time.Hour + time.Second)
+	if res := api.Do(http.MethodGet, "/v1/user", token, nil); res.Code != http.StatusUnauthorized {
+		t.Fatalf("expired token: status = %d, want %d", res.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestActivityLifecycle(t *testing.T) {
+	api := apitest.New(t)
+	token := api.Register("john@example.com", "password123")
+	other := api.Register("jane@example.com", "password123")
+
+	res := api.Do(http.MethodPost, "/v1/activity", token, map[string]any{
+		"activityType":      "Running",
+		"doneAt":            "2025-01-15T07:30:00Z",
+		"durationInMinutes": 30,
+	})
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", res.Code, res.Body)
+	}
+	created := apitest.Decode[dto.CreateActivityResponse](t, res)
+	path := "/v1/activity/" + created.ID.String()
+
+	if res := api.Do(http.MethodPatch, path, other, map[string]any{"durationInMinutes": 60}); res.Code != http.StatusNotFound {
+		t.Fatalf("update by another user: status = %d, want %d", res.Code, http.StatusNotFound)
+	}
+
+	res = api.Do(http.MethodPatch, path, token, map[string]any{"durationInMinutes": 60})
+	if res.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", res.Code, res.Body)
+	}
+	if updated := apitest.Decode[dto.ActivityResponse](t, res); updated.CaloriesBurned != 600 {
+		t.Errorf("caloriesBurned = %d, want 600", updated.CaloriesBurned)
+	}
+
+	res = api.Do(http.MethodGet, "/v1/activity?activityType=Running", token, nil)
+	if list := apitest.Decode[[]dto.ActivityResponse](t, res); len(list) != 1 || list[0].DurationInMinutes != 60 {
+		t.Errorf("list = %+v, want the updated activity", list)
+	}
+	res = api.Do(http.MethodGet, "/v1/activity", other, nil)
+	if list := apitest.Decode[[]dto.ActivityResponse](t, res); len(list) != 0 {
+		t.Errorf("another user's list = %+v, want it empty", list)
+	}
+
+	if res := api.Do(http.MethodDelete, path, token, nil); res.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d: %s", res.Code, res.Body)
+	}
+	if res := api.Do(http.MethodDelete, path, token, nil); res.Code != http.StatusNotFound {
+		t.Fatalf("delete twice: status = %d, want %d", res.Code, http.StatusNotFound)
+	}
+}
+
+func TestActivityTrash(t *testing.T) {
+	api := apitest.New(t)
+	token := api.Register("john@example.com", "password123")
+
+	res := api.Do(http.MethodPost, "/v1/activity", token, map[string]any{
+		"activityType":      "Running",
+		"doneAt":            "2025-01-15T07:30:00Z",
+		"durationInMinutes": 30,
+	})
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", res.Code, res.Body)
+	}
+	created := apitest.Decode[dto.CreateActivityResponse](t, res)
+	path := "/v1/activity/" + created.ID.String()
+
+	if res := api.Do(http.MethodDelete, path, token, nil); res.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d: %s", res.Code, res.Body)
+	}
+	if res := api.Do(http.MethodGet, path, token, nil); res.Code != http.StatusNotFound {
+		t.Errorf("get trashed: status = %d, want %d", res.Code, http.StatusNotFound)
+	}
+	if list := apitest.Decode[[]dto.ActivityResponse](t, api.Do(http.MethodGet, "/v1/activity", token, nil)); len(list) != 0 {
+		t.Errorf("list = %+v, want trashed activities left out", list)
+	}
+	if summary := apitest.Decode[dto.ActivitySummaryResponse](t, api.Do(http.MethodGet, "/v1/activity/summary", token, nil)); summary.TotalActivities != 0 {
+		t.Errorf("summary = %+v, want trashed activities left out", summary)
+	}
+
+	res = api.Do(http.MethodGet, "/v1/activity/trash", token, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("trash: status = %d: %s", res.Code, res.Body)
+	}
+	if trash := apitest.Decode[[]dto.TrashedActivityResponse](t, res); len(trash) != 1 || trash[0].ID != created.ID {
+		t.Fatalf("trash = %+v, want the deleted activity", trash)
+	}
+	if res := api.Do(http.MethodGet, "/v1/activity/trash?limit=101", token, nil); res.Code != http.StatusBadRequest {
+		t.Errorf("limit 101: status = %d, want %d", res.Code, http.StatusBadRequest)
+	}
+
+	res = api.Do(http.MethodPost, path+"/restore", token, nil)
+	if res.Code != http.StatusOK || res.Header().Get("ETag") != `"2"` {
+		t.Fatalf("restore: status = %d, ETag = %q: %s", res.Code, res.Header().Get("ETag"), res.Body)
+	}
+	if res := api.Do(http.MethodPost, path+"/restore", token, nil); res.Code != http.StatusNotFound {
+		t.Errorf("restore twice: status = %d, want %d", res.Code, http.StatusNotFound)
+	}
+	if list := apitest.Decode[[]dto.ActivityResponse](t, api.Do(http.MethodGet, "/v1/activity", token, nil)); len(list) != 1 {
+		t.Errorf("got %d activities after restoring, want 1", len(list))
+	}
+}
+
+func TestAuditTrail(t *testing.T) {
+	adminID := uuid.NewString()
+	api := apitest.New(t, apitest.WithConfig(func(cfg *config.Config) {
+		cfg.AdminUserIDs = adminID
+	}))
+	token := api.Register("john@example.com", "password123")
+	otherToken := api.Register("jane@example.com", "password123")
+	adminToken := service.NewJwtService(api.Config, api.Clock).GenerateAccessToken(adminID)
+
+	res := api.Do(http.MethodPost, "/v1/activity", token, map[string]any{
+		"activityType":      "Running",
+		"doneAt":            "2025-01-15T07:30:00Z",
+		"durationInMinutes": 30,
+	})
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", res.Code, res.Body)
+	}
+	created := apitest.Decode[dto.CreateActivityResponse](t, res)
+	path := "/v1/activity/" + created.ID.String()
+	if res := api.Do(http.MethodPatch, path, token, map[string]any{"durationInMinutes": 45}); res.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", res.Code, res.Body)
+	}
+
+	res = api.Do(http.MethodGet, path+"/history", token, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("history: status = %d: %s", res.Code, res.Body)
+	}
+	history := apitest.Decode[[]dto.AuditEntryResponse](t, res)
+	if len(history) != 2 || history[0].Action != entity.AuditUpdate || history[1].Action != entity.AuditCreate {
+		t.Fatalf("history = %+v, want the update then the creation", history)
+	}
+	if change := history[0].Changes["durationInMinutes"]; change.Before != float64(30) || change.After != float64(45) {
+		t.Errorf("durationInMinutes change = %+v, want 30 to 45", change)
+	}
+	if res := api.Do(http.MethodGet, path+"/history", otherToken, nil); res.Code != http.StatusNotFound {
+		t.Errorf("other user's history: status = %d, want %d", res.Code, http.StatusNotFound)
+	}
+
+	// Only administrators search the whole log
+	if res := api.Do(http.MethodGet, "/v1/admin/audit", token, nil); res.Code != http.StatusForbidden {
+		t.Errorf("search as user: status = %d, want %d", res.Code, http.StatusForbidden)
+	}
+	res = api.Do(http.MethodGet, "/v1/admin/audit?entityId="+created.ID.String()+"&action=create", adminToken, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("search: status = %d: %s", res.Code, res.Body)
+	}
+	if entries := apitest.Decode[[]dto.AuditEntryResponse](t, res); len(entries) != 1 || entries[0].ID != history[1].ID {
+		t.Errorf("entries = %+v, want the creation", entries)
+	}
+	for _, query := range []string{"actorId=42", "action=rename", "entityType=file", "limit=101"} {
+		if res := api.Do(http.MethodGet, "/v1/admin/audit?"+query, adminToken, nil); res.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, res.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	api := apitest.New(t)
+	token := api.Register("john@example.com", "password123")
+
+	// do sends a request with one conditional header
+	do := func(method, path, header, etag string, body any) *httptest.ResponseRecorder {
+		var data []byte
+		if body != nil {
+			data, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(header, etag)
+		return api.Serve(req)
+	}
+
+	res := api.Do(http.MethodPost, "/v1/activity", token, map[string]any{
+		"activityType":      "Running",
+		"doneAt":            "2025-01-15T07:30:00Z",
+		"durationInMinutes": 30,
+	})
+	if res.Code != http.StatusCreated || res.Header().Get("ETag") != `"1"` {
+		t.Fatalf("create: status = %d, ETag = %q: %s", res.Code, res.Header().Get("ETag"), res.Body)
+	}
+	path := "/v1/activity/" + apitest.Decode[dto.CreateActivityResponse](t, res).ID.String()
+
+	res = api.Do(http.MethodGet, path, token, nil)
+	etag := res.Header().Get("ETag")
+	if res.Code != http.StatusOK || etag != `"1"` {
+		t.Fatalf("get: status = %d, ETag = %q", res.Code, etag)
+	}
+	if res := do(http.MethodGet, path, "If-None-Match", etag, nil); res.Code != http.StatusNotModified || res.Body.Len() != 0 {
+		t.Errorf("unchanged: status = %d, body = %q, want an empty %d", res.Code, res.Body, http.StatusNotModified)
+	}
+
+	res = do(http.MethodPatch, path, "If-Match", etag, map[string]any{"durationInMinutes": 60})
+	if res.Code != http.StatusOK || res.Header().Get("ETag") != `"2"` {
+		t.Fatalf("update: status = %d, ETag = %q: %s", res.Code, res.Header().Get("ETag"), res.Body)
+	}
+
+	// Both the stale tag and anything that isn't a tag of ours fail
+	for _, stale := range []string{etag, "W/" + etag, `"abc"`} {
+		res := do(http.MethodPatch, path, "If-Match", stale, map[string]any{"durationInMinutes": 90})
+		if res.Code != http.StatusPreconditionFailed {
+			t.Fatalf("If-Match %s: status = %d, want %d", stale, res.Code, http.StatusPreconditionFailed)
+		}
+		if p := apitest.Decode[problem.Problem](t, res); p.Code != service.ErrVersionMismatch.Code {
+			t.Errorf("If-Match %s: code = %q, want %q", stale, p.Code, service.ErrVersionMismatch.Code)
+		}
+	}
+	if res := do(http.MethodGet, path, "If-None-Match", etag, nil); res.Code != http.StatusOK {
+		t.Errorf("changed: status = %d, want %d", res.Code, http.StatusOK)
+	}
+
+	res = api.Do(http.MethodGet, "/v1/user", token, nil)
+	if res.Code != http.StatusOK || res.Header().Get("ETag") == "" {
+		t.Fatalf("profile: status = %d, ETag = %q", res.Code, res.Header().Get("ETag"))
+	}
+	profile := map[string]any{"preference": "CARDIO", "weightUnit": "KG", "heightUnit": "CM", "weight": 70, "height": 175}
+	if res := do(http.MethodPatch, "/v1/user", "If-Match", `"7"`, profile); res.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale profile update: status = %d, want %d", res.Code, http.StatusPreconditionFailed)
+	}
+	if res := do(http.MethodPatch, "/v1/user", "If-Match", res.Header().Get("ETag"), profile); res.Code != http.StatusOK {
+		t.Errorf("profile update: status = %d: %s", res.Code, res.Body)
+	}
+}
+
+func TestProfileImageUpload(t *testing.T) {
+	api := apitest.New(t)
+	token := api.Register("john@example.com", "password123")
+
+	res := api.Serve(uploadRequest(token, ""))
+	if res.Code != http.StatusOK {
+		t.Fatalf("upload: status = %d: %s", res.Code, res.Body)
+	}
+	uri := apitest.Decode[struct {
+		URI string `json:"uri"`
+	}](t, res).URI
+
+	res = api.Do(http.MethodPatch, "/v1/user", token, map[string]any{
+		"preference": "CARDIO",
+		"weightUnit": "KG",
+		"heightUnit": "CM",
+		"weight":     70,
+		"height":     175,
+		"imageUri":   uri,
+	})
+	if res.Code != http.StatusOK {
+		t.Fatalf("update profile: status = %d: %s", res.Code, res.Body)
+	}
+
+	res = api.Do(http.MethodGet, "/v1/user", token, nil)
+	profile := apitest.Decode[dto.UserResponse](t, res)
+	if !strings.HasPrefix(profile.ImageUri, apitest.StorageEndpoint+"/") || !strings.Contains(profile.ImageUri, ".png?") {
+		t.Errorf("imageUri = %q, want a signed URL of the upload", profile.ImageUri)
+	}
+
+	// The signed URL expires, a cached copy of the profile is never reused
+	req := httptest.NewRequest(http.MethodGet, "/v1/user", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-None-Match", res.Header().Get("ETag"))
+	if res := api.Serve(req); res.Code != http.StatusOK || res.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("If-None-Match with an image: status = %d, Cache-Control = %q, want %d and no-store", res.Code, res.Header().Get("Cache-Control"), http.StatusOK)
+	}
+
+	// Each server checks the hosts of its own config
+	other := apitest.New(t, apitest.WithConfig(func(cfg *config.Config) {
+		cfg.ImageURIAllowedHosts = "images.example.com"
+	}))
+	res = other.Do(http.MethodPatch, "/v1/user", other.Register("john@example.com", "password123"), map[string]any{
+		"preference": "CARDIO",
+		"weightUnit": "KG",
+		"heightUnit": "CM",
+		"weight":     70,
+		"height":     175,
+		"imageUri":   uri,
+	})
+	if res.Code != http.StatusBadRequest || !strings.Contains(res.Body.String(), `"field":"imageUri"`) {
+		t.Errorf("host not allowed: status = %d: %s, want 400 about imageUri", res.Code, res.Body)
+	}
+}
+
+// uploadRequest builds a PNG upload, each call with a new multipart boundary
+// like a client retrying would
+func uploadRequest(token, idempotencyKey string) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "avatar.png")
+	part.Write([]byte("\x89PNG\r\n\x1a\n"))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/file", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	return req
+}
+
+func TestIdempotentActivityCreation(t *testing.T) {
+	api := apitest.New(t)
+	token := api.Register("john@example.com", "password123")
+	other := api.Register("jane@example.com", "password123")
+	running := map[string]any{"activityType": "Running", "doneAt": "2025-01-15T07:30:00Z", "durationInMinutes": 30}
+
+	create := func(token, key string, body any) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/v1/activity", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		return api.Serve(req)
+	}
+
+	first := create(token, "key-1", running)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first: status = %d: %s", first.Code, first.Body)
+	}
+
+	retry := create(token, "key-1", running)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %s, want the first response %s", retry.Code, retry.Body, first.Body)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("retry isn't marked as replayed")
+	}
+	if etag := retry.Header().Get("ETag"); etag == "" || etag != first.Header().Get("ETag") {
+		t.Errorf("retry ETag = %q, want the first one %q", etag, first.Header().Get("ETag"))
+	}
+
+	walking := map[string]any{"activityType": "Walking", "doneAt": "2025-01-15T07:30:00Z", "durationInMinutes": 30}
+	if res := create(token, "key-1", walking); res.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused for another payload: status = %d, want %d", res.Code, http.StatusUnprocessableEntity)
+	} else if p := apitest.Decode[problem.Problem](t, res); p.Code != middlewares.CodeIdempotencyKeyReused {
+		t.Errorf("code = %q, want %q", p.Code, middlewares.CodeIdempotencyKeyReused)
+	}
+
+	// Keys are per user
+	if res := create(other, "key-1", running); res.Code != http.StatusCreated || res.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("another user's key: status = %d, replayed = %q", res.Code, res.Header().Get("Idempotent-Replayed"))
+	}
+
+	// Without a key every request creates an activity
+	create(token, "", running)
+	res := api.Do(http.MethodGet, "/v1/activity", token, nil)
+	if list := apitest.Decode[[]dto.ActivityResponse](t, res); len(list) != 2 {
+		t.Errorf("got %d activities, want 2", len(list))
+	}
+}
+
+// blockingFileService holds uploads until release is closed
+type blockingFileService struct {
+	service.FileService
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s blockingFileService) UploadToS3(ctx context.Context, userID string, file io.Reader, filename, contentType string) (string, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return s.FileService.UploadToS3(ctx, userID, file, filename, contentType)
+}
+
+func TestIdempotentUploadInProgress(t *testing.T) {
+	files := blockingFileService{
+		FileService: service.NewMemoryFileService(apitest.StorageEndpoint),
+		started:     make(chan struct{}, 1),
+		release:     make(chan struct{}),
+	}
+	api := apitest.New(t, apitest.WithDependencies(func(deps *routes.Dependencies) {
+		deps.FileService = files
+	}))
+	token := api.Register("john@example.com", "password123")
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- api.Serve(uploadRequest(token, "upload-1")) }()
+	<-files.started
+
+	if res := api.Serve(uploadRequest(token, "upload-1")); res.Code != http.StatusConflict {
+		t.Errorf("concurrent duplicate: status = %d, want %d", res.Code, http.StatusConflict)
+	}
+
+	close(files.release)
+	first := <-done
+	if first.Code != http.StatusOK {
+		t.Fatalf("first: status = %d: %s", first.Code, first.Body)
+	}
+
+	// The retry carries another multipart boundary, it is still the same upload
+	if res := api.Serve(uploadRequest(token, "upload-1")); res.Code != http.StatusOK || res.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %s, want the first response %s", res.Code, res.Body, first.Body)
+	}
+}
+
+// failingFileService fails uploads until fail is cleared
+type failingFileService struct {
+	service.FileService
+	fail *bool
+}
+
+func (s failingFileService) UploadToS3(ctx context.Context, userID string, file io.Reader, filename, contentType string) (string, error) {
+	if *s.fail {
+		return "", errors.New("storage unavailable")
+	}
+	return s.FileService.UploadToS3(ctx, userID, file, filename, contentType)
+}
+
+func TestIdempotentUploadRetriedAfterFailure(t *testing.T) {
+	fail := true
+	api := apitest.New(t, apitest.WithDependencies(func(deps *routes.Dependencies) {
+		deps.FileService = failingFileService{FileService: deps.FileService, fail: &fail}
+	}))
+	token := api.Register("john@example.com", "password123")
+
+	if res := api.Serve(uploadRequest(token, "upload-1")); res.Code != http.StatusInternalServerError {
+		t.Fatalf("failing upload: status = %d, want %d", res.Code, http.StatusInternalServerError)
+	}
+
+	// Server errors aren't stored, the retry runs again
+	fail = false
+	res := api.Serve(uploadRequest(token, "upload-1"))
+	if res.Code != http.StatusOK || res.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("retry: status = %d, replayed = %q: %s", res.Code, res.Header().Get("Idempotent-Replayed"), res.Body)
+	}
+}
+
+// churningCacheService never finds the record after failing to reserve a key,
+// as when other requests keep taking and releasing it
+type churningCacheService struct {
+	service.CacheService
+}
+
+func (churningCacheService) ReserveIdempotencyKey(ctx context.Context, userID, key string, record dto.IdempotencyRecord, ttl time.Duration) (dto.IdempotencyRecord, bool, error) {
+	return dto.IdempotencyRecord{}, false, service.ErrCacheMiss
+}
+
+func TestIdempotentUploadKeyChurning(t *testing.T) {
+	fail := true
+	api := apitest.New(t, apitest.WithDependencies(func(deps *routes.Dependencies) {
+		deps.CacheService = churningCacheService{deps.CacheService}
+		deps.FileService = failingFileService{FileService: deps.FileService, fail: &fail}
+	}))
+	token := api.Register("john@example.com", "password123")
+
+	// Rejected rather than run without the key
+	if res := api.Serve(uploadRequest(token, "upload-1")); res.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", res.Code, http.StatusConflict)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	failing := health.Check{Name: "minio", Run: func(context.Context) error { return context.DeadlineExceeded }}
+
+	tests := []struct {
+		name       string
+		opts       []apitest.Option
+		path       string
+		wantStatus int
+	}{
+		{name: "all healthy", path: "/v1/ready", wantStatus: http.StatusOK},
+		{name: "component", path: "/v1/ready/redis", wantStatus: http.StatusOK},
+		{name: "unknown component", path: "/v1/ready/kafka", wantStatus: http.StatusNotFound},
+		{
+			name: "non-critical failure degrades",
+			opts: []apitest.Option{apitest.WithDependencies(func(deps *routes.Dependencies) {
+				deps.HealthChecks[2] = failing
+			})},
+			path:       "/v1/ready",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "failing component",
+			opts: []apitest.Option{apitest.WithDependencies(func(deps *routes.Dependencies) {
+				deps.HealthChecks[2] = failing
+			})},
+			path:       "/v1/ready/minio",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := apitest.New(t, tt.opts...)
+
+			if res := api.Do(http.MethodGet, tt.path, "", nil); res.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", res.Code, tt.wantStatus, res.Body)
+			}
+		})
+	}
+}
+
+func TestRequestCancellation(t *testing.T) {
+	t.Run("client gone", func(t *testing.T) {
+		api := apitest.New(t)
+		token := api.Register("john@example.com", "password123")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := httptest.NewRequest(http.MethodGet, "/v1/activity", nil).WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		if res := api.Serve(req); res.Code != 499 {
+			t.Fatalf("status = %d, want 499: %s", res.Code, res.Body)
+		}
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		api := apitest.New(t, apitest.WithConfig(func(cfg *config.Config) {
+			cfg.RequestTimeout = time.Nanosecond
+		}))
+		// Registering would run out of time as well, the token comes from a
+		// server sharing the JWT secret
+		token := apitest.Decode[dto.LoginRegisterResponse](t, apitest.New(t).Do(http.MethodPost, "/v1/register", "", map[string]string{
+			"email": "john@example.com", "password": "password123",
+		})).Token
+
+		res := api.Do(http.MethodGet, "/v1/activity", token, nil)
+		if res.Code != http.StatusGatewayTimeout {
+			t.Fatalf("status = %d, want %d: %s", res.Code, http.StatusGatewayTimeout, res.Body)
+		}
+		if p := apitest.Decode[problem.Problem](t, res); p.Code != problem.CodeTimeout {
+			t.Errorf("code = %q, want %q", p.Code, problem.CodeTimeout)
+		}
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	api := apitest.New(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	if res := api.Serve(req); res.Header().Get("X-Request-ID") != "abc-123" {
+		t.Errorf("X-Request-ID = %q, want the caller's", res.Header().Get("X-Request-ID"))
+	}
+
+	if res := api.Do(http.MethodGet, "/v1/health", "", nil); res.Header().Get("X-Request-ID") == "" {
+		t.Error("no X-Request-ID generated")
+	}
+}
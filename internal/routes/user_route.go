@@ -7,13 +7,13 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterUserRoutes(router gin.IRouter, userController controller.UserController, jwtService service.JwtService) {
+func RegisterUserRoutes(router gin.IRouter, userController controller.UserController, jwtService service.JwtService, rateLimits middlewares.RateLimits) {
 
-	router.POST("/login", userController.Login)
-	router.POST("/register", userController.Register)
+	router.POST("/login", rateLimits.Auth, userController.Login)
+	router.POST("/register", rateLimits.Auth, userController.Register)
 
 	userRoutes := router.Group("/user")
-	userRoutes.Use(middlewares.Authenticate(jwtService))
-	userRoutes.GET("/", userController.GetProfile)
+	userRoutes.Use(middlewares.Authenticate(jwtService), rateLimits.User)
+	userRoutes.GET("", userController.GetProfile)
 	userRoutes.PATCH("", userController.UpdateProfile)
 }
@@ -1,54 +1,130 @@
 package service
 
 import (
-	"fmt"
-	"strings"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
 
 	"github.com/fikrialwan/FitByte/internal/dto"
 	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/fikrialwan/FitByte/internal/events"
+	"github.com/fikrialwan/FitByte/internal/metrics"
 	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/fikrialwan/FitByte/internal/tracing"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// activityCacheTTL bounds how long a list page or summary lives in the cache.
+// Freshness comes from the per-user version, not from the TTL.
+const activityCacheTTL = 10 * time.Minute
+
+// ActivityService manages users' activities. Every change is recorded in the
+// audit log and emitted as an event within the transaction making it.
 type ActivityService struct {
 	activityRepository repository.ActivityRepository
+	cacheService       CacheService
+	transactor         repository.Transactor
+	auditService       AuditService
+	outboxService      OutboxService
 }
 
-func NewActivityService(activityRepository repository.ActivityRepository) ActivityService {
-	return ActivityService{activityRepository}
+func NewActivityService(activityRepository repository.ActivityRepository, cacheService CacheService, transactor repository.Transactor, auditService AuditService, outboxService OutboxService) ActivityService {
+	return ActivityService{
+		activityRepository: activityRepository,
+		cacheService:       cacheService,
+		transactor:         transactor,
+		auditService:       auditService,
+		outboxService:      outboxService,
+	}
 }
 
-func (s ActivityService) GetActivity(filter dto.ActivityFilter, userID string) ([]dto.ActivityResponse, error) {
-	activities, err := s.activityRepository.GetActivity(filter, userID)
+func (s ActivityService) GetActivity(ctx context.Context, filter dto.ActivityFilter, userID string) ([]dto.ActivityResponse, error) {
+	ctx, span := tracing.Start(ctx, "ActivityService.GetActivity", attribute.String("user.id", userID))
+	defer span.End()
+
+	// Without a version we can't tell whether a cached page is stale, so the
+	// cache is skipped entirely
+	version, versionErr := s.cacheService.GetActivityVersion(ctx, userID)
+	if versionErr == nil {
+		if cached, err := s.cacheService.GetActivityList(ctx, userID, version, filter.CacheKey()); err == nil {
+			return cached, nil
+		}
+	}
+
+	activities, err := s.activityRepository.GetActivity(ctx, filter, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(activities) == 0 {
-		return []dto.ActivityResponse{}, nil
+	responses := []dto.ActivityResponse{}
+	for _, activity := range activities {
+		responses = append(responses, newActivityResponse(activity))
 	}
 
-	var responses []dto.ActivityResponse
-	for _, activity := range activities {
-		responses = append(responses, dto.ActivityResponse{
-			ID:                activity.ID,
-			ActivityType:      activity.ActivityType,
-			DoneAt:            dto.PreciseTime{Time: activity.DoneAt},
-			DurationInMinutes: activity.DurationInMinutes,
-			CaloriesBurned:    activity.CaloriesBurned,
-			CreatedAt:         activity.CreatedAt,
-			UpdatedAt:         activity.UpdatedAt,
-		})
+	if versionErr == nil {
+		s.cacheService.SetActivityList(ctx, userID, version, filter.CacheKey(), responses, activityCacheTTL)
 	}
 
 	return responses, nil
 }
 
-func (s ActivityService) CreateActivity(activityReq dto.ActivityRequest, userId string) (dto.CreateActivityResponse, error) {
+func (s ActivityService) GetActivitySummary(ctx context.Context, filter dto.ActivitySummaryFilter, userID string) (dto.ActivitySummaryResponse, error) {
+	ctx, span := tracing.Start(ctx, "ActivityService.GetActivitySummary", attribute.String("user.id", userID))
+	defer span.End()
+
+	version, versionErr := s.cacheService.GetActivityVersion(ctx, userID)
+	if versionErr == nil {
+		if cached, err := s.cacheService.GetActivitySummary(ctx, userID, version, filter.CacheKey()); err == nil {
+			return cached, nil
+		}
+	}
+
+	byType, err := s.activityRepository.GetActivitySummary(ctx, filter, userID)
+	if err != nil {
+		return dto.ActivitySummaryResponse{}, err
+	}
+
+	summary := dto.ActivitySummaryResponse{ByType: []dto.ActivityTypeSummary{}}
+	for _, typeSummary := range byType {
+		summary.TotalActivities += typeSummary.TotalActivities
+		summary.TotalDurationInMinutes += typeSummary.TotalDurationInMinutes
+		summary.TotalCaloriesBurned += typeSummary.TotalCaloriesBurned
+		summary.ByType = append(summary.ByType, typeSummary)
+	}
+
+	if versionErr == nil {
+		s.cacheService.SetActivitySummary(ctx, userID, version, filter.CacheKey(), summary, activityCacheTTL)
+	}
+
+	return summary, nil
+}
+
+// invalidateCache makes every cached page and summary of the user unreachable.
+// The write it follows already happened, so it runs even when the request was
+// cancelled in the meantime. When the version can't be bumped it is deleted
+// instead, otherwise the pages of the current version would be served until
+// they expire.
+func (s ActivityService) invalidateCache(ctx context.Context, userID string) {
+	ctx = context.WithoutCancel(ctx)
+	bumpErr := s.cacheService.BumpActivityVersion(ctx, userID)
+	if bumpErr == nil {
+		return
+	}
+	if err := s.cacheService.DeleteActivityVersion(ctx, userID); err != nil {
+		slog.ErrorContext(ctx, "failed to invalidate activity cache, cached pages may be stale until they expire", "error", errors.Join(bumpErr, err), "ttl", activityCacheTTL)
+		return
+	}
+	slog.WarnContext(ctx, "failed to bump activity cache version, deleted it instead", "error", bumpErr)
+}
+
+func (s ActivityService) CreateActivity(ctx context.Context, activityReq dto.ActivityRequest, userId string) (dto.CreateActivityResponse, error) {
+	ctx, span := tracing.Start(ctx, "ActivityService.CreateActivity", attribute.String("user.id", userId))
+	defer span.End()
+
 	if !activityReq.ActivityType.IsValid() {
-		validTypes := entity.GetValidActivityTypeStrings()
-		return dto.CreateActivityResponse{}, fmt.Errorf("invalid activity type '%s'. valid types: %s",
-			activityReq.ActivityType, strings.Join(validTypes, ", "))
+		return dto.CreateActivityResponse{}, invalidActivityType(activityReq.ActivityType)
 	}
 
 	caloriesBurned := activityReq.ActivityType.CalculateBurnedCalories(activityReq.DurationInMinutes)
@@ -61,10 +137,26 @@ func (s ActivityService) CreateActivity(activityReq dto.ActivityRequest, userId
 		UserID:            uuid.MustParse(userId),
 	}
 
-	createdActivity, err := s.activityRepository.CreateActivity(activity)
+	var createdActivity entity.Activity
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		createdActivity, err = s.activityRepository.CreateActivity(ctx, activity)
+		if err != nil {
+			return err
+		}
+		err = s.auditService.Record(ctx, entity.AuditCreate, entity.AuditEntityActivity, createdActivity.ID, createdActivity.UserID,
+			entity.DiffAuditFields(nil, createdActivity.AuditFields()))
+		if err != nil {
+			return err
+		}
+		return s.outboxService.Emit(ctx, events.ActivityCreated, createdActivity.ID, createdActivity.UserID, events.NewActivity(createdActivity))
+	})
 	if err != nil {
 		return dto.CreateActivityResponse{}, err
 	}
+	metrics.ActivitiesCreated.WithLabelValues(string(createdActivity.ActivityType)).Inc()
+
+	s.invalidateCache(ctx, userId)
 
 	return dto.CreateActivityResponse{
 		ID:                createdActivity.ID,
@@ -72,24 +164,50 @@ func (s ActivityService) CreateActivity(activityReq dto.ActivityRequest, userId
 		DoneAt:            createdActivity.DoneAt,
 		DurationInMinutes: createdActivity.DurationInMinutes,
 		CaloriesBurned:    createdActivity.CaloriesBurned,
+		Version:           createdActivity.Version,
 		CreatedAt:         createdActivity.CreatedAt,
 		UpdatedAt:         createdActivity.UpdatedAt,
 	}, nil
 }
 
-func (s ActivityService) UpdateActivity(activityID, userID string, updateReq dto.ActivityUpdateRequest) (dto.ActivityResponse, error) {
+func (s ActivityService) GetActivityByID(ctx context.Context, activityID, userID string) (dto.ActivityResponse, error) {
+	ctx, span := tracing.Start(ctx, "ActivityService.GetActivityByID", attribute.String("user.id", userID))
+	defer span.End()
+
+	activity, err := s.activityRepository.GetActivityByID(ctx, activityID, userID)
+	if errors.Is(err, dto.ErrActivityNotFound) {
+		return dto.ActivityResponse{}, ErrActivityNotFound
+	} else if err != nil {
+		return dto.ActivityResponse{}, err
+	}
+
+	return newActivityResponse(activity), nil
+}
+
+// UpdateActivity applies the update on top of the stored activity. version is
+// the one the client based its update on, 0 when it didn't name one.
+// Mismatching versions fail with ErrVersionMismatch, and an update racing with
+// another one fails with ErrConcurrentUpdate instead of overwriting it.
+func (s ActivityService) UpdateActivity(ctx context.Context, activityID, userID string, version int, updateReq dto.ActivityUpdateRequest) (dto.ActivityResponse, error) {
+	ctx, span := tracing.Start(ctx, "ActivityService.UpdateActivity", attribute.String("user.id", userID))
+	defer span.End()
+
 	// Get existing activity
-	activity, err := s.activityRepository.GetActivityByID(activityID, userID)
-	if err != nil {
+	activity, err := s.activityRepository.GetActivityByID(ctx, activityID, userID)
+	if errors.Is(err, dto.ErrActivityNotFound) {
+		return dto.ActivityResponse{}, ErrActivityNotFound
+	} else if err != nil {
 		return dto.ActivityResponse{}, err
 	}
+	if version != 0 && activity.Version != version {
+		return dto.ActivityResponse{}, ErrVersionMismatch
+	}
+	before := activity
 
 	// Update fields if provided
 	if updateReq.ActivityType != nil {
 		if !updateReq.ActivityType.IsValid() {
-			validTypes := entity.GetValidActivityTypeStrings()
-			return dto.ActivityResponse{}, fmt.Errorf("invalid activity type '%s'. valid types: %s",
-				*updateReq.ActivityType, strings.Join(validTypes, ", "))
+			return dto.ActivityResponse{}, invalidActivityType(*updateReq.ActivityType)
 		}
 		activity.ActivityType = *updateReq.ActivityType
 	}
@@ -105,12 +223,33 @@ func (s ActivityService) UpdateActivity(activityID, userID string, updateReq dto
 	// Recalculate calories based on current activity type and duration
 	activity.CaloriesBurned = activity.ActivityType.CalculateBurnedCalories(activity.DurationInMinutes)
 
-	// Update in database
-	updatedActivity, err := s.activityRepository.UpdateActivity(activity)
-	if err != nil {
+	// The update only applies to the version read, so before is exactly what
+	// it replaces
+	var updatedActivity entity.Activity
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		updatedActivity, err = s.activityRepository.UpdateActivity(ctx, activity)
+		if err != nil {
+			return err
+		}
+		err = s.auditService.Record(ctx, entity.AuditUpdate, entity.AuditEntityActivity, updatedActivity.ID, updatedActivity.UserID,
+			entity.DiffAuditFields(before.AuditFields(), updatedActivity.AuditFields()))
+		if err != nil {
+			return err
+		}
+		return s.outboxService.Emit(ctx, events.ActivityUpdated, updatedActivity.ID, updatedActivity.UserID, events.ActivityUpdate{
+			Previous: events.NewActivity(before),
+			Current:  events.NewActivity(updatedActivity),
+		})
+	})
+	if errors.Is(err, dto.ErrVersionConflict) {
+		return dto.ActivityResponse{}, versionConflict(version)
+	} else if err != nil {
 		return dto.ActivityResponse{}, err
 	}
 
+	s.invalidateCache(ctx, userID)
+
 	// Preserve the original format if it was provided in the request
 	var responseDoneAt dto.PreciseTime
 	if updateReq.DoneAt != nil {
@@ -125,18 +264,128 @@ func (s ActivityService) UpdateActivity(activityID, userID string, updateReq dto
 		DoneAt:            responseDoneAt,
 		DurationInMinutes: updatedActivity.DurationInMinutes,
 		CaloriesBurned:    updatedActivity.CaloriesBurned,
+		Version:           updatedActivity.Version,
 		CreatedAt:         updatedActivity.CreatedAt,
 		UpdatedAt:         updatedActivity.UpdatedAt,
 	}, nil
 }
 
-func (s ActivityService) DeleteActivity(activityID, userID string) error {
-	// First check if the activity exists and belongs to the user
-	_, err := s.activityRepository.GetActivityByID(activityID, userID)
-	if err != nil {
+// DeleteActivity moves the activity to the trash, from which it can be
+// restored until the retention job purges it
+func (s ActivityService) DeleteActivity(ctx context.Context, activityID, userID string) error {
+	ctx, span := tracing.Start(ctx, "ActivityService.DeleteActivity", attribute.String("user.id", userID))
+	defer span.End()
+
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		deleted, err := s.activityRepository.DeleteActivity(ctx, activityID, userID)
+		if err != nil {
+			return err
+		}
+		err = s.auditService.Record(ctx, entity.AuditDelete, entity.AuditEntityActivity, deleted.ID, deleted.UserID,
+			entity.DiffAuditFields(deleted.AuditFields(), nil))
+		if err != nil {
+			return err
+		}
+		return s.outboxService.Emit(ctx, events.ActivityDeleted, deleted.ID, deleted.UserID, events.NewActivity(deleted))
+	})
+	if errors.Is(err, dto.ErrActivityNotFound) {
+		return ErrActivityNotFound
+	} else if err != nil {
 		return err
 	}
 
-	// Delete the activity
-	return s.activityRepository.DeleteActivity(activityID, userID)
+	s.invalidateCache(ctx, userID)
+	return nil
+}
+
+// GetTrash lists the user's trashed activities, most recently deleted first
+func (s ActivityService) GetTrash(ctx context.Context, filter dto.TrashFilter, userID string) ([]dto.TrashedActivityResponse, error) {
+	ctx, span := tracing.Start(ctx, "ActivityService.GetTrash", attribute.String("user.id", userID))
+	defer span.End()
+
+	activities, err := s.activityRepository.GetTrash(ctx, filter, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := []dto.TrashedActivityResponse{}
+	for _, activity := range activities {
+		responses = append(responses, dto.TrashedActivityResponse{
+			ActivityResponse: newActivityResponse(activity),
+			DeletedAt:        activity.DeletedAt.Time,
+		})
+	}
+	return responses, nil
+}
+
+// RestoreActivity takes the activity out of the trash. Activities that aren't
+// in the trash are ErrActivityNotFound.
+func (s ActivityService) RestoreActivity(ctx context.Context, activityID, userID string) (dto.ActivityResponse, error) {
+	ctx, span := tracing.Start(ctx, "ActivityService.RestoreActivity", attribute.String("user.id", userID))
+	defer span.End()
+
+	var activity entity.Activity
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		activity, err = s.activityRepository.RestoreActivity(ctx, activityID, userID)
+		if err != nil {
+			return err
+		}
+		// The fields are back as they were when deleted
+		err = s.auditService.Record(ctx, entity.AuditRestore, entity.AuditEntityActivity, activity.ID, activity.UserID, nil)
+		if err != nil {
+			return err
+		}
+		return s.outboxService.Emit(ctx, events.ActivityRestored, activity.ID, activity.UserID, events.NewActivity(activity))
+	})
+	if errors.Is(err, dto.ErrActivityNotFound) {
+		return dto.ActivityResponse{}, ErrActivityNotFound
+	} else if err != nil {
+		return dto.ActivityResponse{}, err
+	}
+
+	s.invalidateCache(ctx, userID)
+	return newActivityResponse(activity), nil
+}
+
+// GetHistory lists the changes made to one of the user's activities, most
+// recent first. It stays available once the activity is deleted or purged.
+func (s ActivityService) GetHistory(ctx context.Context, activityID, userID string, filter dto.HistoryFilter) ([]dto.AuditEntryResponse, error) {
+	ctx, span := tracing.Start(ctx, "ActivityService.GetHistory", attribute.String("user.id", userID))
+	defer span.End()
+
+	history, err := s.auditService.Search(ctx, dto.AuditFilter{
+		OwnerID:    userID,
+		EntityType: entity.AuditEntityActivity,
+		EntityID:   activityID,
+		Limit:      filter.Limit,
+		Offset:     filter.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Activities created before the audit log existed have no history, an
+	// empty one is only returned for activities of the user
+	if len(history) == 0 {
+		_, err := s.activityRepository.GetActivityByID(ctx, activityID, userID)
+		if errors.Is(err, dto.ErrActivityNotFound) {
+			return nil, ErrActivityNotFound
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
+
+func newActivityResponse(activity entity.Activity) dto.ActivityResponse {
+	return dto.ActivityResponse{
+		ID:                activity.ID,
+		ActivityType:      activity.ActivityType,
+		DoneAt:            dto.PreciseTime{Time: activity.DoneAt},
+		DurationInMinutes: activity.DurationInMinutes,
+		CaloriesBurned:    activity.CaloriesBurned,
+		Version:           activity.Version,
+		CreatedAt:         activity.CreatedAt,
+		UpdatedAt:         activity.UpdatedAt,
+	}
 }
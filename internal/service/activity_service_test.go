@@ -0,0 +1,472 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/google/uuid"
+)
+
+func newActivityService() service.ActivityService {
+	return newActivityServiceWith(repository.NewMemoryRepositories(clock.System))
+}
+
+func newActivityServiceWith(repos repository.Repositories) service.ActivityService {
+	return service.NewActivityService(repos.Activities, service.NewMemoryCacheService(100), repos.Transactor, service.NewAuditService(repos.Audit), service.NewOutboxService(repos.Outbox))
+}
+
+func createActivity(t *testing.T, s service.ActivityService, userID string, activityType entity.ActivityType, minutes int) dto.CreateActivityResponse {
+	t.Helper()
+
+	created, err := s.CreateActivity(context.Background(), dto.ActivityRequest{
+		ActivityType:      activityType,
+		DoneAt:            time.Date(2025, 1, 15, 7, 30, 0, 0, time.UTC),
+		DurationInMinutes: minutes,
+	}, userID)
+	if err != nil {
+		t.Fatalf("CreateActivity: %v", err)
+	}
+	return created
+}
+
+func TestActivityServiceCreateActivity(t *testing.T) {
+	userID := uuid.NewString()
+
+	tests := []struct {
+		name         string
+		activityType entity.ActivityType
+		minutes      int
+		wantCalories int
+		wantErr      error
+	}{
+		{name: "low intensity", activityType: entity.Walking, minutes: 30, wantCalories: 120},
+		{name: "medium intensity", activityType: entity.Cycling, minutes: 30, wantCalories: 240},
+		{name: "high intensity", activityType: entity.HIIT, minutes: 15, wantCalories: 150},
+		{name: "unknown type", activityType: "Sleeping", minutes: 30, wantErr: service.ErrInvalidActivityType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newActivityService()
+
+			created, err := s.CreateActivity(context.Background(), dto.ActivityRequest{
+				ActivityType:      tt.activityType,
+				DoneAt:            time.Now(),
+				DurationInMinutes: tt.minutes,
+			}, userID)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if created.ID == uuid.Nil {
+				t.Error("ID wasn't assigned")
+			}
+			if created.CaloriesBurned != tt.wantCalories {
+				t.Errorf("CaloriesBurned = %d, want %d", created.CaloriesBurned, tt.wantCalories)
+			}
+		})
+	}
+}
+
+func TestActivityServiceUpdateActivity(t *testing.T) {
+	ptr := func(v entity.ActivityType) *entity.ActivityType { return &v }
+	minutes := func(v int) *int { return &v }
+
+	tests := []struct {
+		name         string
+		otherUser    bool
+		activityID   string
+		request      dto.ActivityUpdateRequest
+		wantType     entity.ActivityType
+		wantCalories int
+		wantErr      error
+	}{
+		{
+			name:         "duration recalculates calories",
+			request:      dto.ActivityUpdateRequest{DurationInMinutes: minutes(60)},
+			wantType:     entity.Walking,
+			wantCalories: 240,
+		},
+		{
+			name:         "type recalculates calories",
+			request:      dto.ActivityUpdateRequest{ActivityType: ptr(entity.Running)},
+			wantType:     entity.Running,
+			wantCalories: 300,
+		},
+		{
+			name:    "invalid type",
+			request: dto.ActivityUpdateRequest{ActivityType: ptr("Sleeping")},
+			wantErr: service.ErrInvalidActivityType,
+		},
+		{
+			name:       "unknown activity",
+			activityID: uuid.NewString(),
+			request:    dto.ActivityUpdateRequest{DurationInMinutes: minutes(60)},
+			wantErr:    service.ErrActivityNotFound,
+		},
+		{
+			name:      "another user's activity",
+			otherUser: true,
+			request:   dto.ActivityUpdateRequest{DurationInMinutes: minutes(60)},
+			wantErr:   service.ErrActivityNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newActivityService()
+			userID := uuid.NewString()
+			created := createActivity(t, s, userID, entity.Walking, 30)
+
+			activityID := created.ID.String()
+			if tt.activityID != "" {
+				activityID = tt.activityID
+			}
+			if tt.otherUser {
+				userID = uuid.NewString()
+			}
+
+			updated, err := s.UpdateActivity(context.Background(), activityID, userID, 0, tt.request)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if updated.ActivityType != tt.wantType {
+				t.Errorf("ActivityType = %s, want %s", updated.ActivityType, tt.wantType)
+			}
+			if updated.CaloriesBurned != tt.wantCalories {
+				t.Errorf("CaloriesBurned = %d, want %d", updated.CaloriesBurned, tt.wantCalories)
+			}
+		})
+	}
+}
+
+func TestActivityServiceDeleteActivity(t *testing.T) {
+	s := newActivityService()
+	userID := uuid.NewString()
+	created := createActivity(t, s, userID, entity.Yoga, 20)
+
+	if err := s.DeleteActivity(context.Background(), created.ID.String(), uuid.NewString()); !errors.Is(err, service.ErrActivityNotFound) {
+		t.Fatalf("deleting another user's activity: err = %v, want %v", err, service.ErrActivityNotFound)
+	}
+	if err := s.DeleteActivity(context.Background(), created.ID.String(), userID); err != nil {
+		t.Fatalf("DeleteActivity: %v", err)
+	}
+	if err := s.DeleteActivity(context.Background(), created.ID.String(), userID); !errors.Is(err, service.ErrActivityNotFound) {
+		t.Fatalf("deleting twice: err = %v, want %v", err, service.ErrActivityNotFound)
+	}
+}
+
+func TestActivityServiceUpdateVersion(t *testing.T) {
+	s := newActivityService()
+	userID := uuid.NewString()
+	created := createActivity(t, s, userID, entity.Walking, 30)
+	if created.Version != 1 {
+		t.Fatalf("Version = %d, want 1", created.Version)
+	}
+	activityID := created.ID.String()
+	minutes := 45
+
+	updated, err := s.UpdateActivity(context.Background(), activityID, userID, 1, dto.ActivityUpdateRequest{DurationInMinutes: &minutes})
+	if err != nil {
+		t.Fatalf("UpdateActivity: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Errorf("Version = %d, want 2", updated.Version)
+	}
+
+	// The update was based on a version that has since changed
+	if _, err := s.UpdateActivity(context.Background(), activityID, userID, 1, dto.ActivityUpdateRequest{DurationInMinutes: &minutes}); !errors.Is(err, service.ErrVersionMismatch) {
+		t.Fatalf("stale version: err = %v, want %v", err, service.ErrVersionMismatch)
+	}
+
+	fetched, err := s.GetActivityByID(context.Background(), activityID, userID)
+	if err != nil {
+		t.Fatalf("GetActivityByID: %v", err)
+	}
+	if fetched.Version != 2 || fetched.DurationInMinutes != minutes {
+		t.Errorf("activity = %+v, want version 2 with the first update applied", fetched)
+	}
+	if _, err := s.GetActivityByID(context.Background(), activityID, uuid.NewString()); !errors.Is(err, service.ErrActivityNotFound) {
+		t.Errorf("another user's activity: err = %v, want %v", err, service.ErrActivityNotFound)
+	}
+}
+
+// Cached pages must not outlive a write, whichever write it is
+func TestActivityServiceCacheInvalidation(t *testing.T) {
+	minutes := 45
+
+	tests := []struct {
+		name      string
+		write     func(s service.ActivityService, userID, activityID string) error
+		wantCount int
+		// wantMinutes is the duration of the first activity, when checked
+		wantMinutes int
+	}{
+		{
+			name: "create",
+			write: func(s service.ActivityService, userID, _ string) error {
+				_, err := s.CreateActivity(context.Background(), dto.ActivityRequest{ActivityType: entity.Running, DoneAt: time.Now(), DurationInMinutes: 10}, userID)
+				return err
+			},
+			wantCount: 2,
+		},
+		{
+			name: "update",
+			write: func(s service.ActivityService, userID, activityID string) error {
+				_, err := s.UpdateActivity(context.Background(), activityID, userID, 0, dto.ActivityUpdateRequest{DurationInMinutes: &minutes})
+				return err
+			},
+			wantCount:   1,
+			wantMinutes: minutes,
+		},
+		{
+			name: "delete",
+			write: func(s service.ActivityService, userID, activityID string) error {
+				return s.DeleteActivity(context.Background(), activityID, userID)
+			},
+			wantCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newActivityService()
+			userID := uuid.NewString()
+			created := createActivity(t, s, userID, entity.Swimming, 30)
+
+			// Fill the cache
+			if _, err := s.GetActivity(context.Background(), dto.ActivityFilter{}, userID); err != nil {
+				t.Fatalf("GetActivity: %v", err)
+			}
+			if _, err := s.GetActivitySummary(context.Background(), dto.ActivitySummaryFilter{}, userID); err != nil {
+				t.Fatalf("GetActivitySummary: %v", err)
+			}
+
+			if err := tt.write(s, userID, created.ID.String()); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			activities, err := s.GetActivity(context.Background(), dto.ActivityFilter{}, userID)
+			if err != nil {
+				t.Fatalf("GetActivity: %v", err)
+			}
+			if len(activities) != tt.wantCount {
+				t.Errorf("got %d activities, want %d", len(activities), tt.wantCount)
+			}
+			if tt.wantMinutes != 0 && activities[0].DurationInMinutes != tt.wantMinutes {
+				t.Errorf("DurationInMinutes = %d, want %d", activities[0].DurationInMinutes, tt.wantMinutes)
+			}
+
+			summary, err := s.GetActivitySummary(context.Background(), dto.ActivitySummaryFilter{}, userID)
+			if err != nil {
+				t.Fatalf("GetActivitySummary: %v", err)
+			}
+			if summary.TotalActivities != tt.wantCount {
+				t.Errorf("summary counts %d activities, want %d", summary.TotalActivities, tt.wantCount)
+			}
+		})
+	}
+}
+
+// failingBumpCache can't move users to a new activity version
+type failingBumpCache struct {
+	service.CacheService
+}
+
+func (failingBumpCache) BumpActivityVersion(context.Context, string) error {
+	return service.ErrCacheUnavailable
+}
+
+// A write whose version bump fails must still not be followed by stale pages
+func TestActivityServiceCacheInvalidationBumpFails(t *testing.T) {
+	repos := repository.NewMemoryRepositories(clock.System)
+	cache := failingBumpCache{CacheService: service.NewMemoryCacheService(100)}
+	s := service.NewActivityService(repos.Activities, cache, repos.Transactor, service.NewAuditService(repos.Audit), service.NewOutboxService(repos.Outbox))
+	userID := uuid.NewString()
+	createActivity(t, s, userID, entity.Swimming, 30)
+
+	// Fill the cache
+	if _, err := s.GetActivity(context.Background(), dto.ActivityFilter{}, userID); err != nil {
+		t.Fatalf("GetActivity: %v", err)
+	}
+	if _, err := s.GetActivitySummary(context.Background(), dto.ActivitySummaryFilter{}, userID); err != nil {
+		t.Fatalf("GetActivitySummary: %v", err)
+	}
+
+	createActivity(t, s, userID, entity.Running, 10)
+
+	activities, err := s.GetActivity(context.Background(), dto.ActivityFilter{}, userID)
+	if err != nil {
+		t.Fatalf("GetActivity: %v", err)
+	}
+	if len(activities) != 2 {
+		t.Errorf("got %d activities, want 2", len(activities))
+	}
+	summary, err := s.GetActivitySummary(context.Background(), dto.ActivitySummaryFilter{}, userID)
+	if err != nil {
+		t.Fatalf("GetActivitySummary: %v", err)
+	}
+	if summary.TotalActivities != 2 {
+		t.Errorf("summary counts %d activities, want 2", summary.TotalActivities)
+	}
+}
+
+func TestActivityServiceGetActivitySummary(t *testing.T) {
+	s := newActivityService()
+	userID := uuid.NewString()
+	createActivity(t, s, userID, entity.Running, 30)
+	createActivity(t, s, userID, entity.Running, 10)
+	createActivity(t, s, userID, entity.Cycling, 20)
+	createActivity(t, s, uuid.NewString(), entity.Running, 60)
+
+	summary, err := s.GetActivitySummary(context.Background(), dto.ActivitySummaryFilter{}, userID)
+	if err != nil {
+		t.Fatalf("GetActivitySummary: %v", err)
+	}
+
+	want := dto.ActivitySummaryResponse{
+		TotalActivities:        3,
+		TotalDurationInMinutes: 60,
+		TotalCaloriesBurned:    560,
+		ByType: []dto.ActivityTypeSummary{
+			{ActivityType: entity.Cycling, TotalActivities: 1, TotalDurationInMinutes: 20, TotalCaloriesBurned: 160},
+			{ActivityType: entity.Running, TotalActivities: 2, TotalDurationInMinutes: 40, TotalCaloriesBurned: 400},
+		},
+	}
+	if summary.TotalActivities != want.TotalActivities || summary.TotalDurationInMinutes != want.TotalDurationInMinutes || summary.TotalCaloriesBurned != want.TotalCaloriesBurned {
+		t.Errorf("totals = %+v, want %+v", summary, want)
+	}
+	if len(summary.ByType) != len(want.ByType) {
+		t.Fatalf("ByType = %+v, want %+v", summary.ByType, want.ByType)
+	}
+	for i := range want.ByType {
+		if summary.ByType[i] != want.ByType[i] {
+			t.Errorf("ByType[%d] = %+v, want %+v", i, summary.ByType[i], want.ByType[i])
+		}
+	}
+}
+
+func TestActivityServiceCancelledContext(t *testing.T) {
+	s := newActivityService()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := s.GetActivity(ctx, dto.ActivityFilter{}, uuid.NewString())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestActivityServiceTrash(t *testing.T) {
+	s := newActivityService()
+	userID := uuid.NewString()
+	kept := createActivity(t, s, userID, entity.Running, 30)
+	trashed := createActivity(t, s, userID, entity.Yoga, 20)
+
+	if err := s.DeleteActivity(context.Background(), trashed.ID.String(), userID); err != nil {
+		t.Fatalf("DeleteActivity: %v", err)
+	}
+
+	list, err := s.GetActivity(context.Background(), dto.ActivityFilter{}, userID)
+	if err != nil {
+		t.Fatalf("GetActivity: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != kept.ID {
+		t.Errorf("list = %+v, want only the kept activity", list)
+	}
+	summary, err := s.GetActivitySummary(context.Background(), dto.ActivitySummaryFilter{}, userID)
+	if err != nil {
+		t.Fatalf("GetActivitySummary: %v", err)
+	}
+	if summary.TotalActivities != 1 || summary.TotalCaloriesBurned != kept.CaloriesBurned {
+		t.Errorf("summary = %+v, want only the kept activity", summary)
+	}
+
+	trash, err := s.GetTrash(context.Background(), dto.TrashFilter{}, userID)
+	if err != nil {
+		t.Fatalf("GetTrash: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID != trashed.ID || trash[0].DeletedAt.IsZero() {
+		t.Fatalf("trash = %+v, want the deleted activity", trash)
+	}
+
+	if _, err := s.RestoreActivity(context.Background(), trashed.ID.String(), uuid.NewString()); !errors.Is(err, service.ErrActivityNotFound) {
+		t.Errorf("restoring another user's activity: err = %v, want %v", err, service.ErrActivityNotFound)
+	}
+	restored, err := s.RestoreActivity(context.Background(), trashed.ID.String(), userID)
+	if err != nil {
+		t.Fatalf("RestoreActivity: %v", err)
+	}
+	if restored.Version != trashed.Version+1 {
+		t.Errorf("Version = %d, want %d", restored.Version, trashed.Version+1)
+	}
+	if _, err := s.RestoreActivity(context.Background(), trashed.ID.String(), userID); !errors.Is(err, service.ErrActivityNotFound) {
+		t.Errorf("restoring twice: err = %v, want %v", err, service.ErrActivityNotFound)
+	}
+
+	// Restoring bumps the cache version like any other write
+	list, err = s.GetActivity(context.Background(), dto.ActivityFilter{}, userID)
+	if err != nil {
+		t.Fatalf("GetActivity: %v", err)
+	}
+	if len(list) != 2 {
+		t.Errorf("got %d activities after restoring, want 2", len(list))
+	}
+}
+
+func TestTrashRetentionPurge(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC))
+	repos := repository.NewMemoryRepositories(clk)
+	s := newActivityServiceWith(repos)
+	retention := service.NewTrashRetention(&config.Config{TrashRetentionDays: 7}, repos.Activities, clk)
+	userID := uuid.NewString()
+
+	old := createActivity(t, s, userID, entity.Running, 30)
+	if err := s.DeleteActivity(context.Background(), old.ID.String(), userID); err != nil {
+		t.Fatalf("DeleteActivity: %v", err)
+	}
+	clk.Advance(5 * 24 * time.Hour)
+	recent := createActivity(t, s, userID, entity.Yoga, 20)
+	if err := s.DeleteActivity(context.Background(), recent.ID.String(), userID); err != nil {
+		t.Fatalf("DeleteActivity: %v", err)
+	}
+	createActivity(t, s, userID, entity.Walking, 10)
+
+	clk.Advance(3 * 24 * time.Hour)
+	purged, err := retention.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("purged %d activities, want 1", purged)
+	}
+
+	trash, err := s.GetTrash(context.Background(), dto.TrashFilter{}, userID)
+	if err != nil {
+		t.Fatalf("GetTrash: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID != recent.ID {
+		t.Errorf("trash = %+v, want only the activity deleted 3 days ago", trash)
+	}
+	if _, err := s.RestoreActivity(context.Background(), old.ID.String(), userID); !errors.Is(err, service.ErrActivityNotFound) {
+		t.Errorf("restoring a purged activity: err = %v, want %v", err, service.ErrActivityNotFound)
+	}
+	if list, _ := s.GetActivity(context.Background(), dto.ActivityFilter{}, userID); len(list) != 1 {
+		t.Errorf("got %d activities, want the one never deleted", len(list))
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/fikrialwan/FitByte/internal/logging"
+	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/fikrialwan/FitByte/internal/tracing"
+	"github.com/google/uuid"
+)
+
+// AuditService records who changed which activity or profile, and how
+type AuditService struct {
+	auditRepository repository.AuditRepository
+}
+
+func NewAuditService(auditRepository repository.AuditRepository) AuditService {
+	return AuditService{auditRepository}
+}
+
+// Record appends an entry for a change to an entity owned by ownerID. The
+// actor is the authenticated user when there is one, the owner otherwise.
+// Call it within the transaction making the change, so neither is kept
+// without the other.
+func (s AuditService) Record(ctx context.Context, action entity.AuditAction, entityType string, entityID, ownerID uuid.UUID, changes entity.AuditChanges) error {
+	actorID := ownerID
+	if userID, err := uuid.Parse(logging.UserID(ctx)); err == nil {
+		actorID = userID
+	}
+
+	return s.auditRepository.Append(ctx, &entity.AuditEntry{
+		ActorID:    actorID,
+		OwnerID:    ownerID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Changes:    changes,
+		RequestID:  logging.RequestID(ctx),
+	})
+}
+
+// Search looks through every user's entries, for administrators
+func (s AuditService) Search(ctx context.Context, filter dto.AuditFilter) ([]dto.AuditEntryResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuditService.Search")
+	defer span.End()
+
+	entries, err := s.auditRepository.Search(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := []dto.AuditEntryResponse{}
+	for _, entry := range entries {
+		responses = append(responses, dto.NewAuditEntryResponse(entry))
+	}
+	return responses, nil
+}
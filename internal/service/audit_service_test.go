@@ -0,0 +1,122 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/fikrialwan/FitByte/internal/logging"
+	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/google/uuid"
+)
+
+// failingAuditRepository refuses every entry, as a full disk would
+type failingAuditRepository struct {
+	repository.AuditRepository
+}
+
+func (failingAuditRepository) Append(context.Context, *entity.AuditEntry) error {
+	return errors.New("audit log unavailable")
+}
+
+func TestActivityServiceHistory(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC))
+	s := newActivityServiceWith(repository.NewMemoryRepositories(clk))
+	userID := uuid.NewString()
+	ctx := logging.WithRequestID(logging.WithUserID(context.Background(), userID), "req-1")
+
+	duration := 60
+	created := createActivity(t, s, userID, entity.Walking, 30)
+	activityID := created.ID.String()
+	clk.Advance(time.Minute)
+	if _, err := s.UpdateActivity(ctx, activityID, userID, 0, dto.ActivityUpdateRequest{DurationInMinutes: &duration}); err != nil {
+		t.Fatalf("UpdateActivity: %v", err)
+	}
+	clk.Advance(time.Minute)
+	if err := s.DeleteActivity(ctx, activityID, userID); err != nil {
+		t.Fatalf("DeleteActivity: %v", err)
+	}
+
+	// The history outlives the activity, most recent change first
+	history, err := s.GetHistory(ctx, activityID, userID, dto.HistoryFilter{})
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	var actions []entity.AuditAction
+	for _, entry := range history {
+		actions = append(actions, entry.Action)
+	}
+	if want := []entity.AuditAction{entity.AuditDelete, entity.AuditUpdate, entity.AuditCreate}; !slices.Equal(actions, want) {
+		t.Fatalf("actions = %v, want %v", actions, want)
+	}
+
+	update := history[1]
+	if update.ActorID.String() != userID || update.OwnerID.String() != userID || update.RequestID != "req-1" {
+		t.Errorf("update entry = %+v, want acted by the owner in req-1", update)
+	}
+	if len(update.Changes) != 2 {
+		t.Errorf("update changes = %v, want the duration and calories", update.Changes)
+	}
+	if change := update.Changes["durationInMinutes"]; change.Before != 30 || change.After != 60 {
+		t.Errorf("durationInMinutes change = %+v, want 30 to 60", change)
+	}
+	if change := history[2].Changes["activityType"]; change.Before != nil || change.After != string(entity.Walking) {
+		t.Errorf("create activityType change = %+v, want nothing to %s", change, entity.Walking)
+	}
+	if change := history[0].Changes["activityType"]; change.Before != string(entity.Walking) || change.After != nil {
+		t.Errorf("delete activityType change = %+v, want %s to nothing", change, entity.Walking)
+	}
+
+	// Paging
+	page, err := s.GetHistory(ctx, activityID, userID, dto.HistoryFilter{Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if len(page) != 1 || page[0].Action != entity.AuditUpdate {
+		t.Errorf("page = %+v, want the update", page)
+	}
+
+	// Other users can't see it
+	if _, err := s.GetHistory(ctx, activityID, uuid.NewString(), dto.HistoryFilter{}); !errors.Is(err, service.ErrActivityNotFound) {
+		t.Errorf("other user: err = %v, want %v", err, service.ErrActivityNotFound)
+	}
+}
+
+func TestActivityServiceAuditRollback(t *testing.T) {
+	repos := repository.NewMemoryRepositories(clock.System)
+	userID := uuid.NewString()
+	created := createActivity(t, newActivityServiceWith(repos), userID, entity.Walking, 30)
+	duration := 60
+
+	// Without its audit entry a change isn't made at all
+	repos.Audit = failingAuditRepository{repos.Audit}
+	s := newActivityServiceWith(repos)
+
+	if _, err := s.CreateActivity(context.Background(), dto.ActivityRequest{
+		ActivityType:      entity.Running,
+		DoneAt:            time.Date(2025, 1, 15, 7, 30, 0, 0, time.UTC),
+		DurationInMinutes: 10,
+	}, userID); err == nil {
+		t.Error("CreateActivity succeeded without an audit entry")
+	}
+	if _, err := s.UpdateActivity(context.Background(), created.ID.String(), userID, 0, dto.ActivityUpdateRequest{DurationInMinutes: &duration}); err == nil {
+		t.Error("UpdateActivity succeeded without an audit entry")
+	}
+	if err := s.DeleteActivity(context.Background(), created.ID.String(), userID); err == nil {
+		t.Error("DeleteActivity succeeded without an audit entry")
+	}
+
+	activities, err := s.GetActivity(context.Background(), dto.ActivityFilter{}, userID)
+	if err != nil {
+		t.Fatalf("GetActivity: %v", err)
+	}
+	if len(activities) != 1 || activities[0].DurationInMinutes != 30 || activities[0].Version != 1 {
+		t.Errorf("activities = %+v, want only the original, unchanged", activities)
+	}
+}
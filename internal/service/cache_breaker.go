@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker wraps a cacheStore and stops calling it after threshold
+// consecutive failures. While open every call fails fast with
+// ErrCacheUnavailable so callers fall back to the database without paying the
+// backend timeout. After cooldown a single probe call is let through.
+type circuitBreaker struct {
+	store     cacheStore
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(store cacheStore, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		store:     store,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+func (b *circuitBreaker) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if !b.allow() {
+		return ErrCacheUnavailable
+	}
+	err := b.store.Set(ctx, key, value, ttl)
+	b.record(ctx, err)
+	return err
+}
+
+func (b *circuitBreaker) Get(ctx context.Context, key string) (string, error) {
+	if !b.allow() {
+		return "", ErrCacheUnavailable
+	}
+	value, err := b.store.Get(ctx, key)
+	b.record(ctx, err)
+	return value, err
+}
+
+func (b *circuitBreaker) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	if !b.allow() {
+		return 0, ErrCacheUnavailable
+	}
+	count, err := b.store.Incr(ctx, key, ttl)
+	b.record(ctx, err)
+	return count, err
+}
+
+func (b *circuitBreaker) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if !b.allow() {
+		return false, ErrCacheUnavailable
+	}
+	ok, err := b.store.SetNX(ctx, key, value, ttl)
+	b.record(ctx, err)
+	return ok, err
+}
+
+func (b *circuitBreaker) Delete(ctx context.Context, key string) error {
+	if !b.allow() {
+		return ErrCacheUnavailable
+	}
+	err := b.store.Delete(ctx, key)
+	b.record(ctx, err)
+	return err
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// Only the probe request goes through until it reports back
+		return false
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of a call. A call cut short
+// because the caller's context ended says nothing about the backend and only
+// releases the probe slot.
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err != nil && ctx.Err() != nil {
+		if b.state == breakerHalfOpen {
+			b.state = breakerOpen
+		}
+		return
+	}
+
+	if err == nil || errors.Is(err, ErrCacheMiss) {
+		if b.state != breakerClosed {
+			slog.InfoContext(ctx, "cache backend recovered, closing circuit breaker")
+		}
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		if b.state != breakerOpen {
+			slog.WarnContext(ctx, "cache backend unavailable, serving from database", "cooldown", b.cooldown.String(), "error", err)
+		}
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
@@ -3,95 +3,323 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fikrialwan/FitByte/config"
 	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/metrics"
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	ErrCacheMiss        = errors.New("cache miss")
+	ErrCacheUnavailable = errors.New("cache unavailable")
+)
+
 type CacheService interface {
-	SetUserProfile(userID string, profile dto.UserResponse, ttl time.Duration) error
-	GetUserProfile(userID string) (dto.UserResponse, error)
-	DeleteUserProfile(userID string) error
-	SetJWTBlacklist(token string, ttl time.Duration) error
-	IsJWTBlacklisted(token string) bool
-	Set(key string, value interface{}, ttl time.Duration) error
-	Get(key string) (string, error)
-	Delete(key string) error
+	SetUserProfile(ctx context.Context, userID string, profile dto.UserResponse, ttl time.Duration) error
+	GetUserProfile(ctx context.Context, userID string) (dto.UserResponse, error)
+	DeleteUserProfile(ctx context.Context, userID string) error
+	SetJWTBlacklist(ctx context.Context, token string, ttl time.Duration) error
+	IsJWTBlacklisted(ctx context.Context, token string) bool
+	GetActivityVersion(ctx context.Context, userID string) (int64, error)
+	BumpActivityVersion(ctx context.Context, userID string) error
+	DeleteActivityVersion(ctx context.Context, userID string) error
+	SetActivityList(ctx context.Context, userID string, version int64, query string, activities []dto.ActivityResponse, ttl time.Duration) error
+	GetActivityList(ctx context.Context, userID string, version int64, query string) ([]dto.ActivityResponse, error)
+	SetActivitySummary(ctx context.Context, userID string, version int64, query string, summary dto.ActivitySummaryResponse, ttl time.Duration) error
+	GetActivitySummary(ctx context.Context, userID string, version int64, query string) (dto.ActivitySummaryResponse, error)
+	ReserveIdempotencyKey(ctx context.Context, userID, key string, record dto.IdempotencyRecord, ttl time.Duration) (dto.IdempotencyRecord, bool, error)
+	SetIdempotencyRecord(ctx context.Context, userID, key string, record dto.IdempotencyRecord, ttl time.Duration) error
+	DeleteIdempotencyRecord(ctx context.Context, userID, key string) error
+	Stats() CacheStats
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Get(ctx context.Context, key string) (string, error)
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// CacheStats holds hit/miss counters per cached resource
+type CacheStats map[string]CacheCounter
+
+type CacheCounter struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// cacheStore is the raw key/value backend behind CacheService. Get returns
+// ErrCacheMiss when the key doesn't exist.
+type cacheStore interface {
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Get(ctx context.Context, key string) (string, error)
+	// Incr atomically increments the counter at key. ttl is only applied when
+	// the counter is created, so it expires ttl after the first increment.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// SetNX stores value only when key doesn't exist and reports whether it
+	// did
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) error
 }
 
 type cacheService struct {
-	client *redis.Client
+	store cacheStore
+
+	mu       sync.Mutex
+	counters map[string]*cacheCounter
 }
 
-func NewCacheService(config *config.Config) CacheService {
-	redisAddr := config.RedisAddr
-	if redisAddr == "" {
-		redisAddr = "localhost:6379"
-	}
+type cacheCounter struct {
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
 
-	rdb := redis.NewClient(&redis.Options{
-		Addr:         redisAddr,
-		Password:     config.RedisPassword,
-		DB:           0,
-		PoolSize:     50,
-		MinIdleConns: 10,
-		MaxRetries:   3,
-	})
+const (
+	activityVersionPrefix = "activity:version:"
+	idempotencyPrefix     = "idempotency:"
+	// idempotencyReserveAttempts bounds the retries of a reservation whose
+	// record keeps vanishing before it can be read
+	idempotencyReserveAttempts = 3
+)
 
-	return &cacheService{
-		client: rdb,
+// NewCacheService builds the cache selected by CACHE_DRIVER:
+//   - redis (default): go-redis behind a circuit breaker
+//   - memory: in-process LRU, for tests and single-node deployments
+//   - tiered: in-process LRU in front of Redis
+func NewCacheService(config *config.Config, redisClient *redis.Client) CacheService {
+	switch strings.ToLower(config.CacheDriver) {
+	case "memory":
+		return NewMemoryCacheService(config.GetCacheLocalMaxEntries())
+	case "tiered":
+		local := newMemoryStore(config.GetCacheLocalMaxEntries())
+		// Version and login keys must be shared by every replica, otherwise a
+		// local copy could point at invalidated pages or outlive an unlock
+		remote := newBreakerRedisStore(config, redisClient)
+		return newCacheService(newTieredStore(local, remote, config.GetCacheLocalTTL(), activityVersionPrefix, loginAttemptPrefix, idempotencyPrefix))
+	case "", "redis":
+		return newCacheService(newBreakerRedisStore(config, redisClient))
+	default:
+		slog.Warn("unknown CACHE_DRIVER, falling back to redis", "driver", config.CacheDriver)
+		return newCacheService(newBreakerRedisStore(config, redisClient))
 	}
 }
 
-func (c *cacheService) SetUserProfile(userID string, profile dto.UserResponse, ttl time.Duration) error {
-	key := fmt.Sprintf("user:profile:%s", userID)
-	data, err := json.Marshal(profile)
-	if err != nil {
-		return err
+// NewMemoryCacheService returns a CacheService backed only by an in-process LRU
+func NewMemoryCacheService(maxEntries int) CacheService {
+	return newCacheService(newMemoryStore(maxEntries))
+}
+
+func newCacheService(store cacheStore) *cacheService {
+	return &cacheService{
+		store:    store,
+		counters: make(map[string]*cacheCounter),
 	}
+}
 
-	return c.client.Set(context.Background(), key, data, ttl).Err()
+func newBreakerRedisStore(config *config.Config, redisClient *redis.Client) cacheStore {
+	return newCircuitBreaker(newRedisStore(redisClient, config.GetCacheTimeout()), config.GetCacheBreakerThreshold(), config.GetCacheBreakerCooldown())
 }
 
-func (c *cacheService) GetUserProfile(userID string) (dto.UserResponse, error) {
+func (c *cacheService) SetUserProfile(ctx context.Context, userID string, profile dto.UserResponse, ttl time.Duration) error {
 	key := fmt.Sprintf("user:profile:%s", userID)
-	data, err := c.client.Get(context.Background(), key).Result()
-	if err != nil {
-		return dto.UserResponse{}, err
-	}
+	return c.setJSON(ctx, key, profile, ttl)
+}
 
+func (c *cacheService) GetUserProfile(ctx context.Context, userID string) (dto.UserResponse, error) {
+	key := fmt.Sprintf("user:profile:%s", userID)
 	var profile dto.UserResponse
-	err = json.Unmarshal([]byte(data), &profile)
+	err := c.getJSON(ctx, "user_profile", key, &profile)
 	return profile, err
 }
 
-func (c *cacheService) DeleteUserProfile(userID string) error {
+func (c *cacheService) DeleteUserProfile(ctx context.Context, userID string) error {
 	key := fmt.Sprintf("user:profile:%s", userID)
-	return c.client.Del(context.Background(), key).Err()
+	return c.store.Delete(ctx, key)
 }
 
-func (c *cacheService) SetJWTBlacklist(token string, ttl time.Duration) error {
+func (c *cacheService) SetJWTBlacklist(ctx context.Context, token string, ttl time.Duration) error {
 	key := fmt.Sprintf("jwt:blacklist:%s", token)
-	return c.client.Set(context.Background(), key, "1", ttl).Err()
+	return c.store.Set(ctx, key, "1", ttl)
 }
 
-func (c *cacheService) IsJWTBlacklisted(token string) bool {
+func (c *cacheService) IsJWTBlacklisted(ctx context.Context, token string) bool {
 	key := fmt.Sprintf("jwt:blacklist:%s", token)
-	_, err := c.client.Get(context.Background(), key).Result()
+	_, err := c.store.Get(ctx, key)
 	return err == nil
 }
 
-func (c *cacheService) Set(key string, value interface{}, ttl time.Duration) error {
-	return c.client.Set(context.Background(), key, value, ttl).Err()
+// GetActivityVersion returns the current cache version of a user's activities,
+// starting a new one when none exists. Versions are timestamps rather than a
+// counter so an evicted version can never be reissued and resurrect old pages.
+func (c *cacheService) GetActivityVersion(ctx context.Context, userID string) (int64, error) {
+	key := activityVersionPrefix + userID
+	data, err := c.store.Get(ctx, key)
+	if errors.Is(err, ErrCacheMiss) {
+		version := time.Now().UnixNano()
+		return version, c.store.Set(ctx, key, strconv.FormatInt(version, 10), 0)
+	} else if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(data, 10, 64)
+}
+
+// BumpActivityVersion moves the user to a new version so every cached list
+// page and summary becomes unreachable
+func (c *cacheService) BumpActivityVersion(ctx context.Context, userID string) error {
+	key := activityVersionPrefix + userID
+	return c.store.Set(ctx, key, strconv.FormatInt(time.Now().UnixNano(), 10), 0)
+}
+
+// DeleteActivityVersion drops the user's current version, the next read
+// starts a new one and every page cached under the old one is unreachable
+func (c *cacheService) DeleteActivityVersion(ctx context.Context, userID string) error {
+	return c.store.Delete(ctx, activityVersionPrefix+userID)
+}
+
+func (c *cacheService) SetActivityList(ctx context.Context, userID string, version int64, query string, activities []dto.ActivityResponse, ttl time.Duration) error {
+	key := fmt.Sprintf("activity:list:%s:%d:%s", userID, version, query)
+	return c.setJSON(ctx, key, activities, ttl)
+}
+
+func (c *cacheService) GetActivityList(ctx context.Context, userID string, version int64, query string) ([]dto.ActivityResponse, error) {
+	key := fmt.Sprintf("activity:list:%s:%d:%s", userID, version, query)
+	var activities []dto.ActivityResponse
+	err := c.getJSON(ctx, "activity_list", key, &activities)
+	return activities, err
+}
+
+func (c *cacheService) SetActivitySummary(ctx context.Context, userID string, version int64, query string, summary dto.ActivitySummaryResponse, ttl time.Duration) error {
+	key := fmt.Sprintf("activity:summary:%s:%d:%s", userID, version, query)
+	return c.setJSON(ctx, key, summary, ttl)
 }
 
-func (c *cacheService) Get(key string) (string, error) {
-	return c.client.Get(context.Background(), key).Result()
+func (c *cacheService) GetActivitySummary(ctx context.Context, userID string, version int64, query string) (dto.ActivitySummaryResponse, error) {
+	key := fmt.Sprintf("activity:summary:%s:%d:%s", userID, version, query)
+	var summary dto.ActivitySummaryResponse
+	err := c.getJSON(ctx, "activity_summary", key, &summary)
+	return summary, err
 }
 
-func (c *cacheService) Delete(key string) error {
-	return c.client.Del(context.Background(), key).Err()
+// ReserveIdempotencyKey stores record for the user's key unless one exists.
+// It reports whether it did, returning the existing record otherwise. A
+// record expiring or released between the two steps is reserved again.
+func (c *cacheService) ReserveIdempotencyKey(ctx context.Context, userID, key string, record dto.IdempotencyRecord, ttl time.Duration) (dto.IdempotencyRecord, bool, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return dto.IdempotencyRecord{}, false, err
+	}
+
+	cacheKey := idempotencyCacheKey(userID, key)
+	for attempt := 1; ; attempt++ {
+		reserved, err := c.store.SetNX(ctx, cacheKey, string(data), ttl)
+		if err != nil || reserved {
+			return record, reserved, err
+		}
+
+		var existing dto.IdempotencyRecord
+		err = c.getJSON(ctx, "idempotency", cacheKey, &existing)
+		if !errors.Is(err, ErrCacheMiss) || attempt == idempotencyReserveAttempts {
+			return existing, false, err
+		}
+	}
+}
+
+// idempotencyCacheKey scopes keys per user, two users may pick the same one
+func idempotencyCacheKey(userID, key string) string {
+	return fmt.Sprintf("%s%s:%s", idempotencyPrefix, userID, key)
+}
+
+func (c *cacheService) SetIdempotencyRecord(ctx context.Context, userID, key string, record dto.IdempotencyRecord, ttl time.Duration) error {
+	return c.setJSON(ctx, idempotencyCacheKey(userID, key), record, ttl)
+}
+
+func (c *cacheService) DeleteIdempotencyRecord(ctx context.Context, userID, key string) error {
+	return c.store.Delete(ctx, idempotencyCacheKey(userID, key))
+}
+
+// Stats returns a snapshot of the hit/miss counters
+func (c *cacheService) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make(CacheStats, len(c.counters))
+	for name, counter := range c.counters {
+		stats[name] = CacheCounter{Hits: counter.hits.Load(), Misses: counter.misses.Load()}
+	}
+	return stats
+}
+
+func (c *cacheService) setJSON(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return c.store.Set(ctx, key, string(data), ttl)
+}
+
+// getJSON loads key into dest and counts the lookup under name. Backend
+// errors count as misses since the caller ends up reading the database.
+func (c *cacheService) getJSON(ctx context.Context, name, key string, dest interface{}) error {
+	counter := c.counter(name)
+
+	data, err := c.store.Get(ctx, key)
+	if err == nil {
+		err = json.Unmarshal([]byte(data), dest)
+	}
+	if err != nil {
+		counter.misses.Add(1)
+		metrics.CacheRequests.WithLabelValues(name, "miss").Inc()
+		return err
+	}
+
+	counter.hits.Add(1)
+	metrics.CacheRequests.WithLabelValues(name, "hit").Inc()
+	return nil
+}
+
+func (c *cacheService) counter(name string) *cacheCounter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counter, ok := c.counters[name]
+	if !ok {
+		counter = &cacheCounter{}
+		c.counters[name] = counter
+	}
+	return counter
+}
+
+func (c *cacheService) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return c.store.Set(ctx, key, toCacheString(value), ttl)
+}
+
+func (c *cacheService) Get(ctx context.Context, key string) (string, error) {
+	return c.store.Get(ctx, key)
+}
+
+func (c *cacheService) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return c.store.Incr(ctx, key, ttl)
+}
+
+func (c *cacheService) Delete(ctx context.Context, key string) error {
+	return c.store.Delete(ctx, key)
+}
+
+func toCacheString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
 }
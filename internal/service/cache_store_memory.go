@@ -0,0 +1,154 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// memoryStore is an in-process LRU with per-key TTL. Expired entries are
+// dropped lazily on access or when they reach the back of the list.
+type memoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+func newMemoryStore(maxEntries int) *memoryStore {
+	return &memoryStore{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (m *memoryStore) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		m.ll.MoveToFront(el)
+		return nil
+	}
+
+	m.items[key] = m.ll.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+
+	for m.maxEntries > 0 && m.ll.Len() > m.maxEntries {
+		m.removeElement(m.ll.Back())
+	}
+
+	return nil
+}
+
+func (m *memoryStore) Get(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return "", ErrCacheMiss
+	}
+
+	entry := el.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		m.removeElement(el)
+		return "", ErrCacheMiss
+	}
+
+	m.ll.MoveToFront(el)
+	return entry.value, nil
+}
+
+func (m *memoryStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if el, ok := m.items[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		if !entry.expired(now) {
+			count, err := strconv.ParseInt(entry.value, 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			count++
+			entry.value = strconv.FormatInt(count, 10)
+			m.ll.MoveToFront(el)
+			return count, nil
+		}
+		m.removeElement(el)
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+	m.items[key] = m.ll.PushFront(&memoryEntry{key: key, value: "1", expiresAt: expiresAt})
+
+	for m.maxEntries > 0 && m.ll.Len() > m.maxEntries {
+		m.removeElement(m.ll.Back())
+	}
+
+	return 1, nil
+}
+
+func (m *memoryStore) SetNX(_ context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if el, ok := m.items[key]; ok {
+		if !el.Value.(*memoryEntry).expired(now) {
+			return false, nil
+		}
+		m.removeElement(el)
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+	m.items[key] = m.ll.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+
+	for m.maxEntries > 0 && m.ll.Len() > m.maxEntries {
+		m.removeElement(m.ll.Back())
+	}
+
+	return true, nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		m.removeElement(el)
+	}
+	return nil
+}
+
+func (m *memoryStore) removeElement(el *list.Element) {
+	m.ll.Remove(el)
+	delete(m.items, el.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// tieredStore keeps a short-lived local copy in front of a shared remote
+// store. Local entries are capped at localTTL so invalidations made by other
+// replicas become visible quickly. Keys matching remoteOnly prefixes are never
+// copied locally.
+type tieredStore struct {
+	local      cacheStore
+	remote     cacheStore
+	localTTL   time.Duration
+	remoteOnly []string
+}
+
+func newTieredStore(local, remote cacheStore, localTTL time.Duration, remoteOnly ...string) *tieredStore {
+	return &tieredStore{
+		local:      local,
+		remote:     remote,
+		localTTL:   localTTL,
+		remoteOnly: remoteOnly,
+	}
+}
+
+func (t *tieredStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if t.isRemoteOnly(key) {
+		return t.remote.Set(ctx, key, value, ttl)
+	}
+	t.local.Set(ctx, key, value, t.capTTL(ttl))
+	return t.remote.Set(ctx, key, value, ttl)
+}
+
+func (t *tieredStore) Get(ctx context.Context, key string) (string, error) {
+	if t.isRemoteOnly(key) {
+		return t.remote.Get(ctx, key)
+	}
+	if value, err := t.local.Get(ctx, key); err == nil {
+		return value, nil
+	}
+
+	value, err := t.remote.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	t.local.Set(ctx, key, value, t.localTTL)
+	return value, nil
+}
+
+// Incr always goes to the remote store, counters must be shared
+func (t *tieredStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return t.remote.Incr(ctx, key, ttl)
+}
+
+// SetNX always goes to the remote store, only it can tell whether another
+// replica set the key first
+func (t *tieredStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return t.remote.SetNX(ctx, key, value, ttl)
+}
+
+func (t *tieredStore) Delete(ctx context.Context, key string) error {
+	localErr := t.local.Delete(ctx, key)
+	return errors.Join(localErr, t.remote.Delete(ctx, key))
+}
+
+func (t *tieredStore) isRemoteOnly(key string) bool {
+	for _, prefix := range t.remoteOnly {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tieredStore) capTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > t.localTTL {
+		return t.localTTL
+	}
+	return ttl
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fikrialwan/FitByte/internal/entity"
+)
+
+// ErrorKind classifies domain errors so transports can map them without
+// inspecting messages
+type ErrorKind int
+
+const (
+	KindNotFound ErrorKind = iota + 1
+	KindConflict
+	KindValidation
+	KindForbidden
+	KindPreconditionFailed
+)
+
+// Error is a domain error with a stable machine readable code. Field names the
+// offending request field of validation errors.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Field   string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches errors of the same kind and code so sentinels compare equal to
+// errors built with a more specific message
+func (e *Error) Is(target error) bool {
+	var t *Error
+	return errors.As(target, &t) && t.Kind == e.Kind && t.Code == e.Code
+}
+
+func NewNotFoundError(code, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+func NewConflictError(code, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+func NewValidationError(code, field, message string) *Error {
+	return &Error{Kind: KindValidation, Code: code, Field: field, Message: message}
+}
+
+func NewForbiddenError(code, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+func NewPreconditionFailedError(code, message string) *Error {
+	return &Error{Kind: KindPreconditionFailed, Code: code, Message: message}
+}
+
+var (
+	// ErrInvalidCredentials covers unknown emails, wrong passwords and
+	// lockouts alike. It stays a 404 for compatibility with existing clients.
+	ErrInvalidCredentials  = NewNotFoundError("invalid_credentials", "Invalid email or password")
+	ErrUserNotFound        = NewNotFoundError("user_not_found", "User not found")
+	ErrEmailExists         = NewConflictError("email_exists", "Email already exists")
+	ErrActivityNotFound    = NewNotFoundError("activity_not_found", "Activity not found")
+	ErrInvalidActivityType = NewValidationError("invalid_activity_type", "activityType", "invalid activity type")
+	// ErrImageNotUploaded rejects profile images that aren't an upload of the
+	// user, the bucket is private and only their own files may be shown
+	ErrImageNotUploaded = NewValidationError("invalid_image_uri", "imageUri", "imageUri must be a URI returned by POST /v1/file")
+	// ErrVersionMismatch rejects updates made against a version other than
+	// the current one, ErrConcurrentUpdate ones that raced with another
+	// update without naming a version
+	ErrVersionMismatch  = NewPreconditionFailedError("version_mismatch", "The resource was modified since it was read")
+	ErrConcurrentUpdate = NewConflictError("concurrent_update", "The resource was modified by another request, please retry")
+)
+
+// versionConflict reports an update that lost the race against another one.
+// Clients that named a version get the same error as if they had been
+// late to begin with.
+func versionConflict(version int) error {
+	if version != 0 {
+		return ErrVersionMismatch
+	}
+	return ErrConcurrentUpdate
+}
+
+// invalidActivityType reports the rejected type along with the accepted ones
+func invalidActivityType(activityType entity.ActivityType) error {
+	validTypes := strings.Join(entity.GetValidActivityTypeStrings(), ", ")
+	err := *ErrInvalidActivityType
+	err.Message = fmt.Sprintf("invalid activity type '%s'. valid types: %s", activityType, validTypes)
+	return &err
+}
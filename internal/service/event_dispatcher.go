@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/fikrialwan/FitByte/internal/events"
+	"github.com/fikrialwan/FitByte/internal/metrics"
+	"github.com/fikrialwan/FitByte/internal/repository"
+)
+
+const (
+	// outboxBatch is how many events are claimed at once
+	outboxBatch          = 20
+	outboxHandlerTimeout = 30 * time.Second
+	// outboxLease is how long a claimed event is left to its dispatcher
+	// before another one may deliver it again. It covers a whole batch of
+	// events with one handler each, and the lease of every event is renewed
+	// for all its handlers when its delivery starts.
+	outboxLease = outboxBatch * outboxHandlerTimeout
+	// outboxLeaseMargin is added to renewed leases for recording the outcome
+	outboxLeaseMargin = 30 * time.Second
+	// outboxMaxBackoff caps the doubling of OUTBOX_RETRY_BACKOFF
+	outboxMaxBackoff = time.Hour
+	// outboxPurgeInterval is how often published events past their retention
+	// are deleted
+	outboxPurgeInterval = time.Hour
+	outboxPurgeBatch    = 500
+)
+
+type subscription struct {
+	name    string
+	handler events.Handler
+}
+
+// EventDispatcher delivers the events emitted through the OutboxService to
+// the handlers subscribed to their type. Delivery is at least once: an event
+// is retried, to every handler of its type, until they all succeed or
+// OUTBOX_MAX_ATTEMPTS is reached. Every replica may run a dispatcher, claims
+// keep them from delivering the same event at once.
+type EventDispatcher struct {
+	outboxRepository repository.OutboxRepository
+	clock            clock.Clock
+	interval         time.Duration
+	backoff          time.Duration
+	maxAttempts      int
+	retention        time.Duration
+
+	mu            sync.RWMutex
+	subscriptions map[events.Type][]subscription
+}
+
+func NewEventDispatcher(config *config.Config, outboxRepository repository.OutboxRepository, clk clock.Clock) *EventDispatcher {
+	return &EventDispatcher{
+		outboxRepository: outboxRepository,
+		clock:            clk,
+		interval:         config.GetOutboxPollInterval(),
+		backoff:          config.GetOutboxRetryBackoff(),
+		maxAttempts:      config.GetOutboxMaxAttempts(),
+		retention:        time.Duration(config.GetOutboxRetentionDays()) * 24 * time.Hour,
+		subscriptions:    make(map[events.Type][]subscription),
+	}
+}
+
+// Subscribe has handler called for every event of eventType, name identifies
+// it in logs. Events without a handler are published as soon as they're
+// claimed.
+func (d *EventDispatcher) Subscribe(eventType events.Type, name string, handler events.Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions[eventType] = append(d.subscriptions[eventType], subscription{name, handler})
+}
+
+// Dispatch delivers the events that are due, in batches, and returns how
+// many it attempted. A failed delivery is scheduled again rather than
+// reported, the error is about claiming or recording the outcome.
+func (d *EventDispatcher) Dispatch(ctx context.Context) (int, error) {
+	var total int
+	for {
+		now := d.clock.Now()
+		batch, err := d.outboxRepository.Claim(ctx, now, now.Add(outboxLease), outboxBatch)
+		if err != nil {
+			return total, err
+		}
+
+		for _, event := range batch {
+			if err := d.deliver(ctx, event); err != nil {
+				return total, err
+			}
+			total++
+		}
+		if len(batch) < outboxBatch {
+			return total, nil
+		}
+	}
+}
+
+// deliver hands event to its handlers and records the outcome. When that
+// can't be recorded the lease runs out and the event is delivered again. An
+// event whose lease was lost is left to the dispatcher that claimed it since.
+func (d *EventDispatcher) deliver(ctx context.Context, outboxEvent entity.OutboxEvent) error {
+	event := events.Event{
+		ID:          outboxEvent.ID,
+		Type:        events.Type(outboxEvent.Type),
+		AggregateID: outboxEvent.AggregateID,
+		UserID:      outboxEvent.UserID,
+		Payload:     outboxEvent.Payload,
+		RequestID:   outboxEvent.RequestID,
+		OccurredAt:  outboxEvent.CreatedAt,
+		Attempt:     outboxEvent.Attempts,
+	}
+
+	d.mu.RLock()
+	subscriptions := d.subscriptions[event.Type]
+	d.mu.RUnlock()
+
+	// Earlier events of the batch may have used up most of the lease
+	leaseUntil := d.clock.Now().Add(time.Duration(len(subscriptions))*outboxHandlerTimeout + outboxLeaseMargin)
+	if err := d.outboxRepository.Renew(ctx, event.ID, outboxEvent.ClaimToken, leaseUntil); err != nil {
+		return d.ignoreLeaseLost(ctx, outboxEvent, err)
+	}
+
+	var errs []error
+	for _, sub := range subscriptions {
+		if err := d.handle(ctx, sub, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+		}
+	}
+	// A stopping dispatcher leaves the event to the next one
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	now := d.clock.Now()
+	claimToken := outboxEvent.ClaimToken
+	err := errors.Join(errs...)
+	var recordErr error
+	switch {
+	case err == nil:
+		metrics.OutboxDeliveries.WithLabelValues(outboxEvent.Type, "published").Inc()
+		recordErr = d.outboxRepository.MarkPublished(ctx, event.ID, claimToken, now)
+	case event.Attempt >= d.maxAttempts:
+		metrics.OutboxDeliveries.WithLabelValues(outboxEvent.Type, "failed").Inc()
+		slog.ErrorContext(ctx, "giving up on event delivery", "event_id", event.ID, "event_type", event.Type, "attempts", event.Attempt, "error", err)
+		recordErr = d.outboxRepository.MarkFailed(ctx, event.ID, claimToken, now, err.Error())
+	default:
+		metrics.OutboxDeliveries.WithLabelValues(outboxEvent.Type, "retried").Inc()
+		retryAt := now.Add(d.retryBackoff(event.Attempt))
+		slog.WarnContext(ctx, "event delivery failed", "event_id", event.ID, "event_type", event.Type, "attempt", event.Attempt, "retry_at", retryAt, "error", err)
+		recordErr = d.outboxRepository.Retry(ctx, event.ID, claimToken, retryAt, err.Error())
+	}
+	return d.ignoreLeaseLost(ctx, outboxEvent, recordErr)
+}
+
+// ignoreLeaseLost logs and swallows ErrOutboxLeaseLost, other errors are returned
+func (d *EventDispatcher) ignoreLeaseLost(ctx context.Context, event entity.OutboxEvent, err error) error {
+	if !errors.Is(err, repository.ErrOutboxLeaseLost) {
+		return err
+	}
+	slog.WarnContext(ctx, "event lease lost", "event_id", event.ID, "event_type", event.Type, "attempt", event.Attempts)
+	return nil
+}
+
+// handle runs one handler, turning a panic into an error so it is retried
+// like any other failure
+func (d *EventDispatcher) handle(ctx context.Context, sub subscription, event events.Event) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, outboxHandlerTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return sub.handler(ctx, event)
+}
+
+// retryBackoff is the wait after the given failed attempt, doubling from
+// OUTBOX_RETRY_BACKOFF
+func (d *EventDispatcher) retryBackoff(attempt int) time.Duration {
+	backoff := d.backoff
+	for i := 1; i < attempt && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, outboxMaxBackoff)
+}
+
+// Purge deletes every event published before the retention period, in
+// batches, and returns how many it deleted. Failed events are kept.
+func (d *EventDispatcher) Purge(ctx context.Context) (int64, error) {
+	publishedBefore := d.clock.Now().Add(-d.retention)
+
+	var total int64
+	for {
+		purged, err := d.outboxRepository.PurgePublished(ctx, publishedBefore, outboxPurgeBatch)
+		total += purged
+		if err != nil {
+			return total, err
+		}
+		if purged < outboxPurgeBatch {
+			return total, nil
+		}
+	}
+}
+
+// Run dispatches every OUTBOX_POLL_INTERVAL and purges published events
+// every hour until ctx is done. Failures are logged and retried on the next
+// tick.
+func (d *EventDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	purgeTicker := time.NewTicker(outboxPurgeInterval)
+	defer purgeTicker.Stop()
+
+	for {
+		if _, err := d.Dispatch(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to dispatch events", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-purgeTicker.C:
+			purged, err := d.Purge(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "failed to purge published events", "error", err, "purged", purged)
+			} else if purged > 0 {
+				slog.InfoContext(ctx, "purged published events", "purged", purged)
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/fikrialwan/FitByte/internal/events"
+	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/google/uuid"
+)
+
+func dispatch(t *testing.T, d *service.EventDispatcher) int {
+	t.Helper()
+
+	n, err := d.Dispatch(context.Background())
+	if err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	return n
+}
+
+func TestEventDispatcherActivityEvents(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC))
+	repos := repository.NewMemoryRepositories(clk)
+	s := newActivityServiceWith(repos)
+	d := service.NewEventDispatcher(&config.Config{}, repos.Outbox, clk)
+
+	var received []events.Event
+	record := func(ctx context.Context, event events.Event) error {
+		received = append(received, event)
+		return nil
+	}
+	for _, eventType := range []events.Type{events.ActivityCreated, events.ActivityUpdated, events.ActivityDeleted, events.ActivityRestored} {
+		d.Subscribe(eventType, "recorder", record)
+	}
+
+	userID := uuid.NewString()
+	created := createActivity(t, s, userID, entity.Walking, 30)
+	activityID := created.ID.String()
+	duration := 60
+	if _, err := s.UpdateActivity(context.Background(), activityID, userID, 0, dto.ActivityUpdateRequest{DurationInMinutes: &duration}); err != nil {
+		t.Fatalf("UpdateActivity: %v", err)
+	}
+	if err := s.DeleteActivity(context.Background(), activityID, userID); err != nil {
+		t.Fatalf("DeleteActivity: %v", err)
+	}
+	if _, err := s.RestoreActivity(context.Background(), activityID, userID); err != nil {
+		t.Fatalf("RestoreActivity: %v", err)
+	}
+
+	if n := dispatch(t, d); n != 4 {
+		t.Fatalf("dispatched %d events, want 4", n)
+	}
+	var types []events.Type
+	for _, event := range received {
+		types = append(types, event.Type)
+		if event.AggregateID != created.ID || event.UserID.String() != userID || event.Attempt != 1 {
+			t.Errorf("%s event = %+v, want about the activity on the first attempt", event.Type, event)
+		}
+	}
+	if want := []events.Type{events.ActivityCreated, events.ActivityUpdated, events.ActivityDeleted, events.ActivityRestored}; !slices.Equal(types, want) {
+		t.Fatalf("types = %v, want %v", types, want)
+	}
+
+	var update events.ActivityUpdate
+	if err := received[1].Decode(&update); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if update.Previous.DurationInMinutes != 30 || update.Current.DurationInMinutes != 60 || update.Current.Version != 2 {
+		t.Errorf("update = %+v, want from 30 to 60 minutes at version 2", update)
+	}
+
+	// Published events aren't delivered again
+	if n := dispatch(t, d); n != 0 {
+		t.Errorf("dispatched %d events again, want 0", n)
+	}
+}
+
+func TestEventDispatcherRetries(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC))
+	repos := repository.NewMemoryRepositories(clk)
+	s := newActivityServiceWith(repos)
+	d := service.NewEventDispatcher(&config.Config{OutboxRetryBackoff: time.Second, OutboxMaxAttempts: 3}, repos.Outbox, clk)
+
+	var attempts []int
+	var delivered int
+	d.Subscribe(events.ActivityCreated, "flaky", func(ctx context.Context, event events.Event) error {
+		attempts = append(attempts, event.Attempt)
+		if event.Attempt < 3 {
+			return errors.New("downstream unavailable")
+		}
+		return nil
+	})
+	// Every handler of the type gets the retried event
+	d.Subscribe(events.ActivityCreated, "steady", func(ctx context.Context, event events.Event) error {
+		delivered++
+		return nil
+	})
+	createActivity(t, s, uuid.NewString(), entity.Running, 30)
+
+	dispatch(t, d)
+	// The backoff doubles, the second retry isn't due after a second
+	clk.Advance(time.Second)
+	dispatch(t, d)
+	clk.Advance(time.Second)
+	if n := dispatch(t, d); n != 0 {
+		t.Errorf("dispatched %d events before the backoff elapsed, want 0", n)
+	}
+	clk.Advance(time.Second)
+	dispatch(t, d)
+
+	if !slices.Equal(attempts, []int{1, 2, 3}) {
+		t.Errorf("attempts = %v, want [1 2 3]", attempts)
+	}
+	if delivered != 3 {
+		t.Errorf("steady handler got %d deliveries, want 3", delivered)
+	}
+	clk.Advance(time.Hour)
+	if n := dispatch(t, d); n != 0 {
+		t.Errorf("dispatched %d events after success, want 0", n)
+	}
+}
+
+func TestEventDispatcherGivesUp(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC))
+	repos := repository.NewMemoryRepositories(clk)
+	s := newActivityServiceWith(repos)
+	d := service.NewEventDispatcher(&config.Config{OutboxRetryBackoff: time.Second, OutboxMaxAttempts: 2}, repos.Outbox, clk)
+
+	var attempts int
+	d.Subscribe(events.ActivityCreated, "broken", func(ctx context.Context, event events.Event) error {
+		attempts++
+		panic("handler bug")
+	})
+	createActivity(t, s, uuid.NewString(), entity.Running, 30)
+
+	for range 5 {
+		dispatch(t, d)
+		clk.Advance(time.Minute)
+	}
+	if attempts != 2 {
+		t.Errorf("got %d attempts, want 2", attempts)
+	}
+}
+
+func TestEventDispatcherLeaseLost(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC))
+	repos := repository.NewMemoryRepositories(clk)
+	s := newActivityServiceWith(repos)
+	stalled := service.NewEventDispatcher(&config.Config{OutboxMaxAttempts: 1}, repos.Outbox, clk)
+	other := service.NewEventDispatcher(&config.Config{}, repos.Outbox, clk)
+
+	var attempts []int
+	other.Subscribe(events.ActivityCreated, "recorder", func(ctx context.Context, event events.Event) error {
+		attempts = append(attempts, event.Attempt)
+		return nil
+	})
+	// The handler outlives the lease, meanwhile another replica delivers the
+	// event again
+	stalled.Subscribe(events.ActivityCreated, "stalled", func(ctx context.Context, event events.Event) error {
+		attempts = append(attempts, event.Attempt)
+		clk.Advance(time.Hour)
+		dispatch(t, other)
+		return errors.New("timed out")
+	})
+	createActivity(t, s, uuid.NewString(), entity.Running, 30)
+
+	// Giving up after the only attempt would fail the event the other
+	// replica published, the outcome of a lost lease isn't recorded
+	dispatch(t, stalled)
+	if !slices.Equal(attempts, []int{1, 2}) {
+		t.Errorf("attempts = %v, want [1 2]", attempts)
+	}
+	clk.Advance(time.Hour)
+	if n := dispatch(t, other); n != 0 {
+		t.Errorf("dispatched %d events after publishing, want 0", n)
+	}
+}
+
+func TestEventDispatcherRollback(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC))
+	repos := repository.NewMemoryRepositories(clk)
+	d := service.NewEventDispatcher(&config.Config{}, repos.Outbox, clk)
+
+	// No event is emitted for a change that isn't committed
+	failing := repos
+	failing.Audit = failingAuditRepository{repos.Audit}
+	if _, err := newActivityServiceWith(failing).CreateActivity(context.Background(), dto.ActivityRequest{
+		ActivityType:      entity.Running,
+		DoneAt:            time.Date(2025, 1, 15, 7, 30, 0, 0, time.UTC),
+		DurationInMinutes: 10,
+	}, uuid.NewString()); err == nil {
+		t.Fatal("CreateActivity succeeded without an audit entry")
+	}
+	if n := dispatch(t, d); n != 0 {
+		t.Errorf("dispatched %d events, want 0", n)
+	}
+}
+
+func TestEventDispatcherPurge(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC))
+	repos := repository.NewMemoryRepositories(clk)
+	s := newActivityServiceWith(repos)
+	d := service.NewEventDispatcher(&config.Config{OutboxRetentionDays: 7}, repos.Outbox, clk)
+
+	userID := uuid.NewString()
+	createActivity(t, s, userID, entity.Running, 30)
+	dispatch(t, d)
+	clk.Advance(24 * time.Hour)
+	createActivity(t, s, userID, entity.Running, 30)
+	dispatch(t, d)
+	// Still pending, never purged
+	createActivity(t, s, userID, entity.Running, 30)
+
+	clk.Advance(6*24*time.Hour + time.Hour)
+	purged, err := d.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("purged %d events, want the one published over 7 days ago", purged)
+	}
+	if n := dispatch(t, d); n != 1 {
+		t.Errorf("dispatched %d events after purging, want the pending one", n)
+	}
+}
@@ -2,110 +2,201 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
 	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/metrics"
+	"github.com/fikrialwan/FitByte/internal/tracing"
 	"github.com/google/uuid"
 )
 
 type FileService interface {
-	UploadToS3(file io.Reader, filename, contentType string) (string, error)
+	// UploadToS3 stores an upload of userID under a key only their uploads
+	// get, see UploadedBy
+	UploadToS3(ctx context.Context, userID string, file io.Reader, filename, contentType string) (string, error)
+	PresignGetURL(ctx context.Context, key string) (string, error)
+	// ObjectKey extracts the object key from a URL of this bucket on the
+	// storage host, presigned or not. Any other URL fails with
+	// ErrImageNotUploaded.
+	ObjectKey(uri string) (string, error)
 	CheckConnectivity(ctx context.Context) error
 }
 
 type fileService struct {
-	s3Client   *s3.Client
-	bucketName string
-	config     *config.Config
+	s3Client      *s3.Client
+	presignClient *s3.PresignClient
+	bucketName    string
+	presignExpiry time.Duration
+	timeout       time.Duration
+	config        *config.Config
+	// hosts are the storage hosts object URLs may point at
+	hosts []string
 }
 
-func NewFileService(config *config.Config) FileService {
+// NewFileService returns a FileService on the MinIO bucket of config. Nothing
+// is sent to MinIO until the first call.
+func NewFileService(config *config.Config) (FileService, error) {
 	// Get MinIO configuration from environment
 	minioEndpoint := config.MinIOEndpoint
 	accessKey := config.MinIOAccessKey
 	secretKey := config.MinIOSecretKey
 	bucketName := config.MinIOBucket
-	useSSL := config.MinIOUseSSL == "true"
+	useSSL := config.MinIOUseSSL
 
 	// Validate required environment variables
 	if minioEndpoint == "" {
-		panic("MINIO_ENDPOINT environment variable is required")
+		return nil, errors.New("MINIO_ENDPOINT is required")
 	}
 	if accessKey == "" {
-		panic("MINIO_ACCESS_KEY environment variable is required")
+		return nil, errors.New("MINIO_ACCESS_KEY is required")
 	}
 	if secretKey == "" {
-		panic("MINIO_SECRET_KEY environment variable is required")
+		return nil, errors.New("MINIO_SECRET_KEY is required")
 	}
 	if bucketName == "" {
-		panic("MINIO_BUCKET environment variable is required")
+		return nil, errors.New("MINIO_BUCKET is required")
 	}
 
 	// Configure AWS SDK to work with MinIO
 	cfg, err := awsConfig.LoadDefaultConfig(context.TODO(),
 		awsConfig.WithRegion("us-east-1"), // MinIO doesn't care about region, but AWS SDK requires it
 		awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
+		awsConfig.WithAPIOptions([]func(*middleware.Stack) error{tracing.AWSMiddleware}),
 	)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to load MinIO config: %v", err))
+		return nil, fmt.Errorf("loading MinIO config: %w", err)
 	}
 
 	// Create S3 client configured for MinIO
 	s3Client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(fmt.Sprintf("http%s://%s", map[bool]string{true: "s", false: ""}[useSSL], minioEndpoint))
+		o.BaseEndpoint = aws.String(endpointURL(minioEndpoint, useSSL))
 		o.UsePathStyle = true // MinIO uses path-style URLs
 	})
 
+	// Presigned URLs are signed for the host clients will use, which may differ
+	// from the in-cluster endpoint the API talks to
+	presignClient := s3.NewPresignClient(s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpointURL(config.GetMinIOPublicEndpoint(), useSSL))
+		o.UsePathStyle = true
+	}))
+
 	return &fileService{
-		s3Client:   s3Client,
-		bucketName: bucketName,
-		config:     config,
-	}
+		s3Client:      s3Client,
+		presignClient: presignClient,
+		bucketName:    bucketName,
+		presignExpiry: config.GetMinIOPresignExpiry(),
+		timeout:       config.GetStorageTimeout(),
+		config:        config,
+		hosts:         []string{strings.ToLower(config.GetMinIOPublicEndpoint()), strings.ToLower(minioEndpoint)},
+	}, nil
 }
 
-func (s *fileService) UploadToS3(file io.Reader, filename, contentType string) (string, error) {
-	// Generate unique filename
-	ext := filepath.Ext(filename)
-	uniqueFilename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
+func endpointURL(endpoint string, useSSL bool) string {
+	if useSSL {
+		return "https://" + endpoint
+	}
+	return "http://" + endpoint
+}
 
-	// Create S3 key with timestamp folder structure
-	now := time.Now()
-	key := fmt.Sprintf("uploads/%d/%02d/%02d/%s", now.Year(), now.Month(), now.Day(), uniqueFilename)
+// UploadToS3 stores the file in the private bucket and returns its object key
+func (s *fileService) UploadToS3(ctx context.Context, userID string, file io.Reader, filename, contentType string) (string, error) {
+	key := newObjectKey(userID, filename)
 
 	// Upload to S3
-	_, err := s.s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err := s.s3Client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(s.bucketName),
 		Key:         aws.String(key),
 		Body:        file,
 		ContentType: aws.String(contentType),
 	})
+	metrics.ObserveStorage("upload", start, err)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload to S3: %w", err)
 	}
 
-	// Generate MinIO URL (for MinIO, we can return the direct URL)
-	minioEndpoint := s.config.MinIOEndpoint
-	useSSL := s.config.MinIOUseSSL == "true"
-	protocol := "http"
-	if useSSL {
-		protocol = "https"
+	return key, nil
+}
+
+// newObjectKey returns a unique key for an upload of userID, keeping the
+// file's extension and grouping uploads per user and day
+func newObjectKey(userID, filename string) string {
+	ext := filepath.Ext(filename)
+	uniqueFilename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
+
+	now := time.Now()
+	return fmt.Sprintf("%s%d/%02d/%02d/%s", uploadPrefix(userID), now.Year(), now.Month(), now.Day(), uniqueFilename)
+}
+
+func uploadPrefix(userID string) string {
+	return "uploads/" + userID + "/"
+}
+
+// UploadedBy reports whether key was handed out for an upload of userID.
+// Keys of uploads made before they were grouped per user are not.
+func UploadedBy(key, userID string) bool {
+	return userID != "" && strings.HasPrefix(key, uploadPrefix(userID))
+}
+
+// PresignGetURL returns a short-lived GET URL for an object key
+func (s *fileService) PresignGetURL(ctx context.Context, key string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+
+	start := time.Now()
+	req, err := s.presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(s.presignExpiry))
+	metrics.ObserveStorage("presign", start, err)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign object URL: %w", err)
 	}
 
-	// Return direct MinIO URL
-	fileURL := fmt.Sprintf("%s://%s/%s/%s", protocol, minioEndpoint, s.bucketName, key)
-	return fileURL, nil
+	return req.URL, nil
+}
+
+func (s *fileService) ObjectKey(uri string) (string, error) {
+	return objectKey(uri, s.bucketName, s.hosts)
+}
+
+// objectKey extracts the key from a path-style URL of bucketName on one of
+// hosts
+func objectKey(uri, bucketName string, hosts []string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !slices.Contains(hosts, strings.ToLower(u.Host)) {
+		return "", ErrImageNotUploaded
+	}
+
+	key, ok := strings.CutPrefix(u.Path, "/"+bucketName+"/")
+	if !ok || key == "" || slices.Contains(strings.Split(key, "/"), "..") {
+		return "", ErrImageNotUploaded
+	}
+	return key, nil
 }
 
 // CheckConnectivity tests MinIO connectivity by listing buckets
 func (s *fileService) CheckConnectivity(ctx context.Context) error {
 	// Perform a lightweight operation to test connectivity
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
 	_, err := s.s3Client.ListBuckets(ctx, &s3.ListBucketsInput{})
 	if err != nil {
 		return fmt.Errorf("MinIO connectivity check failed: %w", err)
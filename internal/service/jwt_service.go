@@ -2,10 +2,11 @@ package service
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/clock"
 	"github.com/golang-jwt/jwt/v4"
 )
 
@@ -18,39 +19,39 @@ type JwtService struct {
 	secretKey    string
 	issuer       string
 	accessExpiry time.Duration
+	clock        clock.Clock
+	parser       *jwt.Parser
 }
 
-func NewJwtService(config *config.Config) JwtService {
+// NewJwtService issues and checks tokens against clk, so expiry can be tested
+// without waiting
+func NewJwtService(config *config.Config, clk clock.Clock) JwtService {
 	return JwtService{
-		secretKey:    getSecretKey(config),
+		secretKey:    config.JWTSecret,
 		issuer:       "fitbyte-api",
 		accessExpiry: 24 * time.Hour, // 24 hours
+		clock:        clk,
+		// jwt.TimeFunc is global, the time based claims are checked in
+		// ValidateToken instead
+		parser: jwt.NewParser(jwt.WithoutClaimsValidation()),
 	}
 }
 
-func getSecretKey(config *config.Config) string {
-	secretKey := config.JWTSecret
-	if secretKey == "" {
-		secretKey = "template"
-	}
-
-	return secretKey
-}
-
 func (j JwtService) GenerateAccessToken(userId string) string {
+	now := j.clock.Now()
 	claims := jwtCustomClaim{
 		UserID: userId,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.accessExpiry)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.accessExpiry)),
 			Issuer:    j.issuer,
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tx, err := token.SignedString([]byte(j.secretKey))
 	if err != nil {
-		log.Println(err)
+		slog.Error("failed to sign access token", "error", err)
 	}
 	return tx
 }
@@ -63,7 +64,22 @@ func (j JwtService) parseToken(t_ *jwt.Token) (any, error) {
 }
 
 func (j JwtService) ValidateToken(token string) (*jwt.Token, error) {
-	return jwt.Parse(token, j.parseToken)
+	tToken, err := j.parser.Parse(token, j.parseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := tToken.Claims.(jwt.MapClaims)
+	now := j.clock.Now().Unix()
+	switch {
+	case !claims.VerifyExpiresAt(now, false):
+		return nil, jwt.NewValidationError("token is expired", jwt.ValidationErrorExpired)
+	case !claims.VerifyIssuedAt(now, false):
+		return nil, jwt.NewValidationError("token used before issued", jwt.ValidationErrorIssuedAt)
+	case !claims.VerifyNotBefore(now, false):
+		return nil, jwt.NewValidationError("token is not valid yet", jwt.ValidationErrorNotValidYet)
+	}
+	return tToken, nil
 }
 
 func (j JwtService) GetUserIDByToken(token string) (string, error) {
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/fikrialwan/FitByte/config"
+)
+
+const loginAttemptPrefix = "login:"
+
+// LoginAttemptService tracks failed logins per email and per client IP. Once
+// an identity reaches its threshold it is locked out, and every further
+// failure doubles the lockout up to a maximum. Counters reset after the
+// attempt window passes without failures.
+type LoginAttemptService struct {
+	cacheService   CacheService
+	maxPerEmail    int
+	maxPerIP       int
+	window         time.Duration
+	lockoutBase    time.Duration
+	lockoutMaximum time.Duration
+}
+
+func NewLoginAttemptService(config *config.Config, cacheService CacheService) LoginAttemptService {
+	return LoginAttemptService{
+		cacheService:   cacheService,
+		maxPerEmail:    config.GetLoginMaxAttemptsPerEmail(),
+		maxPerIP:       config.GetLoginMaxAttemptsPerIP(),
+		window:         config.GetLoginAttemptWindow(),
+		lockoutBase:    config.GetLoginLockoutBase(),
+		lockoutMaximum: config.GetLoginLockoutMax(),
+	}
+}
+
+// IsLocked reports whether either the email or the IP is locked out. When the
+// cache is unavailable logins are allowed so an outage doesn't lock everyone
+// out.
+func (s LoginAttemptService) IsLocked(ctx context.Context, email, ip string) bool {
+	for _, key := range []string{lockKey("email", normalizeEmail(email)), lockKey("ip", ip)} {
+		_, err := s.cacheService.Get(ctx, key)
+		if err == nil {
+			return true
+		}
+		if !errors.Is(err, ErrCacheMiss) {
+			slog.WarnContext(ctx, "failed to read login lockout, allowing attempt", "error", err)
+		}
+	}
+	return false
+}
+
+// RecordFailure counts a failed attempt and locks the email or IP when it
+// crosses its threshold. It isn't cancelled with the request, otherwise
+// disconnecting right after a guess would skip the count.
+func (s LoginAttemptService) RecordFailure(ctx context.Context, email, ip string) {
+	ctx = context.WithoutCancel(ctx)
+	s.recordFailure(ctx, "email", normalizeEmail(email), s.maxPerEmail)
+	s.recordFailure(ctx, "ip", ip, s.maxPerIP)
+}
+
+// RecordSuccess clears the email's failures. The IP counter is left alone so
+// one valid account can't be used to reset attempts against others.
+func (s LoginAttemptService) RecordSuccess(ctx context.Context, email string) {
+	email = normalizeEmail(email)
+	s.cacheService.Delete(ctx, failKey("email", email))
+	s.cacheService.Delete(ctx, lockKey("email", email))
+}
+
+// Unlock removes failures and lockouts for the given email and/or IP
+func (s LoginAttemptService) Unlock(ctx context.Context, email, ip string) error {
+	var errs []error
+	if email != "" {
+		email = normalizeEmail(email)
+		errs = append(errs, s.cacheService.Delete(ctx, failKey("email", email)), s.cacheService.Delete(ctx, lockKey("email", email)))
+	}
+	if ip != "" {
+		errs = append(errs, s.cacheService.Delete(ctx, failKey("ip", ip)), s.cacheService.Delete(ctx, lockKey("ip", ip)))
+	}
+	return errors.Join(errs...)
+}
+
+func (s LoginAttemptService) recordFailure(ctx context.Context, kind, identity string, threshold int) {
+	failures, err := s.cacheService.Incr(ctx, failKey(kind, identity), s.window)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to record failed login", "kind", kind, "error", err)
+		return
+	}
+
+	if failures < int64(threshold) {
+		return
+	}
+
+	lockout := s.lockoutDuration(failures - int64(threshold))
+	if err := s.cacheService.Set(ctx, lockKey(kind, identity), failures, lockout); err != nil {
+		slog.WarnContext(ctx, "failed to lock out login", "kind", kind, "failures", failures, "error", err)
+		return
+	}
+	slog.WarnContext(ctx, "login locked", "kind", kind, "failures", failures, "lockout", lockout.String())
+}
+
+// lockoutDuration doubles the base lockout for every failure past the threshold
+func (s LoginAttemptService) lockoutDuration(excess int64) time.Duration {
+	lockout := s.lockoutBase
+	for i := int64(0); i < excess && lockout < s.lockoutMaximum; i++ {
+		lockout *= 2
+	}
+	if lockout > s.lockoutMaximum {
+		return s.lockoutMaximum
+	}
+	return lockout
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func failKey(kind, identity string) string {
+	return loginAttemptPrefix + "fail:" + kind + ":" + identity
+}
+
+func lockKey(kind, identity string) string {
+	return loginAttemptPrefix + "lock:" + kind + ":" + identity
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/fikrialwan/FitByte/internal/events"
+	"github.com/fikrialwan/FitByte/internal/logging"
+	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/google/uuid"
+)
+
+// OutboxService emits domain events through the outbox, see EventDispatcher
+// for their delivery
+type OutboxService struct {
+	outboxRepository repository.OutboxRepository
+}
+
+func NewOutboxService(outboxRepository repository.OutboxRepository) OutboxService {
+	return OutboxService{outboxRepository}
+}
+
+// Emit stores an event about aggregateID, owned by userID, with payload
+// marshalled as JSON. Call it within the transaction making the change, so
+// the event is only delivered if the change is committed.
+func (s OutboxService) Emit(ctx context.Context, eventType events.Type, aggregateID, userID uuid.UUID, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return s.outboxRepository.Append(ctx, &entity.OutboxEvent{
+		Type:        string(eventType),
+		AggregateID: aggregateID,
+		UserID:      userID,
+		Payload:     data,
+		RequestID:   logging.RequestID(ctx),
+	})
+}
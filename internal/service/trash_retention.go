@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/metrics"
+	"github.com/fikrialwan/FitByte/internal/repository"
+)
+
+// trashPurgeBatch bounds each delete so a large backlog doesn't hold locks
+// for the length of one query timeout
+const trashPurgeBatch = 500
+
+// TrashRetention permanently deletes activities that have been in the trash
+// for longer than TRASH_RETENTION_DAYS. Every replica may run it, purging is
+// idempotent.
+type TrashRetention struct {
+	activityRepository repository.ActivityRepository
+	clock              clock.Clock
+	retention          time.Duration
+	interval           time.Duration
+}
+
+func NewTrashRetention(config *config.Config, activityRepository repository.ActivityRepository, clk clock.Clock) TrashRetention {
+	return TrashRetention{
+		activityRepository: activityRepository,
+		clock:              clk,
+		retention:          time.Duration(config.GetTrashRetentionDays()) * 24 * time.Hour,
+		interval:           config.GetTrashPurgeInterval(),
+	}
+}
+
+// Purge deletes every activity trashed before the retention period, in
+// batches, and returns how many it deleted
+func (t TrashRetention) Purge(ctx context.Context) (int64, error) {
+	deletedBefore := t.clock.Now().Add(-t.retention)
+
+	var total int64
+	for {
+		purged, err := t.activityRepository.PurgeTrash(ctx, deletedBefore, trashPurgeBatch)
+		total += purged
+		metrics.ActivitiesPurged.Add(float64(purged))
+		if err != nil {
+			return total, err
+		}
+		if purged < trashPurgeBatch {
+			return total, nil
+		}
+	}
+}
+
+// Run purges right away and then every TRASH_PURGE_INTERVAL until ctx is
+// done. Failures are logged and retried on the next tick.
+func (t TrashRetention) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := t.Purge(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to purge trashed activities", "error", err, "purged", purged)
+		} else if purged > 0 {
+			slog.InfoContext(ctx, "purged trashed activities", "purged", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
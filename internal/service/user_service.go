@@ -1,40 +1,87 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/fikrialwan/FitByte/internal/dto"
 	"github.com/fikrialwan/FitByte/internal/entity"
+	"github.com/fikrialwan/FitByte/internal/events"
+	"github.com/fikrialwan/FitByte/internal/metrics"
 	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/fikrialwan/FitByte/internal/tracing"
 	"github.com/fikrialwan/FitByte/pkg/helpers"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type UserService struct {
-	userRepository repository.UserRepository
-	jwtService     JwtService
-	cacheService   CacheService
+	userRepository      repository.UserRepository
+	jwtService          JwtService
+	cacheService        CacheService
+	fileService         FileService
+	loginAttemptService LoginAttemptService
+	transactor          repository.Transactor
+	auditService        AuditService
+	outboxService       OutboxService
+	passwordHasher      helpers.PasswordHasher
+	// dummyPasswordHash is compared against when the email doesn't exist so
+	// unknown accounts take as long to reject as wrong passwords
+	dummyPasswordHash func() string
 }
 
-func NewUserService(userRepository repository.UserRepository, jwtService JwtService, cacheService CacheService) UserService {
+func NewUserService(userRepository repository.UserRepository, jwtService JwtService, cacheService CacheService, fileService FileService, loginAttemptService LoginAttemptService, transactor repository.Transactor, auditService AuditService, outboxService OutboxService, passwordHasher helpers.PasswordHasher) UserService {
 	return UserService{
-		userRepository: userRepository,
-		jwtService:     jwtService,
-		cacheService:   cacheService,
+		userRepository:      userRepository,
+		jwtService:          jwtService,
+		cacheService:        cacheService,
+		fileService:         fileService,
+		loginAttemptService: loginAttemptService,
+		transactor:          transactor,
+		auditService:        auditService,
+		outboxService:       outboxService,
+		passwordHasher:      passwordHasher,
+		dummyPasswordHash: sync.OnceValue(func() string {
+			hash, _ := passwordHasher.Hash("fitbyte-dummy-password")
+			return hash
+		}),
 	}
 }
 
-func (s UserService) Verify(email, password string) (dto.LoginRegisterResponse, error) {
-	user, err := s.userRepository.GetByEmail(email)
-	if err != nil {
+// Verify checks the credentials. Unknown emails and locked out emails or IPs
+// get the same ErrInvalidCredentials as a wrong password so neither can be
+// used to probe which accounts exist.
+func (s UserService) Verify(ctx context.Context, email, password, clientIP string) (dto.LoginRegisterResponse, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Verify")
+	defer span.End()
+
+	if s.loginAttemptService.IsLocked(ctx, email, clientIP) {
+		// Hash anyway so a locked account answers as slowly as any other
+		s.passwordHasher.Verify(s.dummyPasswordHash(), []byte(password))
+		return dto.LoginRegisterResponse{}, ErrInvalidCredentials
+	}
+
+	user, err := s.userRepository.GetByEmail(ctx, email)
+	if errors.Is(err, dto.ErrUserNotFound) {
+		s.passwordHasher.Verify(s.dummyPasswordHash(), []byte(password))
+		s.loginAttemptService.RecordFailure(ctx, email, clientIP)
+		return dto.LoginRegisterResponse{}, ErrInvalidCredentials
+	} else if err != nil {
 		return dto.LoginRegisterResponse{}, err
 	}
 
-	validPass, err := helpers.CheckPassword(user.Password, []byte(password))
+	validPass, err := s.passwordHasher.Verify(user.Password, []byte(password))
 	if err != nil || !validPass {
-		return dto.LoginRegisterResponse{}, dto.ErrUserNotFound
+		s.loginAttemptService.RecordFailure(ctx, email, clientIP)
+		return dto.LoginRegisterResponse{}, ErrInvalidCredentials
 	}
 
+	s.loginAttemptService.RecordSuccess(ctx, email)
+	s.upgradePasswordHash(ctx, user, password)
+
 	token := s.jwtService.GenerateAccessToken(user.ID.String())
 
 	return dto.LoginRegisterResponse{
@@ -43,10 +90,20 @@ func (s UserService) Verify(email, password string) (dto.LoginRegisterResponse,
 	}, nil
 }
 
-func (s UserService) Register(email, password string) (dto.LoginRegisterResponse, error) {
-	existingUser, _ := s.userRepository.GetByEmail(email)
+func (s UserService) Register(ctx context.Context, email, password string) (dto.LoginRegisterResponse, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Register")
+	defer span.End()
+
+	existingUser, _ := s.userRepository.GetByEmail(ctx, email)
 	if existingUser.ID != uuid.Nil {
-		return dto.LoginRegisterResponse{}, dto.ErrUserEmailExist
+		return dto.LoginRegisterResponse{}, ErrEmailExists
+	}
+
+	// Passwords are hashed here, never by the repository, which can't tell
+	// a plain password shaped like a hash from a real one
+	hash, err := s.passwordHasher.Hash(password)
+	if err != nil {
+		return dto.LoginRegisterResponse{}, err
 	}
 
 	// Generate UUID upfront for immediate use in JWT token
@@ -54,16 +111,20 @@ func (s UserService) Register(email, password string) (dto.LoginRegisterResponse
 
 	// Create new entity.User struct instead of modifying fetched one
 	newUser := entity.User{
-		ID:        userID,
-		Email:     email,
-		Password:  password,
-		Timestamp: entity.Timestamp{CreatedAt: time.Now()},
+		ID:       userID,
+		Email:    email,
+		Password: hash,
 	}
 
-	err := s.userRepository.CreateUser(&newUser)
-	if err != nil {
+	// Two concurrent registrations can both pass the check above, the unique
+	// index rejects the second
+	err = s.userRepository.CreateUser(ctx, &newUser)
+	if errors.Is(err, dto.ErrUserEmailExist) {
+		return dto.LoginRegisterResponse{}, ErrEmailExists
+	} else if err != nil {
 		return dto.LoginRegisterResponse{}, err
 	}
+	metrics.UsersRegistered.Inc()
 
 	token := s.jwtService.GenerateAccessToken(userID.String())
 
@@ -73,39 +134,64 @@ func (s UserService) Register(email, password string) (dto.LoginRegisterResponse
 	}, nil
 }
 
-func (s UserService) GetProfile(userId string) (dto.UserResponse, error) {
-	if profile, err := s.cacheService.GetUserProfile(userId); err == nil {
-		return profile, nil
+func (s UserService) GetProfile(ctx context.Context, userId string) (dto.UserResponse, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetProfile", attribute.String("user.id", userId))
+	defer span.End()
+
+	// Cached profiles keep the object key, the URL is signed on every read so
+	// it never outlives its expiry in the cache
+	// Profiles cached before versions existed are skipped, their ETag would
+	// never match
+	if profile, err := s.cacheService.GetUserProfile(ctx, userId); err == nil && profile.Version != 0 {
+		return s.withImageURL(ctx, profile), nil
 	}
 
-	user, err := s.userRepository.GetById(userId)
-	if err != nil {
+	user, err := s.userRepository.GetById(ctx, userId)
+	if errors.Is(err, dto.ErrUserNotFound) {
+		return dto.UserResponse{}, ErrUserNotFound
+	} else if err != nil {
 		return dto.UserResponse{}, err
 	}
 
-	response := dto.UserResponse{
-		Email:      user.Email,
-		Name:       user.Name,
-		Preference: user.Preference,
-		WeightUnit: user.WeightUnit,
-		HeightUnit: user.HeightUnit,
-		Weight:     user.Weight,
-		Height:     user.Height,
-		ImageUri:   user.ImageUri,
-	}
+	response := dto.NewUserResponseFromEntity(user)
 
 	// Cache the result for 5 minutes
-	s.cacheService.SetUserProfile(userId, response, 5*time.Minute)
+	s.cacheService.SetUserProfile(ctx, userId, response, 5*time.Minute)
 
-	return response, nil
+	return s.withImageURL(ctx, response), nil
 }
 
-func (s UserService) UpdateProfile(userId string, request dto.UserRequest) (dto.UserResponse, error) {
+// UpdateProfile replaces the profile fields. version is the one the client
+// based its update on, 0 when it didn't name one, see
+// ActivityService.UpdateActivity.
+func (s UserService) UpdateProfile(ctx context.Context, userId string, version int, request dto.UserRequest) (dto.UserResponse, error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateProfile", attribute.String("user.id", userId))
+	defer span.End()
+
 	// Get existing user first
-	existingUser, err := s.userRepository.GetById(userId)
-	if err != nil {
+	existingUser, err := s.userRepository.GetById(ctx, userId)
+	if errors.Is(err, dto.ErrUserNotFound) {
+		return dto.UserResponse{}, ErrUserNotFound
+	} else if err != nil {
 		return dto.UserResponse{}, err
 	}
+	if version != 0 && existingUser.Version != version {
+		return dto.UserResponse{}, ErrVersionMismatch
+	}
+	before := existingUser
+
+	// Only the user's own uploads, or the image they already have, can be
+	// shown: whatever key is stored gets presigned
+	imageKey := ""
+	if request.ImageUri != "" {
+		imageKey, err = s.fileService.ObjectKey(request.ImageUri)
+		if err != nil {
+			return dto.UserResponse{}, err
+		}
+		if imageKey != existingUser.ImageKey && !UploadedBy(imageKey, userId) {
+			return dto.UserResponse{}, ErrImageNotUploaded
+		}
+	}
 
 	// Update only the fields provided in the request
 	existingUser.Preference = request.Preference
@@ -114,14 +200,67 @@ func (s UserService) UpdateProfile(userId string, request dto.UserRequest) (dto.
 	existingUser.Weight = request.Weight
 	existingUser.Height = request.Height
 	existingUser.Name = request.Name
-	existingUser.ImageUri = request.ImageUri
+	existingUser.ImageKey = imageKey
 
-	if err = s.userRepository.Update(&existingUser); err != nil {
+	// Update reloads the stored row, the change is recorded as it was saved
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepository.Update(ctx, &existingUser); err != nil {
+			return err
+		}
+		err := s.auditService.Record(ctx, entity.AuditUpdate, entity.AuditEntityUser, existingUser.ID, existingUser.ID,
+			entity.DiffAuditFields(before.AuditFields(), existingUser.AuditFields()))
+		if err != nil {
+			return err
+		}
+		return s.outboxService.Emit(ctx, events.ProfileUpdated, existingUser.ID, existingUser.ID, events.ProfileUpdate{
+			Previous: events.NewProfile(before),
+			Current:  events.NewProfile(existingUser),
+		})
+	})
+	if errors.Is(err, dto.ErrVersionConflict) {
+		return dto.UserResponse{}, versionConflict(version)
+	} else if err != nil {
 		return dto.UserResponse{}, err
 	}
 
-	// Clear cache after update
-	s.cacheService.DeleteUserProfile(userId)
+	// Clear cache after update, even if the client has gone away since
+	s.cacheService.DeleteUserProfile(context.WithoutCancel(ctx), userId)
+
+	return s.withImageURL(ctx, dto.NewUserResponseFromEntity(existingUser)), nil
+}
+
+// upgradePasswordHash rehashes the password with the current algorithm and
+// cost when the stored hash is outdated. It only runs after a successful
+// login, the one moment the plain password is known.
+func (s UserService) upgradePasswordHash(ctx context.Context, user entity.User, password string) {
+	if !s.passwordHasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	hash, err := s.passwordHasher.Hash(password)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to rehash password", "user_id", user.ID.String(), "error", err)
+		return
+	}
+
+	if err := s.userRepository.UpdatePassword(ctx, user.ID, hash); err != nil {
+		slog.ErrorContext(ctx, "failed to store upgraded password hash", "user_id", user.ID.String(), "error", err)
+	}
+}
+
+// withImageURL replaces the stored image key with a presigned download URL
+func (s UserService) withImageURL(ctx context.Context, response dto.UserResponse) dto.UserResponse {
+	if response.ImageUri == "" {
+		return response
+	}
+
+	url, err := s.fileService.PresignGetURL(ctx, response.ImageUri)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to presign profile image", "error", err)
+		response.ImageUri = ""
+		return response
+	}
 
-	return dto.NewUserResponseFromEntity(existingUser), nil
+	response.ImageUri = url
+	return response
 }
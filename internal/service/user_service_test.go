@@ -0,0 +1,285 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/clock"
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/repository"
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/fikrialwan/FitByte/pkg/helpers"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type userFixture struct {
+	service      service.UserService
+	jwtService   service.JwtService
+	fileService  service.FileService
+	cacheService service.CacheService
+	hasher       *countingHasher
+	clock        *clock.Fake
+}
+
+// countingHasher counts password verifications, each one is a slow hash
+type countingHasher struct {
+	helpers.PasswordHasher
+	verifies int
+}
+
+func (h *countingHasher) Verify(hash string, password []byte) (bool, error) {
+	h.verifies++
+	return h.PasswordHasher.Verify(hash, password)
+}
+
+func newUserFixture(maxAttempts int) userFixture {
+	cfg := &config.Config{JWTSecret: "test-secret", LoginMaxAttemptsPerEmail: maxAttempts}
+	clk := clock.NewFake(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC))
+	f := userFixture{
+		jwtService:   service.NewJwtService(cfg, clk),
+		fileService:  service.NewMemoryFileService("http://storage.test"),
+		cacheService: service.NewMemoryCacheService(100),
+		clock:        clk,
+	}
+	loginAttemptService := service.NewLoginAttemptService(cfg, f.cacheService)
+	repos := repository.NewMemoryRepositories(clk)
+	// The cheapest bcrypt cost keeps registrations fast
+	hasher, err := helpers.NewPasswordHasher(helpers.AlgorithmBcrypt, bcrypt.MinCost, helpers.Argon2Params{})
+	if err != nil {
+		panic(err)
+	}
+	f.hasher = &countingHasher{PasswordHasher: hasher}
+	f.service = service.NewUserService(repos.Users, f.jwtService, f.cacheService, f.fileService, loginAttemptService, repos.Transactor, service.NewAuditService(repos.Audit), service.NewOutboxService(repos.Outbox), f.hasher)
+	return f
+}
+
+func TestUserServiceRegister(t *testing.T) {
+	f := newUserFixture(0)
+
+	tests := []struct {
+		name    string
+		email   string
+		wantErr error
+	}{
+		{name: "new email", email: "john@example.com"},
+		{name: "other email", email: "jane@example.com"},
+		{name: "taken email", email: "john@example.com", wantErr: service.ErrEmailExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := f.service.Register(context.Background(), tt.email, "password123")
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			userID, err := f.jwtService.GetUserIDByToken(res.Token)
+			if err != nil {
+				t.Fatalf("token of the new user is invalid: %v", err)
+			}
+			if _, err := uuid.Parse(userID); err != nil {
+				t.Errorf("token subject %q isn't a user ID", userID)
+			}
+		})
+	}
+}
+
+// Passwords shaped like a hash are still hashed, so they can log in
+func TestUserServiceHashShapedPassword(t *testing.T) {
+	f := newUserFixture(0)
+
+	for i, password := range []string{"$argon2id$hunter2x", "$2a$04$aaaaaaaaaaaaaaaaaaaaaa"} {
+		email := fmt.Sprintf("user%d@example.com", i)
+		if _, err := f.service.Register(context.Background(), email, password); err != nil {
+			t.Fatalf("Register(%q): %v", password, err)
+		}
+		if _, err := f.service.Verify(context.Background(), email, password, "203.0.113.1"); err != nil {
+			t.Errorf("Verify(%q): %v", password, err)
+		}
+	}
+}
+
+func TestUserServiceTokenExpiry(t *testing.T) {
+	f := newUserFixture(0)
+	res, err := f.service.Register(context.Background(), "john@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		wantErr bool
+	}{
+		{name: "fresh", advance: 0},
+		{name: "about to expire", advance: 24*time.Hour - time.Second},
+		{name: "expired", advance: 2 * time.Second, wantErr: true},
+	}
+
+	// Each case moves the clock further
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f.clock.Advance(tt.advance)
+
+			_, err := f.jwtService.GetUserIDByToken(res.Token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUserServiceVerify(t *testing.T) {
+	f := newUserFixture(0)
+	if _, err := f.service.Register(context.Background(), "john@example.com", "password123"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{name: "valid credentials", email: "john@example.com", password: "password123"},
+		{name: "wrong password", email: "john@example.com", password: "password124", wantErr: service.ErrInvalidCredentials},
+		{name: "unknown email", email: "jane@example.com", password: "password123", wantErr: service.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := f.service.Verify(context.Background(), tt.email, tt.password, "10.0.0.1")
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && res.Token == "" {
+				t.Error("no token issued")
+			}
+		})
+	}
+}
+
+func TestUserServiceVerifyLockout(t *testing.T) {
+	f := newUserFixture(3)
+	if _, err := f.service.Register(context.Background(), "john@example.com", "password123"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	for range 3 {
+		if _, err := f.service.Verify(context.Background(), "john@example.com", "wrong-password", "10.0.0.1"); !errors.Is(err, service.ErrInvalidCredentials) {
+			t.Fatalf("wrong password: err = %v, want %v", err, service.ErrInvalidCredentials)
+		}
+	}
+
+	// Locked out accounts look like wrong passwords, even with the right one,
+	// and take as long to reject
+	verifies := f.hasher.verifies
+	if _, err := f.service.Verify(context.Background(), "john@example.com", "password123", "10.0.0.2"); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Fatalf("locked out: err = %v, want %v", err, service.ErrInvalidCredentials)
+	}
+	if f.hasher.verifies != verifies+1 {
+		t.Errorf("locked out: %d password verifications, want 1", f.hasher.verifies-verifies)
+	}
+}
+
+func TestUserServiceProfile(t *testing.T) {
+	f := newUserFixture(0)
+	res, err := f.service.Register(context.Background(), "john@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	userID, _ := f.jwtService.GetUserIDByToken(res.Token)
+
+	if _, err := f.service.GetProfile(context.Background(), uuid.NewString()); !errors.Is(err, service.ErrUserNotFound) {
+		t.Fatalf("unknown user: err = %v, want %v", err, service.ErrUserNotFound)
+	}
+
+	// Caches the profile before the update
+	if _, err := f.service.GetProfile(context.Background(), userID); err != nil {
+		t.Fatalf("GetProfile: %v", err)
+	}
+
+	key, err := f.fileService.UploadToS3(context.Background(), userID, strings.NewReader("png"), "avatar.png", "image/png")
+	if err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	imageURL, err := f.fileService.PresignGetURL(context.Background(), key)
+	if err != nil {
+		t.Fatalf("PresignGetURL: %v", err)
+	}
+	request := dto.UserRequest{
+		Preference: "CARDIO",
+		WeightUnit: "KG",
+		HeightUnit: "CM",
+		Weight:     70,
+		Height:     175,
+		Name:       "John",
+		ImageUri:   imageURL,
+	}
+	if _, err := f.service.UpdateProfile(context.Background(), userID, 0, request); err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+
+	profile, err := f.service.GetProfile(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetProfile: %v", err)
+	}
+	if profile.Email != "john@example.com" || profile.Name != "John" || profile.Weight != 70 || profile.Preference != "CARDIO" {
+		t.Errorf("profile = %+v, want the updated fields", profile)
+	}
+	// Only the object key is stored, the URL is signed on every read
+	if !strings.Contains(profile.ImageUri, "/"+key+"?") {
+		t.Errorf("ImageUri = %q, want a presigned URL of the uploaded object", profile.ImageUri)
+	}
+}
+
+func TestUserServiceProfileImage(t *testing.T) {
+	f := newUserFixture(0)
+	register := func(email string) string {
+		res, err := f.service.Register(context.Background(), email, "password123")
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		userID, _ := f.jwtService.GetUserIDByToken(res.Token)
+		return userID
+	}
+	userID := register("john@example.com")
+	otherID := register("jane@example.com")
+
+	otherKey, err := f.fileService.UploadToS3(context.Background(), otherID, strings.NewReader("png"), "avatar.png", "image/png")
+	if err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	otherURL, _ := f.fileService.PresignGetURL(context.Background(), otherKey)
+
+	// Nothing but the user's own uploads can end up presigned
+	for _, uri := range []string{
+		otherURL,
+		"http://storage.test/fitbyte/config/secrets.json",
+		"http://storage.test/other-bucket/uploads/" + userID + "/avatar.png",
+		"http://storage.test/fitbyte/uploads/" + userID + "/../" + otherID + "/avatar.png",
+		"https://images.example.com/fitbyte/uploads/" + userID + "/avatar.png",
+	} {
+		_, err := f.service.UpdateProfile(context.Background(), userID, 0, dto.UserRequest{
+			Preference: "CARDIO",
+			WeightUnit: "KG",
+			HeightUnit: "CM",
+			Weight:     70,
+			Height:     175,
+			ImageUri:   uri,
+		})
+		if !errors.Is(err, service.ErrImageNotUploaded) {
+			t.Errorf("%s: err = %v, want %v", uri, err, service.ErrImageNotUploaded)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AWSMiddleware records a client span for every AWS SDK call, e.g.
+// "S3.PutObject". Add it with config.WithAPIOptions.
+func AWSMiddleware(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Tracing",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			service := awsmiddleware.GetServiceID(ctx)
+			operation := awsmiddleware.GetOperationName(ctx)
+
+			ctx, span := tracer().Start(ctx, service+"."+operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.RPCSystemKey.String("aws-api"),
+					semconv.RPCService(service),
+					semconv.RPCMethod(operation),
+					semconv.CloudRegion(awsmiddleware.GetRegion(ctx)),
+				),
+			)
+			defer span.End()
+
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			if response, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response); ok {
+				span.SetAttributes(semconv.HTTPResponseStatusCode(response.StatusCode))
+			}
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return out, metadata, err
+		}), middleware.After)
+}
@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/pkg/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin only lets the users listed in ADMIN_USER_IDS through, others
+// get a 403. It must run after Authenticate. The list follows config reloads,
+// so access can be revoked without a restart.
+func RequireAdmin(reloader *config.Reloader) gin.HandlerFunc {
+	var admins atomic.Pointer[map[string]struct{}]
+	build := func(cfg *config.Config) {
+		ids := make(map[string]struct{})
+		for _, id := range cfg.GetAdminUserIDs() {
+			ids[strings.ToLower(id)] = struct{}{}
+		}
+		admins.Store(&ids)
+	}
+	build(reloader.Current())
+	reloader.Subscribe(build)
+
+	return func(ctx *gin.Context) {
+		if _, ok := (*admins.Load())[strings.ToLower(ctx.GetString("user_id"))]; !ok {
+			problem.Write(ctx, problem.New(http.StatusForbidden, problem.CodeForbidden, "Administrator access is required"))
+			return
+		}
+		ctx.Next()
+	}
+}
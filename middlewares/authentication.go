@@ -4,7 +4,9 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/fikrialwan/FitByte/internal/logging"
 	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/fikrialwan/FitByte/pkg/problem"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,50 +15,41 @@ func Authenticate(jwtService service.JwtService) gin.HandlerFunc {
 		authHeader := ctx.GetHeader("Authorization")
 
 		if authHeader == "" {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Authorization header is required",
-			})
-			ctx.Abort()
+			unauthorized(ctx, "Authorization header is required")
 			return
 		}
 
 		if !strings.Contains(authHeader, "Bearer ") {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid authorization format. Use 'Bearer <token>'",
-			})
-			ctx.Abort()
+			unauthorized(ctx, "Invalid authorization format. Use 'Bearer <token>'")
 			return
 		}
 
 		authHeader = strings.TrimPrefix(authHeader, "Bearer ")
 		token, err := jwtService.ValidateToken(authHeader)
 		if err != nil {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid token: " + err.Error(),
-			})
-			ctx.Abort()
+			unauthorized(ctx, "Invalid token: "+err.Error())
 			return
 		}
 
 		if !token.Valid {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Token is not valid",
-			})
-			ctx.Abort()
+			unauthorized(ctx, "Token is not valid")
 			return
 		}
 
 		userId, err := jwtService.GetUserIDByToken(authHeader)
 		if err != nil {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Failed to extract user ID from token",
-			})
-			ctx.Abort()
+			unauthorized(ctx, "Failed to extract user ID from token")
 			return
 		}
 
 		ctx.Set("token", authHeader)
 		ctx.Set("user_id", userId)
+		ctx.Request = ctx.Request.WithContext(logging.WithUserID(ctx.Request.Context(), userId))
 		ctx.Next()
 	}
 }
+
+func unauthorized(ctx *gin.Context, message string) {
+	ctx.Header("WWW-Authenticate", "Bearer")
+	problem.Write(ctx, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, message))
+}
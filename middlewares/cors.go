@@ -1,13 +1,30 @@
 package middlewares
 
 import (
+	"sync/atomic"
+
 	"github.com/fikrialwan/FitByte/config"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-// CORS returns a CORS middleware with configurable settings
-func CORS(cfg *config.Config) gin.HandlerFunc {
+// CORS returns a CORS middleware with configurable settings. The settings are
+// rebuilt whenever reloader accepts a new configuration.
+func CORS(reloader *config.Reloader) gin.HandlerFunc {
+	var handler atomic.Pointer[gin.HandlerFunc]
+	build := func(cfg *config.Config) {
+		h := newCORS(cfg)
+		handler.Store(&h)
+	}
+	build(reloader.Current())
+	reloader.Subscribe(build)
+
+	return func(c *gin.Context) {
+		(*handler.Load())(c)
+	}
+}
+
+func newCORS(cfg *config.Config) gin.HandlerFunc {
 	return cors.New(cors.Config{
 		AllowOrigins:     cfg.GetCORSAllowedOrigins(),
 		AllowMethods:     cfg.GetCORSAllowedMethods(),
@@ -0,0 +1,160 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/internal/dto"
+	"github.com/fikrialwan/FitByte/internal/service"
+	"github.com/fikrialwan/FitByte/pkg/problem"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader lets clients retry a request without repeating
+	// its effects
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response replayed for a retry
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeIdempotencyKeyInUse  = "idempotency_key_in_use"
+
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodyBytes is read into memory to fingerprint the request,
+	// well above the 100KB upload limit
+	maxIdempotentBodyBytes = 1 << 20
+)
+
+// Idempotency stores the first response to a request carrying an
+// Idempotency-Key for ttl, and replays it verbatim for retries with the same
+// key. Keys are per user, so it must run after Authenticate. A retry arriving
+// while the first request is still running gets a 409, and a key reused for a
+// different request a 422. Requests without the header are left alone.
+func Idempotency(cacheService service.CacheService, ttl time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			problem.Write(ctx, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Idempotency-Key can't be longer than 255 characters"))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxIdempotentBodyBytes))
+		if err != nil {
+			problem.Write(ctx, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Failed to read request body"))
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		reqCtx := ctx.Request.Context()
+		userID := ctx.GetString("user_id")
+		fingerprint := requestFingerprint(ctx.Request, body)
+
+		// The reservation outlives a crashed request by at most the longest a
+		// request can run
+		existing, reserved, err := cacheService.ReserveIdempotencyKey(reqCtx, userID, key, dto.IdempotencyRecord{Fingerprint: fingerprint}, config.ServerWriteTimeout)
+		if errors.Is(err, service.ErrCacheMiss) {
+			// Other requests kept taking and releasing the key, running this
+			// one too could repeat their effects
+			problem.Write(ctx, problem.New(http.StatusConflict, CodeIdempotencyKeyInUse, "A request with this Idempotency-Key is still in progress"))
+			return
+		}
+		if err != nil {
+			// Retries can't be recognised without the cache, serve the request
+			// as if it had no key rather than failing it
+			slog.WarnContext(reqCtx, "idempotency key not checked", "error", err)
+			ctx.Next()
+			return
+		}
+		if !reserved {
+			replay(ctx, existing, fingerprint)
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = recorder
+		ctx.Next()
+
+		// The request may have ended with its context, the outcome must still
+		// be recorded
+		saveCtx := context.WithoutCancel(reqCtx)
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError || errors.Is(reqCtx.Err(), context.Canceled) {
+			// Nothing was done, or the client never saw the outcome: let the
+			// retry run again
+			if err := cacheService.DeleteIdempotencyRecord(saveCtx, userID, key); err != nil {
+				slog.WarnContext(reqCtx, "failed to release idempotency key", "error", err)
+			}
+			return
+		}
+
+		record := dto.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			ETag:        recorder.Header().Get("ETag"),
+			Body:        recorder.body.Bytes(),
+		}
+		if err := cacheService.SetIdempotencyRecord(saveCtx, userID, key, record, ttl); err != nil {
+			slog.WarnContext(reqCtx, "failed to store idempotent response", "error", err)
+		}
+	}
+}
+
+func replay(ctx *gin.Context, record dto.IdempotencyRecord, fingerprint string) {
+	switch {
+	case record.Fingerprint != fingerprint:
+		problem.Write(ctx, problem.New(http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request"))
+	case !record.Completed():
+		problem.Write(ctx, problem.New(http.StatusConflict, CodeIdempotencyKeyInUse, "A request with this Idempotency-Key is still in progress"))
+	default:
+		ctx.Header(IdempotentReplayedHeader, "true")
+		if record.ETag != "" {
+			ctx.Header("ETag", record.ETag)
+		}
+		ctx.Data(record.Status, record.ContentType, record.Body)
+		ctx.Abort()
+	}
+}
+
+// requestFingerprint identifies what a request asks for. Multipart boundaries
+// are left out since clients pick a new one on every attempt.
+func requestFingerprint(req *http.Request, body []byte) string {
+	if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && params["boundary"] != "" {
+		body = bytes.ReplaceAll(body, []byte(params["boundary"]), nil)
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + " " + req.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// bodyRecorder keeps a copy of the response body
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/fikrialwan/FitByte/internal/logging"
+	"github.com/fikrialwan/FitByte/pkg/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// quietPaths are polled constantly, their requests are only logged at debug
+// level
+var quietPaths = map[string]bool{
+	"/metrics":   true,
+	"/v1/health": true,
+	"/v1/ready":  true,
+}
+
+// Logger writes one line per request with its route, status and latency.
+// Server errors are logged at error level and client errors at warn level.
+// Query parameters are kept, but the values of sensitive ones are redacted.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case quietPaths[c.Request.URL.Path]:
+			level = slog.LevelDebug
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+		}
+		if query := logging.RedactQuery(c.Request.URL.RawQuery); query != "" {
+			attrs = append(attrs, slog.String("query", query))
+		}
+		if errors := c.Errors.ByType(gin.ErrorTypePrivate).String(); errors != "" {
+			attrs = append(attrs, slog.String("error", errors))
+		}
+
+		// The user ID is only known once authentication ran further down the
+		// chain, so it is read from the gin context rather than the request's
+		ctx := c.Request.Context()
+		if userID := c.GetString("user_id"); userID != "" && logging.UserID(ctx) == "" {
+			ctx = logging.WithUserID(ctx, userID)
+		}
+		slog.LogAttrs(ctx, level, "request", attrs...)
+	}
+}
+
+// Recovery turns a panic into a 500 problem response and logs it with its
+// stack trace
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered any) {
+		slog.ErrorContext(c.Request.Context(), "panic recovered",
+			"error", fmt.Sprint(recovered),
+			"stack", string(debug.Stack()),
+		)
+		problem.Write(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "Internal server error"))
+	})
+}
@@ -1,66 +1,169 @@
 package middlewares
 
 import (
+	"context"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fikrialwan/FitByte/config"
+	"github.com/fikrialwan/FitByte/pkg/problem"
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
+	"github.com/redis/go-redis/v9"
 )
 
-type IPRateLimiter struct {
-	ips map[string]*rate.Limiter
-	mu  *sync.RWMutex
-	r   rate.Limit
-	b   int
+// RateLimitPolicy allows Limit requests per Period with bursts of up to Burst
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+	Burst  int
 }
 
-func NewIPRateLimiter(r rate.Limit, b int) *IPRateLimiter {
-	i := &IPRateLimiter{
-		ips: make(map[string]*rate.Limiter),
-		mu:  &sync.RWMutex{},
-		r:   r,
-		b:   b,
+// RateLimitResult is the outcome of a single limiter call
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimiter decides whether the request identified by key may proceed under
+// the given policy
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error)
+}
+
+// NewRateLimiter returns the limiter selected by RATE_LIMIT_DRIVER: redis
+// (default) shares state across replicas, memory is process-local
+func NewRateLimiter(cfg *config.Config, redisClient *redis.Client) RateLimiter {
+	if cfg.RateLimitDriver == "memory" {
+		return NewMemoryRateLimiter()
+	}
+	return NewRedisRateLimiter(redisClient)
+}
+
+// gcra computes the Generic Cell Rate Algorithm step shared by both limiters.
+// tat is the theoretical arrival time of the next request.
+func gcra(now, tat time.Time, policy RateLimitPolicy) (RateLimitResult, time.Time) {
+	emissionInterval := policy.Period / time.Duration(policy.Limit)
+	burstOffset := emissionInterval * time.Duration(policy.Burst)
+
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(emissionInterval)
+	allowAt := newTat.Add(-burstOffset)
+
+	diff := now.Sub(allowAt)
+	if diff < 0 {
+		return RateLimitResult{
+			Allowed:    false,
+			Limit:      policy.Burst,
+			Remaining:  0,
+			ResetAfter: tat.Sub(now),
+			RetryAfter: -diff,
+		}, tat
 	}
 
-	return i
+	return RateLimitResult{
+		Allowed:    true,
+		Limit:      policy.Burst,
+		Remaining:  int(diff / emissionInterval),
+		ResetAfter: newTat.Sub(now),
+	}, newTat
 }
 
-func (i *IPRateLimiter) AddIP(ip string) *rate.Limiter {
-	i.mu.Lock()
-	defer i.mu.Unlock()
+// memoryRateLimiter keeps GCRA state per key in process memory. Keys whose
+// state has fully decayed are swept periodically so the map stays bounded by
+// the number of recently active clients.
+type memoryRateLimiter struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimiter() RateLimiter {
+	return &memoryRateLimiter{
+		tats:      make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
 
-	limiter := rate.NewLimiter(i.r, i.b)
-	i.ips[ip] = limiter
+func (m *memoryRateLimiter) Allow(_ context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	now := time.Now()
+	key = policy.Name + ":" + key
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > time.Minute {
+		for k, tat := range m.tats {
+			if tat.Before(now) {
+				delete(m.tats, k)
+			}
+		}
+		m.lastSweep = now
+	}
 
-	return limiter
+	result, tat := gcra(now, m.tats[key], policy)
+	m.tats[key] = tat
+	return result, nil
 }
 
-func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
-	i.mu.Lock()
-	limiter, exists := i.ips[ip]
+// RateLimitKeyFunc extracts the identity a policy is applied to
+type RateLimitKeyFunc func(ctx *gin.Context) string
 
-	if !exists {
-		i.mu.Unlock()
-		return i.AddIP(ip)
+// KeyByIP limits by client IP
+func KeyByIP(ctx *gin.Context) string {
+	return "ip:" + ctx.ClientIP()
+}
+
+// KeyByUser limits by authenticated user ID, falling back to client IP. It
+// must run after Authenticate.
+func KeyByUser(ctx *gin.Context) string {
+	if userID := ctx.GetString("user_id"); userID != "" {
+		return "user:" + userID
 	}
+	return KeyByIP(ctx)
+}
 
-	i.mu.Unlock()
-	return limiter
+// RateLimit enforces policy on every request and reports the state through
+// RateLimit-* headers. Limiter errors fail open so a Redis outage doesn't take
+// the API down with it.
+func RateLimit(limiter RateLimiter, policy RateLimitPolicy, keyFunc RateLimitKeyFunc) gin.HandlerFunc {
+	return rateLimit(limiter, func() *RateLimitPolicy { return &policy }, keyFunc)
 }
 
-func RateLimit(limiter *IPRateLimiter) gin.HandlerFunc {
+// rateLimit reads the policy on every request so it can change at runtime, a
+// nil policy lets the request through
+func rateLimit(limiter RateLimiter, policyFunc func() *RateLimitPolicy, keyFunc RateLimitKeyFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		l := limiter.GetLimiter(ip)
-
-		if !l.Allow() {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Rate limit exceeded. Please try again later.",
-			})
-			c.Abort()
+		policy := policyFunc()
+		if policy == nil {
+			c.Next()
+			return
+		}
+
+		result, err := limiter.Allow(c.Request.Context(), keyFunc(c), *policy)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "rate limiter unavailable, allowing request", "policy", policy.Name, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			problem.Write(c, problem.New(http.StatusTooManyRequests, problem.CodeRateLimited, "Rate limit exceeded. Please try again later."))
 			return
 		}
 
@@ -68,20 +171,66 @@ func RateLimit(limiter *IPRateLimiter) gin.HandlerFunc {
 	}
 }
 
-// Global rate limiter
-var GlobalRateLimiter *IPRateLimiter
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// RateLimits holds the per route group limiters. Disabled limits are no-ops so
+// routes can always register them.
+type RateLimits struct {
+	// Global applies to every request, keyed by IP
+	Global gin.HandlerFunc
+	// Auth guards login and registration, keyed by IP
+	Auth gin.HandlerFunc
+	// User applies to authenticated routes, keyed by user ID
+	User gin.HandlerFunc
+}
+
+// rateLimitPolicies is swapped as a whole on reload so a request never sees
+// a mix of old and new limits
+type rateLimitPolicies struct {
+	global, auth, user *RateLimitPolicy
+}
 
-// InitGlobalRateLimiter initializes the global rate limiter with config
-func InitGlobalRateLimiter(cfg *config.Config) {
-	rateLimit := rate.Every(time.Microsecond)
-	if cfg.RateLimitPerSecond > 0 {
-		rateLimit = rate.Limit(cfg.RateLimitPerSecond)
+func newRateLimitPolicies(cfg *config.Config) *rateLimitPolicies {
+	if !cfg.RateLimitEnabled {
+		return &rateLimitPolicies{}
 	}
 
-	burst := 100
-	if cfg.RateLimitBurst > 0 {
-		burst = cfg.RateLimitBurst
+	return &rateLimitPolicies{
+		global: &RateLimitPolicy{
+			Name:   "global",
+			Limit:  cfg.GetRateLimitPerSecond(),
+			Period: time.Second,
+			Burst:  cfg.GetRateLimitBurst(),
+		},
+		auth: &RateLimitPolicy{
+			Name:   "auth",
+			Limit:  cfg.GetRateLimitAuthPerMinute(),
+			Period: time.Minute,
+			Burst:  cfg.GetRateLimitAuthBurst(),
+		},
+		user: &RateLimitPolicy{
+			Name:   "user",
+			Limit:  cfg.GetRateLimitUserPerSecond(),
+			Period: time.Second,
+			Burst:  cfg.GetRateLimitUserBurst(),
+		},
 	}
+}
 
-	GlobalRateLimiter = NewIPRateLimiter(rateLimit, burst)
+// NewRateLimits builds the route group limiters from the current
+// configuration and follows reloads of the RATE_LIMIT_* settings
+func NewRateLimits(reloader *config.Reloader, limiter RateLimiter) RateLimits {
+	var policies atomic.Pointer[rateLimitPolicies]
+	policies.Store(newRateLimitPolicies(reloader.Current()))
+	reloader.Subscribe(func(cfg *config.Config) {
+		policies.Store(newRateLimitPolicies(cfg))
+	})
+
+	return RateLimits{
+		Global: rateLimit(limiter, func() *RateLimitPolicy { return policies.Load().global }, KeyByIP),
+		Auth:   rateLimit(limiter, func() *RateLimitPolicy { return policies.Load().auth }, KeyByIP),
+		User:   rateLimit(limiter, func() *RateLimitPolicy { return policies.Load().user }, KeyByUser),
+	}
 }
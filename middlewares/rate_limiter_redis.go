@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript runs the GCRA step atomically in Redis using the server clock so
+// every replica shares the same state. It returns
+// {allowed, remaining, retry_after, reset_after} with durations in seconds.
+var gcraScript = redis.NewScript(`
+local key = KEYS[1]
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+
+local emission_interval = period / rate
+local burst_offset = emission_interval * burst
+
+local now = redis.call("TIME")
+now = tonumber(now[1]) + (tonumber(now[2]) / 1000000)
+
+local tat = redis.call("GET", key)
+if not tat then
+  tat = now
+else
+  tat = tonumber(tat)
+end
+tat = math.max(tat, now)
+
+local new_tat = tat + emission_interval
+local allow_at = new_tat - burst_offset
+local diff = now - allow_at
+
+if diff < 0 then
+  return {0, 0, tostring(-diff), tostring(tat - now)}
+end
+
+local reset_after = new_tat - now
+redis.call("SET", key, tostring(new_tat), "EX", math.ceil(reset_after))
+return {1, math.floor(diff / emission_interval), "0", tostring(reset_after)}
+`)
+
+type redisRateLimiter struct {
+	client *redis.Client
+}
+
+func NewRedisRateLimiter(client *redis.Client) RateLimiter {
+	return &redisRateLimiter{client: client}
+}
+
+func (r *redisRateLimiter) Allow(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	values, err := gcraScript.Run(ctx, r.client, []string{"ratelimit:" + policy.Name + ":" + key},
+		policy.Burst, policy.Limit, policy.Period.Seconds()).Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	retryAfter, err := parseSeconds(values[2])
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	resetAfter, err := parseSeconds(values[3])
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return RateLimitResult{
+		Allowed:    values[0].(int64) == 1,
+		Limit:      policy.Burst,
+		Remaining:  int(values[1].(int64)),
+		ResetAfter: resetAfter,
+		RetryAfter: retryAfter,
+	}, nil
+}
+
+func parseSeconds(value interface{}) (time.Duration, error) {
+	str, _ := value.(string)
+	seconds, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"github.com/fikrialwan/FitByte/internal/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds IDs accepted from clients so they can't bloat logs
+const maxRequestIDLength = 128
+
+// RequestID reuses the caller's X-Request-ID, or generates one when it is
+// missing or malformed, and echoes it in the response. The ID is stored in the
+// request context so every log line of the request carries it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Set("request_id", requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// validRequestID accepts printable ASCII without spaces, which covers UUIDs
+// and the IDs generated by common proxies
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// untracedPaths are polled by Prometheus and the kubelet and would drown out
+// real traffic
+var untracedPaths = map[string]bool{
+	"/metrics":   true,
+	"/v1/health": true,
+	"/v1/ready":  true,
+}
+
+// Tracing starts a server span per request, continuing the trace from the W3C
+// traceparent header when present. Spans are named after the route template.
+func Tracing(serviceName string) gin.HandlerFunc {
+	return otelgin.Middleware(serviceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return !untracedPaths[r.URL.Path]
+	}))
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag formats a resource version as a strong entity tag, e.g. "3"
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ResponseWithETag sends data tagged with the version. A GET whose
+// If-None-Match already holds the tag gets an empty 304 instead.
+func ResponseWithETag(ctx *gin.Context, statusCode int, version int, data interface{}) {
+	etag := ETag(version)
+	ctx.Header("ETag", etag)
+
+	if ctx.Request.Method == http.MethodGet && statusCode == http.StatusOK && ifNoneMatches(ctx.GetHeader("If-None-Match"), etag) {
+		ctx.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	ctx.JSON(statusCode, data)
+}
+
+// ResponseWithVersion sends data tagged with the version for If-Match, but
+// always in full and uncached. It is for bodies that change between
+// versions, e.g. with presigned URLs that expire.
+func ResponseWithVersion(ctx *gin.Context, statusCode int, version int, data interface{}) {
+	ctx.Header("ETag", ETag(version))
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(statusCode, data)
+}
+
+// IfMatchVersion returns the version named by If-Match, 0 when the header is
+// missing or "*". ok is false when the header holds anything but a single
+// strong tag issued by ETag, a precondition that can never hold.
+func IfMatchVersion(ctx *gin.Context) (version int, ok bool) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+
+	unquoted, found := strings.CutPrefix(header, `"`)
+	if !found {
+		return 0, false
+	}
+	unquoted, found = strings.CutSuffix(unquoted, `"`)
+	if !found {
+		return 0, false
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+// ifNoneMatches reports whether an If-None-Match header matches etag. The
+// comparison is weak as RFC 9110 asks, W/ prefixes are ignored.
+func ifNoneMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
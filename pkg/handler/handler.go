@@ -1,15 +1,97 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"reflect"
 
+	"github.com/fikrialwan/FitByte/pkg/problem"
 	"github.com/fikrialwan/FitByte/pkg/validator"
 	"github.com/gin-gonic/gin"
+	playground "github.com/go-playground/validator/v10"
 )
 
-// Handle response error
-func ResponseError(ctx *gin.Context, statusCode int, message string) {
-	ctx.JSON(statusCode, gin.H{"error": message})
+// Handle response error as problem+json with a stable code
+func ResponseError(ctx *gin.Context, statusCode int, code, message string) {
+	problem.Write(ctx, problem.New(statusCode, code, message))
+}
+
+// Handle validation error, listing each failing field. Submitted values are
+// left out so passwords aren't echoed back.
+func ResponseValidationError(ctx *gin.Context, err error) {
+	problem.Write(ctx, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "Invalid request format").
+		WithErrors(fieldErrors(err)...))
+}
+
+// Handle validation error of a single field
+func ResponseFieldError(ctx *gin.Context, field, message string) {
+	problem.Write(ctx, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, message).
+		WithErrors(problem.FieldError{Field: field, Message: message}))
+}
+
+// fieldErrors extracts the failing fields from schema, binding and JSON
+// decoding errors
+func fieldErrors(err error) []problem.FieldError {
+	var schemaErrors validator.ValidationErrors
+	if errors.As(err, &schemaErrors) {
+		fields := make([]problem.FieldError, len(schemaErrors))
+		for i, e := range schemaErrors {
+			fields[i] = problem.FieldError{Field: e.Field, Message: e.Message}
+		}
+		return fields
+	}
+
+	var bindingErrors playground.ValidationErrors
+	if errors.As(err, &bindingErrors) {
+		fields := make([]problem.FieldError, len(bindingErrors))
+		for i, e := range bindingErrors {
+			fields[i] = problem.FieldError{Field: validator.FieldPath(e), Message: bindingMessage(e)}
+		}
+		return fields
+	}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) && typeError.Field != "" {
+		return []problem.FieldError{{Field: typeError.Field, Message: fmt.Sprintf("expected %s type", jsonTypeName(typeError))}}
+	}
+
+	return nil
+}
+
+func bindingMessage(e playground.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "field is required"
+	case "min":
+		return fmt.Sprintf("minimum is %s", e.Param())
+	case "max":
+		return fmt.Sprintf("maximum is %s", e.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", e.Param())
+	case "email":
+		return "invalid email format"
+	case "url":
+		return "invalid URL format"
+	}
+	return fmt.Sprintf("failed the '%s' rule", e.Tag())
+}
+
+func jsonTypeName(err *json.UnmarshalTypeError) string {
+	switch err.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return err.Type.Kind().String()
 }
 
 // Handle response success
@@ -21,21 +103,21 @@ func ResponseSuccess(ctx *gin.Context, statusCode int, data interface{}) {
 func BindAndValidate(ctx *gin.Context, data interface{}) bool {
 	// Check for empty body first
 	if ctx.Request.ContentLength == 0 {
-		ResponseError(ctx, http.StatusBadRequest, "Invalid request format")
+		ResponseError(ctx, http.StatusBadRequest, problem.CodeInvalidRequest, "Request body is required")
 		return true
 	}
 
 	// First try to bind - this will catch JSON parsing errors and basic type mismatches
 	err := ctx.ShouldBind(data)
 	if err != nil {
-		ResponseError(ctx, http.StatusBadRequest, "Invalid request format")
+		ResponseValidationError(ctx, err)
 		return true
 	}
 
 	// Then validate the struct with our validation rules
 	isError := validator.Check(data)
 	if isError {
-		ResponseError(ctx, http.StatusBadRequest, "Invalid request format")
+		ResponseError(ctx, http.StatusBadRequest, problem.CodeValidationFailed, "Invalid request format")
 		return true
 	}
 
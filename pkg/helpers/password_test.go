@@ -0,0 +1,48 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"github.com/fikrialwan/FitByte/pkg/helpers"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Plain passwords shaped like hashes, all valid by the register rules
+var hashShapedPasswords = []string{
+	"$argon2id$hunter2x",
+	"$2a$04$aaaaaaaaaaaaaaaaaaaaaa",
+	"password123",
+}
+
+func TestPasswordHasherHashesEveryInput(t *testing.T) {
+	hashers := map[string]helpers.Argon2Params{
+		helpers.AlgorithmBcrypt:   {},
+		helpers.AlgorithmArgon2id: {Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+	}
+	for algorithm, params := range hashers {
+		hasher, err := helpers.NewPasswordHasher(algorithm, bcrypt.MinCost, params)
+		if err != nil {
+			t.Fatalf("NewPasswordHasher(%s): %v", algorithm, err)
+		}
+
+		for _, password := range hashShapedPasswords {
+			hash, err := hasher.Hash(password)
+			if err != nil {
+				t.Fatalf("%s: Hash(%q): %v", algorithm, password, err)
+			}
+			if hash == password {
+				t.Errorf("%s: Hash(%q) returned the password unchanged", algorithm, password)
+			}
+			if ok, err := hasher.Verify(hash, []byte(password)); err != nil || !ok {
+				t.Errorf("%s: Verify(Hash(%q)) = %v, %v, want true", algorithm, password, ok, err)
+			}
+			if ok, _ := hasher.Verify(hash, []byte(password+"!")); ok {
+				t.Errorf("%s: Verify accepted a wrong password for %q", algorithm, password)
+			}
+			// The plain password never verifies as its own hash
+			if ok, _ := hasher.Verify(password, []byte(password)); ok {
+				t.Errorf("%s: Verify(%q, %q) = true, want false", algorithm, password, password)
+			}
+		}
+	}
+}
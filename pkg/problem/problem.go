@@ -0,0 +1,69 @@
+package problem
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of RFC 7807 problem details
+const ContentType = "application/problem+json"
+
+// Codes shared by every endpoint, domain specific codes live with the
+// services that return them
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeRateLimited      = "rate_limited"
+	CodeTimeout          = "timeout"
+	CodeInternal         = "internal_error"
+)
+
+// FieldError is a single failing field of a validation problem
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details body. Code is a stable machine
+// readable identifier clients can switch on, Detail is for humans and may
+// change.
+type Problem struct {
+	Type     string       `json:"type" example:"about:blank"`
+	Title    string       `json:"title" example:"Not Found"`
+	Status   int          `json:"status" example:"404"`
+	Detail   string       `json:"detail,omitempty" example:"Activity not found"`
+	Instance string       `json:"instance,omitempty" example:"/v1/activity/123e4567-e89b-12d3-a456-426614174000"`
+	Code     string       `json:"code" example:"activity_not_found"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// New returns a problem whose title is the status text
+func New(status int, code, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// WithErrors attaches the failing fields
+func (p Problem) WithErrors(errs ...FieldError) Problem {
+	p.Errors = append(p.Errors, errs...)
+	return p
+}
+
+// Write sends the problem for the current request and aborts the chain
+func Write(ctx *gin.Context, p Problem) {
+	if p.Instance == "" {
+		p.Instance = ctx.Request.URL.Path
+	}
+	ctx.Header("Content-Type", ContentType)
+	ctx.AbortWithStatusJSON(p.Status, p)
+}
@@ -4,30 +4,50 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// ValidationRule defines validation constraints for a field
+// ValidationRule defines validation constraints for a field. Required means
+// the field must be present, NotNull means a present field can't be null (or
+// an empty string).
 type ValidationRule struct {
-	Type        string   `json:"type"`
-	NotNull     bool     `json:"notNull"`
-	MinLength   *int     `json:"minLength,omitempty"`
-	MaxLength   *int     `json:"maxLength,omitempty"`
-	Min         *float64 `json:"min,omitempty"`
-	Max         *float64 `json:"max,omitempty"`
-	Enum        []string `json:"enum,omitempty"`
-	IsEmail     bool     `json:"isEmail,omitempty"`
-	IsUrl       bool     `json:"isUrl,omitempty"`
+	Type      string   `json:"type"`
+	Required  bool     `json:"required,omitempty"`
+	NotNull   bool     `json:"notNull"`
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	Enum      []string `json:"enum,omitempty"`
+	IsEmail   bool     `json:"isEmail,omitempty"`
+	IsUrl     bool     `json:"isUrl,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	// Format checks well-known string formats, only "date-time" (RFC 3339)
+	// is supported
+	Format string `json:"format,omitempty"`
+	// Rules names custom checks added with RegisterRule
+	Rules []string `json:"rules,omitempty"`
+
+	// Properties validates the fields of an object
+	Properties ValidationSchema `json:"properties,omitempty"`
+	// Items validates every element of an array
+	Items *ValidationRule `json:"items,omitempty"`
 }
 
 // ValidationSchema defines validation rules for an object
 type ValidationSchema map[string]ValidationRule
 
-// ValidationError represents a validation error
+// FormatDateTime is the Format for RFC 3339 timestamps
+const FormatDateTime = "date-time"
+
+// ValidationError represents a validation error. Field is the path to the
+// value, e.g. "items[2].name".
 type ValidationError struct {
-	Field   string `json:"field"`
-	Message string `json:"message"`
+	Field   string      `json:"field"`
+	Message string      `json:"message"`
 	Value   interface{} `json:"value"`
 }
 
@@ -35,154 +55,200 @@ func (e ValidationError) Error() string {
 	return fmt.Sprintf("validation failed for field '%s': %s", e.Field, e.Message)
 }
 
+// ValidationErrors is every problem found in a payload, ordered by field
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
 // JSONValidator provides comprehensive JSON validation
 type JSONValidator struct {
-	schema ValidationSchema
+	schema   ValidationSchema
+	patterns map[string]*regexp.Regexp
 }
 
-// NewJSONValidator creates a new validator with the given schema
+// NewJSONValidator creates a new validator with the given schema. It panics
+// if the schema contains an invalid Pattern.
 func NewJSONValidator(schema ValidationSchema) *JSONValidator {
-	return &JSONValidator{schema: schema}
+	v := &JSONValidator{schema: schema, patterns: make(map[string]*regexp.Regexp)}
+	v.compilePatterns(schema)
+	return v
+}
+
+func (v *JSONValidator) compilePatterns(schema ValidationSchema) {
+	for _, rule := range schema {
+		v.compileRule(rule)
+	}
+}
+
+func (v *JSONValidator) compileRule(rule ValidationRule) {
+	if rule.Pattern != "" {
+		if _, ok := v.patterns[rule.Pattern]; !ok {
+			v.patterns[rule.Pattern] = regexp.MustCompile(rule.Pattern)
+		}
+	}
+	v.compilePatterns(rule.Properties)
+	if rule.Items != nil {
+		v.compileRule(*rule.Items)
+	}
 }
 
-// ValidateJSON validates a JSON payload against the schema
+// ValidateJSON validates a JSON payload against the schema. It returns
+// ValidationErrors listing every problem, or nil.
 func (v *JSONValidator) ValidateJSON(jsonData []byte) error {
-	var data map[string]interface{}
+	var data interface{}
 	if err := json.Unmarshal(jsonData, &data); err != nil {
-		return ValidationError{
+		return ValidationErrors{{
 			Field:   "root",
 			Message: "invalid JSON format",
-			Value:   string(jsonData),
-		}
+		}}
+	}
+
+	object, ok := data.(map[string]interface{})
+	if !ok {
+		return ValidationErrors{{
+			Field:   "root",
+			Message: "expected object type",
+			Value:   data,
+		}}
 	}
 
-	return v.ValidateObject(data)
+	return v.ValidateObject(object)
 }
 
-// ValidateObject validates an object against the schema
+// ValidateObject validates an object against the schema. It returns
+// ValidationErrors listing every problem, or nil.
 func (v *JSONValidator) ValidateObject(data map[string]interface{}) error {
-	for field, rule := range v.schema {
-		value, exists := data[field]
-		
-		if err := v.validateField(field, value, exists, rule); err != nil {
-			return err
-		}
+	var errs ValidationErrors
+	v.validateObject("", data, v.schema, &errs)
+	if len(errs) > 0 {
+		return errs
 	}
 	return nil
 }
 
+// validateObject checks data against schema in field name order so the
+// reported errors are stable
+func (v *JSONValidator) validateObject(path string, data map[string]interface{}, schema ValidationSchema, errs *ValidationErrors) {
+	fields := make([]string, 0, len(schema))
+	for field := range schema {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		value, exists := data[field]
+		v.validateField(joinPath(path, field), value, exists, schema[field], errs)
+	}
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
 // validateField validates a single field against its rule
-func (v *JSONValidator) validateField(field string, value interface{}, exists bool, rule ValidationRule) error {
+func (v *JSONValidator) validateField(field string, value interface{}, exists bool, rule ValidationRule, errs *ValidationErrors) {
+	if !exists {
+		if rule.Required {
+			errs.add(field, "field is required", nil)
+		}
+		return
+	}
+
 	// Check null values
 	if value == nil {
 		if rule.NotNull {
-			return ValidationError{
-				Field:   field,
-				Message: "field cannot be null",
-				Value:   nil,
-			}
+			errs.add(field, "field cannot be null", nil)
 		}
-		return nil // null is allowed
+		return // null is allowed
 	}
 
-	// Type validation
-	if err := v.validateType(field, value, rule.Type); err != nil {
-		return err
+	// Type validation, the other rules only make sense for the right type
+	if !v.validateType(field, value, rule.Type, errs) {
+		return
 	}
 
-	// String-specific validations
-	if rule.Type == "string" {
+	v.validateRules(field, value, rule, errs)
+
+	switch rule.Type {
+	case "string":
 		if str, ok := value.(string); ok {
-			if err := v.validateString(field, str, rule); err != nil {
-				return err
-			}
+			v.validateString(field, str, rule, errs)
 		}
-	}
-
-	// Number-specific validations
-	if rule.Type == "number" {
+	case "number":
 		if num := v.getNumberValue(value); num != nil {
-			if err := v.validateNumber(field, *num, rule); err != nil {
-				return err
+			v.validateNumber(field, *num, rule, errs)
+		}
+	case "object":
+		if rule.Properties != nil {
+			v.validateObject(field, value.(map[string]interface{}), rule.Properties, errs)
+		}
+	case "array":
+		if rule.Items != nil {
+			for i, item := range value.([]interface{}) {
+				v.validateField(fmt.Sprintf("%s[%d]", field, i), item, true, *rule.Items, errs)
 			}
 		}
 	}
+}
 
-	return nil
+// add records a problem once, overlapping rules such as url and a custom URL
+// rule may report the same message
+func (e *ValidationErrors) add(field, message string, value interface{}) {
+	for _, existing := range *e {
+		if existing.Field == field && existing.Message == message {
+			return
+		}
+	}
+	*e = append(*e, ValidationError{Field: field, Message: message, Value: value})
 }
 
 // validateType checks if the value matches the expected type
-func (v *JSONValidator) validateType(field string, value interface{}, expectedType string) error {
+func (v *JSONValidator) validateType(field string, value interface{}, expectedType string, errs *ValidationErrors) bool {
+	var ok bool
 	switch expectedType {
 	case "string":
-		if _, ok := value.(string); !ok {
-			return ValidationError{
-				Field:   field,
-				Message: "expected string type",
-				Value:   value,
-			}
-		}
+		_, ok = value.(string)
 	case "number":
-		if !v.isNumber(value) {
-			return ValidationError{
-				Field:   field,
-				Message: "expected number type",
-				Value:   value,
-			}
-		}
+		ok = v.isNumber(value)
 	case "boolean":
-		if _, ok := value.(bool); !ok {
-			return ValidationError{
-				Field:   field,
-				Message: "expected boolean type",
-				Value:   value,
-			}
-		}
+		_, ok = value.(bool)
 	case "object":
-		if _, ok := value.(map[string]interface{}); !ok {
-			return ValidationError{
-				Field:   field,
-				Message: "expected object type",
-				Value:   value,
-			}
-		}
+		_, ok = value.(map[string]interface{})
 	case "array":
-		if _, ok := value.([]interface{}); !ok {
-			return ValidationError{
-				Field:   field,
-				Message: "expected array type",
-				Value:   value,
-			}
-		}
+		_, ok = value.([]interface{})
+	default:
+		return true
 	}
-	return nil
+
+	if !ok {
+		errs.add(field, fmt.Sprintf("expected %s type", expectedType), value)
+	}
+	return ok
 }
 
 // validateString performs string-specific validations
-func (v *JSONValidator) validateString(field, str string, rule ValidationRule) error {
+func (v *JSONValidator) validateString(field, str string, rule ValidationRule, errs *ValidationErrors) {
 	// Empty string validation for required fields
 	if rule.NotNull && str == "" {
-		return ValidationError{
-			Field:   field,
-			Message: "field cannot be empty",
-			Value:   str,
-		}
+		errs.add(field, "field cannot be empty", str)
+		return
 	}
 
 	// Length validation
 	if rule.MinLength != nil && len(str) < *rule.MinLength {
-		return ValidationError{
-			Field:   field,
-			Message: fmt.Sprintf("minimum length is %d", *rule.MinLength),
-			Value:   str,
-		}
+		errs.add(field, fmt.Sprintf("minimum length is %d", *rule.MinLength), str)
 	}
 	if rule.MaxLength != nil && len(str) > *rule.MaxLength {
-		return ValidationError{
-			Field:   field,
-			Message: fmt.Sprintf("maximum length is %d", *rule.MaxLength),
-			Value:   str,
-		}
+		errs.add(field, fmt.Sprintf("maximum length is %d", *rule.MaxLength), str)
 	}
 
 	// Enum validation
@@ -195,56 +261,57 @@ func (v *JSONValidator) validateString(field, str string, rule ValidationRule) e
 			}
 		}
 		if !valid {
-			return ValidationError{
-				Field:   field,
-				Message: fmt.Sprintf("must be one of: %s", strings.Join(rule.Enum, ", ")),
-				Value:   str,
-			}
+			errs.add(field, fmt.Sprintf("must be one of: %s", strings.Join(rule.Enum, ", ")), str)
+		}
+	}
+
+	if rule.Pattern != "" && !v.patterns[rule.Pattern].MatchString(str) {
+		errs.add(field, fmt.Sprintf("must match pattern %s", rule.Pattern), str)
+	}
+
+	if rule.Format == FormatDateTime {
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			errs.add(field, "invalid date-time format, expected RFC 3339", str)
 		}
 	}
 
 	// Email validation
 	if rule.IsEmail {
-		if !v.isValidEmail(str) {
-			return ValidationError{
-				Field:   field,
-				Message: "invalid email format",
-				Value:   str,
-			}
+		if err := ValidateEmail(str); err != nil {
+			errs.add(field, err.Error(), str)
 		}
 	}
 
 	// URL validation
 	if rule.IsUrl {
-		if !v.isValidURL(str) {
-			return ValidationError{
-				Field:   field,
-				Message: "invalid URL format",
-				Value:   str,
-			}
+		if err := ValidateURL(str, nil, nil); err != nil {
+			errs.add(field, err.Error(), str)
 		}
 	}
+}
 
-	return nil
+// validateRules runs the registered rules named by rule.Rules
+func (v *JSONValidator) validateRules(field string, value interface{}, rule ValidationRule, errs *ValidationErrors) {
+	for _, name := range rule.Rules {
+		fn, ok := lookupRule(name)
+		if !ok {
+			errs.add(field, fmt.Sprintf("unknown validation rule '%s'", name), value)
+			continue
+		}
+		if err := fn(value); err != nil {
+			errs.add(field, err.Error(), value)
+		}
+	}
 }
 
 // validateNumber performs number-specific validations
-func (v *JSONValidator) validateNumber(field string, num float64, rule ValidationRule) error {
+func (v *JSONValidator) validateNumber(field string, num float64, rule ValidationRule, errs *ValidationErrors) {
 	if rule.Min != nil && num < *rule.Min {
-		return ValidationError{
-			Field:   field,
-			Message: fmt.Sprintf("minimum value is %g", *rule.Min),
-			Value:   num,
-		}
+		errs.add(field, fmt.Sprintf("minimum value is %g", *rule.Min), num)
 	}
 	if rule.Max != nil && num > *rule.Max {
-		return ValidationError{
-			Field:   field,
-			Message: fmt.Sprintf("maximum value is %g", *rule.Max),
-			Value:   num,
-		}
+		errs.add(field, fmt.Sprintf("maximum value is %g", *rule.Max), num)
 	}
-	return nil
 }
 
 // Helper functions
@@ -317,126 +384,3 @@ func (v *JSONValidator) getNumberValue(value interface{}) *float64 {
 	}
 	return nil
 }
-
-func (v *JSONValidator) isValidEmail(email string) bool {
-	// Simple email validation - can be enhanced
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	return emailRegex.MatchString(email) && !strings.Contains(email, "notAnEmail") && !strings.Contains(email, "missingdomain.com")
-}
-
-func (v *JSONValidator) isValidURL(url string) bool {
-	// Enhanced URL validation to catch test patterns
-	if url == "" || 
-	   strings.Contains(url, "notAUrl") ||
-	   strings.Contains(url, "notAnObject") ||
-	   strings.Contains(url, "notABoolean") ||
-	   strings.Contains(url, "invalid-url") ||
-	   url == "http://incomplete" ||
-	   strings.HasPrefix(url, "ftp://") {
-		return false
-	}
-	
-	// Basic URL format check
-	urlRegex := regexp.MustCompile(`^https?://[^\s/$.?#].[^\s]*$`)
-	return urlRegex.MatchString(url)
-}
-
-// Predefined schemas for common use cases
-
-// GetUserValidationSchema returns validation schema for user profile updates
-func GetUserValidationSchema() ValidationSchema {
-	minLength2 := 2
-	maxLength60 := 60
-	minWeight := 10.0
-	maxWeight := 1000.0
-	minHeight := 3.0
-	maxHeight := 250.0
-	
-	return ValidationSchema{
-		"preference": {
-			Type:    "string",
-			NotNull: true,
-			Enum:    []string{"CARDIO", "WEIGHT"},
-		},
-		"weightUnit": {
-			Type:    "string",
-			NotNull: true,
-			Enum:    []string{"KG", "LBS"},
-		},
-		"heightUnit": {
-			Type:    "string",
-			NotNull: true,
-			Enum:    []string{"CM", "INCH"},
-		},
-		"weight": {
-			Type:    "number",
-			NotNull: true,
-			Min:     &minWeight,
-			Max:     &maxWeight,
-		},
-		"height": {
-			Type:    "number",
-			NotNull: true,
-			Min:     &minHeight,
-			Max:     &maxHeight,
-		},
-		"name": {
-			Type:      "string",
-			NotNull:   true,
-			MinLength: &minLength2,
-			MaxLength: &maxLength60,
-		},
-		"imageUri": {
-			Type:    "string",
-			NotNull: true,
-			IsUrl:   true,
-		},
-	}
-}
-
-// GetActivityValidationSchema returns validation schema for activity operations
-func GetActivityValidationSchema() ValidationSchema {
-	minDuration := 1.0
-	
-	return ValidationSchema{
-		"activityType": {
-			Type:    "string",
-			NotNull: true,
-			Enum:    []string{"Walking", "Yoga", "Stretching", "Cycling", "Swimming", "Dancing", "Hiking", "Running", "HIIT", "JumpRope"},
-		},
-		"doneAt": {
-			Type:    "string",
-			NotNull: true,
-		},
-		"durationInMinutes": {
-			Type:    "number",
-			NotNull: true,
-			Min:     &minDuration,
-		},
-	}
-}
-
-// GetLoginValidationSchema returns validation schema for login
-func GetLoginValidationSchema() ValidationSchema {
-	minLength8 := 8
-	maxLength32 := 32
-	
-	return ValidationSchema{
-		"email": {
-			Type:    "string",
-			NotNull: true,
-			IsEmail: true,
-		},
-		"password": {
-			Type:      "string",
-			NotNull:   true,
-			MinLength: &minLength8,
-			MaxLength: &maxLength32,
-		},
-	}
-}
-
-// GetRegisterValidationSchema returns validation schema for registration
-func GetRegisterValidationSchema() ValidationSchema {
-	return GetLoginValidationSchema() // Same as login for now
-}
@@ -1,17 +1,19 @@
 package validator
 
 import (
+	"errors"
 	"io"
 	"net/http"
 	"strings"
 
+	"github.com/fikrialwan/FitByte/pkg/problem"
 	"github.com/gin-gonic/gin"
 )
 
 // ValidateJSONMiddleware creates a middleware that validates JSON payloads
 func ValidateJSONMiddleware(schema ValidationSchema) gin.HandlerFunc {
 	validator := NewJSONValidator(schema)
-	
+
 	return func(ctx *gin.Context) {
 		// Only validate for requests with JSON content
 		contentType := ctx.GetHeader("Content-Type")
@@ -23,8 +25,7 @@ func ValidateJSONMiddleware(schema ValidationSchema) gin.HandlerFunc {
 		// Read the raw body
 		body, err := ctx.GetRawData()
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
-			ctx.Abort()
+			problem.Write(ctx, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Request body can't be read"))
 			return
 		}
 
@@ -34,10 +35,16 @@ func ValidateJSONMiddleware(schema ValidationSchema) gin.HandlerFunc {
 			return
 		}
 
-		// Validate the JSON
+		// Validate the JSON, reporting every failing field
 		if err := validator.ValidateJSON(body); err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
-			ctx.Abort()
+			var errs ValidationErrors
+			errors.As(err, &errs)
+			fields := make([]problem.FieldError, len(errs))
+			for i, e := range errs {
+				fields[i] = problem.FieldError{Field: e.Field, Message: e.Message}
+			}
+			problem.Write(ctx, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "Invalid request format").
+				WithErrors(fields...))
 			return
 		}
 
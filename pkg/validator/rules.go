@@ -0,0 +1,135 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	playground "github.com/go-playground/validator/v10"
+)
+
+// RuleFunc checks a single value and returns an error describing why it's
+// invalid. The value is the decoded JSON value in the schema validator and the
+// struct field value during Gin binding.
+type RuleFunc func(value interface{}) error
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]RuleFunc{}
+)
+
+// RegisterRule adds a named rule usable in schemas (ValidationRule.Rules) and
+// in binding tags, e.g. `binding:"omitempty,imageuri"`. Registering an
+// existing name replaces it. Rules must be registered before the first
+// request is bound.
+func RegisterRule(name string, fn RuleFunc) error {
+	check := func(fl playground.FieldLevel) bool {
+		return fn(fl.Field().Interface()) == nil
+	}
+	if err := validate.RegisterValidation(name, check); err != nil {
+		return err
+	}
+	if engine, ok := binding.Validator.Engine().(*playground.Validate); ok {
+		if err := engine.RegisterValidation(name, check); err != nil {
+			return err
+		}
+	}
+
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = fn
+	return nil
+}
+
+func lookupRule(name string) (RuleFunc, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	fn, ok := rules[name]
+	return fn, ok
+}
+
+// ValidateEmail accepts a bare RFC 5322 address such as "john@example.com".
+// Display names and dotless domains are rejected.
+func ValidateEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return errors.New("invalid email format")
+	}
+
+	_, domain, _ := strings.Cut(address.Address, "@")
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return errors.New("invalid email domain")
+	}
+	return nil
+}
+
+// defaultURLSchemes are the schemes accepted when none are configured
+var defaultURLSchemes = []string{"http", "https"}
+
+// ValidateURL accepts an absolute URL with one of the schemes and a host that
+// matches one of hosts. A host entry matches the hostname, host:port or, when
+// written as "*.example.com", any subdomain. Empty schemes means http and
+// https, empty hosts allows any host.
+func ValidateURL(rawURL string, schemes, hosts []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.Opaque != "" {
+		return errors.New("invalid URL format")
+	}
+	if strings.ContainsAny(rawURL, " \t\r\n") {
+		return errors.New("invalid URL format")
+	}
+
+	if len(schemes) == 0 {
+		schemes = defaultURLSchemes
+	}
+	if !containsFold(schemes, u.Scheme) {
+		return fmt.Errorf("URL scheme must be one of: %s", strings.Join(schemes, ", "))
+	}
+
+	if len(hosts) > 0 && !matchesHost(u, hosts) {
+		return errors.New("URL host is not allowed")
+	}
+	return nil
+}
+
+// URLRule returns a rule applying ValidateURL to strings with the given
+// allowed schemes and hosts
+func URLRule(schemes, hosts []string) RuleFunc {
+	return func(value interface{}) error {
+		str, ok := value.(string)
+		if !ok {
+			return errors.New("expected string type")
+		}
+		return ValidateURL(str, schemes, hosts)
+	}
+}
+
+func matchesHost(u *url.URL, hosts []string) bool {
+	hostname := strings.ToLower(u.Hostname())
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		switch {
+		case host == "":
+		case strings.HasPrefix(host, "*."):
+			if strings.HasSuffix(hostname, host[1:]) {
+				return true
+			}
+		case host == hostname || host == strings.ToLower(u.Host):
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
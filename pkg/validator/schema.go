@@ -0,0 +1,188 @@
+package validator
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// validators caches the JSONValidator built for each struct type
+var validators sync.Map
+
+// ValidateJSONFor validates a JSON payload against the schema derived from
+// the struct type of v, see SchemaFromStruct
+func ValidateJSONFor(jsonData []byte, v interface{}) error {
+	t := reflect.TypeOf(v)
+	cached, ok := validators.Load(t)
+	if !ok {
+		cached, _ = validators.LoadOrStore(t, NewJSONValidator(SchemaFromStruct(v)))
+	}
+	return cached.(*JSONValidator).ValidateJSON(jsonData)
+}
+
+// SchemaFromStruct builds a ValidationSchema from the json and binding tags of
+// a struct, so request constraints are declared once on the DTO and shared by
+// Gin binding, swag and this validator. Supported binding rules are required,
+// min, max, oneof, email, url and rules added with RegisterRule, others are
+// left to Gin. Fields may be omitted unless required but can't be null, and a
+// swaggertype/format tag overrides the type inferred for custom types.
+func SchemaFromStruct(v interface{}) ValidationSchema {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return schemaFromType(t)
+}
+
+func schemaFromType(t reflect.Type) ValidationSchema {
+	schema := ValidationSchema{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// encoding/json promotes the fields of untagged embedded structs
+		if field.Anonymous && name == "" && indirect(field.Type).Kind() == reflect.Struct {
+			for embeddedName, rule := range schemaFromType(indirect(field.Type)) {
+				if _, exists := schema[embeddedName]; !exists {
+					schema[embeddedName] = rule
+				}
+			}
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		schema[name] = ruleFromField(field)
+	}
+
+	return schema
+}
+
+func ruleFromField(field reflect.StructField) ValidationRule {
+	rule := ruleFromType(field.Type)
+	rule.NotNull = true
+
+	if swaggerType := field.Tag.Get("swaggertype"); swaggerType != "" {
+		rule = ValidationRule{Type: jsonType(swaggerType), NotNull: true}
+	}
+	if format := field.Tag.Get("format"); format != "" {
+		rule.Format = format
+	}
+
+	applyBindingTag(&rule, field.Tag.Get("binding"))
+	return rule
+}
+
+func ruleFromType(t reflect.Type) ValidationRule {
+	t = indirect(t)
+	if t == timeType {
+		return ValidationRule{Type: "string", Format: FormatDateTime}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return ValidationRule{Type: "string"}
+	case reflect.Bool:
+		return ValidationRule{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return ValidationRule{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		items := ruleFromType(t.Elem())
+		return ValidationRule{Type: "array", Items: &items}
+	case reflect.Struct:
+		return ValidationRule{Type: "object", Properties: schemaFromType(t)}
+	case reflect.Map:
+		return ValidationRule{Type: "object"}
+	}
+
+	// Unknown types are only checked by the binding rules
+	return ValidationRule{}
+}
+
+func applyBindingTag(rule *ValidationRule, tag string) {
+	for _, option := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "required":
+			rule.Required = true
+		case "min", "gte":
+			rule.setMin(value)
+		case "max", "lte":
+			rule.setMax(value)
+		case "oneof":
+			rule.Enum = strings.Fields(value)
+		case "email":
+			rule.IsEmail = true
+		case "url":
+			rule.IsUrl = true
+		case "dive":
+			// rules after dive apply to the elements, which Gin checks
+			return
+		default:
+			if _, ok := lookupRule(key); ok {
+				rule.Rules = append(rule.Rules, key)
+			}
+		}
+	}
+}
+
+// setMin applies a min rule as a length for strings and a value for numbers
+func (rule *ValidationRule) setMin(value string) {
+	switch rule.Type {
+	case "string":
+		if n, err := strconv.Atoi(value); err == nil {
+			rule.MinLength = &n
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			rule.Min = &f
+		}
+	}
+}
+
+// setMax applies a max rule as a length for strings and a value for numbers
+func (rule *ValidationRule) setMax(value string) {
+	switch rule.Type {
+	case "string":
+		if n, err := strconv.Atoi(value); err == nil {
+			rule.MaxLength = &n
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			rule.Max = &f
+		}
+	}
+}
+
+// jsonType maps swagger primitive types to validator types
+func jsonType(swaggerType string) string {
+	switch swaggerType {
+	case "integer", "number":
+		return "number"
+	case "string", "boolean", "object", "array":
+		return swaggerType
+	}
+	return ""
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
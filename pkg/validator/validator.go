@@ -1,14 +1,47 @@
 package validator
 
-import "github.com/go-playground/validator/v10"
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
 
 var validate *validator.Validate
 
 func init() {
 	validate = validator.New()
+	validate.RegisterTagNameFunc(jsonFieldName)
+
+	// Report binding failures with the JSON field names clients sent
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		engine.RegisterTagNameFunc(jsonFieldName)
+	}
 }
 
 func Check(value interface{}) bool {
 	err := validate.Struct(value)
 	return err != nil
 }
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// FieldPath returns the JSON path of a failed field, e.g. "items[0].name",
+// without the name of the validated struct
+func FieldPath(err validator.FieldError) string {
+	_, path, found := strings.Cut(err.Namespace(), ".")
+	if !found {
+		return err.Field()
+	}
+	return path
+}
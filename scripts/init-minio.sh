@@ -42,26 +42,7 @@ else
     fi
 fi
 
-# Set bucket policy to public read using MinIO API
-echo "Setting bucket policy to public read..."
-policy='{
-  "Version": "2012-10-17",
-  "Statement": [
-    {
-      "Effect": "Allow",
-      "Principal": {"AWS": "*"},
-      "Action": ["s3:GetObject"],
-      "Resource": ["arn:aws:s3:::'$BUCKET_NAME'/*"]
-    }
-  ]
-}'
-
-curl -s -X PUT \
-    -H "Content-Type: application/json" \
-    --user "${MINIO_ROOT_USER}:${MINIO_ROOT_PASSWORD}" \
-    --data "$policy" \
-    "$MINIO_URL/minio/admin/v3/set-bucket-policy?bucket=$BUCKET_NAME" \
-    >/dev/null 2>&1
+# The bucket stays private: the API hands out presigned download URLs
 
 echo "MinIO bucket '$BUCKET_NAME' initialized successfully!"
 echo "You can access MinIO console at: http://localhost:9001"